@@ -1,13 +1,16 @@
 package awscommons
 
 import (
+	"encoding/json"
 	goerrors "errors"
+	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
 	"github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
 
 	"github.com/gruntwork-io/go-commons/errors"
+	"github.com/gruntwork-io/go-commons/logging"
 )
 
 // GetSecretsManagerMetadata returns the metadata of the Secrets Manager entry with the given ID.
@@ -38,7 +41,9 @@ func SecretsManagerEntryExists(opts *Options, arn string) (bool, error) {
 	return true, nil
 }
 
-// GetSecretsManagerSecretString will return the secret value stored at the given Secrets Manager ARN.
+// GetSecretsManagerSecretString will return the secret value stored at the given Secrets Manager ARN. The value is
+// registered as a secret with the logging package, along with the values of its secret keys (e.g. "password") if it is
+// a JSON object (as created for key/value secrets in the console), so that they are masked in all logs.
 func GetSecretsManagerSecretString(opts *Options, arn string) (string, error) {
 	client, err := NewSecretsManagerClient(opts)
 	if err != nil {
@@ -49,7 +54,41 @@ func GetSecretsManagerSecretString(opts *Options, arn string) (string, error) {
 	if err != nil {
 		return "", errors.WithStackTrace(err)
 	}
-	return aws.ToString(secretVal.SecretString), nil
+	secretString := aws.ToString(secretVal.SecretString)
+	registerSecret(secretString)
+	return secretString, nil
+}
+
+// The words that mark a key of a JSON secret as holding a secret value, once lower cased and without separators. Other
+// values (e.g. username, host or region) are not secret on their own, and masking them would mask common words in
+// every log line.
+var secretKeyWords = []string{"password", "passwd", "passphrase", "secret", "token", "apikey", "privatekey", "credential"}
+
+// registerSecret registers the given secret value with the logging package, along with the values of the keys that
+// hold secrets (see secretKeyWords) if it is a JSON object.
+func registerSecret(secretString string) {
+	logging.RegisterSecret(secretString)
+
+	keyValues := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(secretString), &keyValues); err != nil {
+		return
+	}
+	for key, value := range keyValues {
+		if stringValue, isString := value.(string); isString && isSecretKey(key) {
+			logging.RegisterSecret(stringValue)
+		}
+	}
+}
+
+// isSecretKey returns true if the given key of a JSON secret holds a secret value, e.g. "password" or "api-key".
+func isSecretKey(key string) bool {
+	normalizedKey := strings.NewReplacer("_", "", "-", "", ".", "", " ", "").Replace(strings.ToLower(key))
+	for _, word := range secretKeyWords {
+		if strings.Contains(normalizedKey, word) {
+			return true
+		}
+	}
+	return false
 }
 
 // NewSecretsManagerClient will return a new AWS SDK client for interacting with AWS Secrets Manager.
@@ -1,6 +1,7 @@
 package awscommons
 
 import (
+	"fmt"
 	"testing"
 
 	goaws "github.com/aws/aws-sdk-go-v2/aws"
@@ -8,6 +9,8 @@ import (
 	"github.com/gruntwork-io/terratest/modules/random"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/gruntwork-io/go-commons/logging"
 )
 
 // Test GetSecretsManagerSecret by creating the secret in terratest and using the function to read the value to make
@@ -26,6 +29,24 @@ func TestGetSecretString(t *testing.T) {
 	actualSecret, err := GetSecretsManagerSecretString(opts, secretARN)
 	require.NoError(t, err)
 	assert.Equal(t, secretVal, actualSecret)
+	assert.Equal(t, logging.RedactedText, logging.Redact(secretVal))
+}
+
+// Test registerSecret only masks the values of the secret keys of a JSON secret, and not common values like the user
+// name or the region.
+func TestRegisterSecretOnlyMasksSecretKeys(t *testing.T) {
+	t.Parallel()
+
+	username := "user-" + random.UniqueId()
+	password := "password-" + random.UniqueId()
+	apiKey := "api-key-" + random.UniqueId()
+	secretString := fmt.Sprintf(`{"username": "%s", "password": "%s", "API_KEY": "%s"}`, username, password, apiKey)
+
+	registerSecret(secretString)
+	assert.Equal(t, logging.RedactedText, logging.Redact(secretString))
+	assert.Equal(t, logging.RedactedText, logging.Redact(password))
+	assert.Equal(t, logging.RedactedText, logging.Redact(apiKey))
+	assert.Equal(t, username, logging.Redact(username))
 }
 
 // Test SecretsManagerEntryExists returns false when calling it on a secrets manager entry that doesn't exist.
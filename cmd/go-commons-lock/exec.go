@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	goerrors "errors"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/gruntwork-io/go-commons/collections"
+	"github.com/gruntwork-io/go-commons/errors"
+	"github.com/gruntwork-io/go-commons/lock"
+)
+
+// The signals that are passed through to the command run by exec
+var forwardedSignals = []os.Signal{syscall.SIGTERM, syscall.SIGHUP}
+
+// The signals that the terminal sends to the whole foreground process group, e.g. on Ctrl-C, so the command run by exec
+// gets them without our help. Forwarding them would deliver them twice, which commands like terraform treat as a
+// request to abort right away.
+var terminalSignals = []os.Signal{os.Interrupt, syscall.SIGQUIT}
+
+// MissingCommandError is returned when exec is called without a command to run.
+type MissingCommandError struct{}
+
+func (err MissingCommandError) Error() string {
+	return "You must pass in the command to run after --"
+}
+
+func execCommand(newLocker newLockerFunc) cli.ActionFunc {
+	return func(cliContext *cli.Context) error {
+		args := cliContext.Args().Slice()
+		if len(args) == 0 {
+			return errors.WithStackTrace(MissingCommandError{})
+		}
+
+		// The timeout only applies to acquiring the lock, not to running the command.
+		locker := acquireTimeoutLocker{Locker: newLocker(cliContext), cliContext: cliContext}
+		return lock.WithLockerLock(cliContext.Context, locker, newOptions(cliContext), func(ctx context.Context) error {
+			// Don't start the command if we were interrupted while acquiring the lock.
+			if err := ctx.Err(); err != nil {
+				return errors.WithStackTrace(err)
+			}
+			return runCommand(args)
+		})
+	}
+}
+
+// acquireTimeoutLocker is a Locker that bounds the time it takes to acquire a lock by the timeout flag, if it is set.
+type acquireTimeoutLocker struct {
+	lock.Locker
+	cliContext *cli.Context
+}
+
+func (locker acquireTimeoutLocker) AcquireLock(ctx context.Context, options *lock.Options) (*lock.Lock, error) {
+	if timeout := locker.cliContext.Duration(optTimeout); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return locker.Locker.AcquireLock(ctx, options)
+}
+
+// runCommand runs the given command with the standard streams of this process, passing through the forwardedSignals
+// this process receives while the command runs. The terminalSignals are caught but not forwarded, so that they don't
+// kill this process before the command exits. They are not ignored with signal.Ignore, as the command would inherit that. If the command fails, this returns an ErrorWithExitCode with the exit code of the command.
+func runCommand(args []string) error {
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, append(forwardedSignals, terminalSignals...)...)
+	defer signal.Stop(signals)
+
+	if err := cmd.Start(); err != nil {
+		return errors.WithStackTrace(err)
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				if !collections.ListContainsElement(terminalSignals, sig) {
+					cmd.Process.Signal(sig)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	err := cmd.Wait()
+	var exitErr *exec.ExitError
+	if goerrors.As(err, &exitErr) {
+		return errors.WithStackTrace(errors.ErrorWithExitCode{Err: err, ExitCode: exitCode(exitErr)})
+	}
+	return errors.WithStackTrace(err)
+}
+
+// exitCode returns the exit code of the given failed command. Like shells do, this is 128 plus the number of the signal
+// for commands that were killed by a signal.
+func exitCode(exitErr *exec.ExitError) int {
+	if status, isWaitStatus := exitErr.Sys().(syscall.WaitStatus); isWaitStatus && status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+	return exitErr.ExitCode()
+}
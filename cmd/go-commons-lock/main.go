@@ -0,0 +1,273 @@
+// Command go-commons-lock acquires, releases and inspects the locks of the go-commons lock package from the command line, so that
+// shell based pipelines can use the same DynamoDB lock table as our Go tooling (and Terraform).
+//
+// The most convenient way to use it is `go-commons-lock exec`, which holds a lock for as long as a command runs:
+//
+//	go-commons-lock exec --table my-lock-table --lock-string prod-deploy --lease 1m -- ./deploy.sh
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/gruntwork-io/go-commons/entrypoint"
+	"github.com/gruntwork-io/go-commons/errors"
+	"github.com/gruntwork-io/go-commons/lock"
+	"github.com/gruntwork-io/go-commons/logging"
+)
+
+// The version of the command. This is set at build time with -ldflags "-X main.version=<version>".
+var version = "dev"
+
+const (
+	optRegion              = "region"
+	optTable               = "table"
+	optLockString          = "lock-string"
+	optOwner               = "owner"
+	optDescription         = "description"
+	optMode                = "mode"
+	optCapacity            = "capacity"
+	optLease               = "lease"
+	optMaxRetries          = "max-retries"
+	optSleepBetweenRetries = "sleep-between-retries"
+	optTimeout             = "timeout"
+	optTerraform           = "terraform"
+	optPrefix              = "prefix"
+)
+
+func main() {
+	entrypoint.RunApp(newApp(newDynamoDBLocker))
+}
+
+// newLockerFunc returns the Locker to use for the given invocation of the command.
+type newLockerFunc func(cliContext *cli.Context) lock.Locker
+
+func newDynamoDBLocker(cliContext *cli.Context) lock.Locker {
+	return lock.NewDynamoDBLocker()
+}
+
+// newApp returns the CLI app, using the given function to get the Locker to use.
+func newApp(newLocker newLockerFunc) *cli.App {
+	app := entrypoint.NewApp("go-commons-lock", version)
+	app.Usage = "Acquire, release and inspect locks in a DynamoDB lock table."
+	app.Commands = []*cli.Command{
+		{
+			Name:      "acquire",
+			Usage:     "Acquire a lock and print its fencing token. Note that nothing renews the lease of the lock once this command exits: use exec to hold a leased lock while a command runs.",
+			Flags:     append(lockFlags(true), acquireFlags()...),
+			Action:    acquireCommand(newLocker),
+			ArgsUsage: " ",
+		},
+		{
+			Name:      "release",
+			Usage:     "Release a lock held by the given owner.",
+			Flags:     append(lockFlags(true), modeFlags()...),
+			Action:    releaseCommand(newLocker),
+			ArgsUsage: " ",
+		},
+		{
+			Name:      "status",
+			Usage:     "Print the record of a lock as JSON, or nothing if the lock is not held.",
+			Flags:     lockFlags(false),
+			Action:    statusCommand(newLocker),
+			ArgsUsage: " ",
+		},
+		{
+			Name:  "list",
+			Usage: "List the locks held in a lock table.",
+			Flags: append(
+				tableFlags(),
+				&cli.StringFlag{Name: optPrefix, Usage: "Only list the locks whose ID starts with this prefix."},
+				&cli.StringFlag{Name: optOwner, Usage: "Only list the locks held by this owner."},
+			),
+			Action:    listCommand(newLocker),
+			ArgsUsage: " ",
+		},
+		{
+			Name:      "force-unlock",
+			Usage:     "Release a lock whoever holds it, like terraform force-unlock. This takes the ID of the lock, which is its owner, or the ID in its Terraform lock info.",
+			Flags:     lockFlags(false),
+			Action:    forceUnlockCommand(newLocker),
+			ArgsUsage: "LOCK_ID",
+		},
+		{
+			Name:      "exec",
+			Usage:     "Acquire a lock, run a command while holding it, and release the lock once the command exits. SIGTERM and SIGHUP are passed through to the command (which gets Ctrl-C straight from the terminal), and the exit code of the command is returned.",
+			Flags:     append(lockFlags(false), acquireFlags()...),
+			Action:    execCommand(newLocker),
+			ArgsUsage: "-- COMMAND [ARGS...]",
+		},
+	}
+	return app
+}
+
+func tableFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{Name: optRegion, Usage: "The AWS region of the lock table.", EnvVars: []string{"AWS_REGION"}},
+		&cli.StringFlag{Name: optTable, Usage: "The name of the DynamoDB lock table.", EnvVars: []string{"LOCK_TABLE"}, Required: true},
+	}
+}
+
+// lockFlags returns the flags that identify a lock. The owner is required by the commands that act on behalf of the
+// holder of the lock across invocations, as the default owner is unique to each process.
+func lockFlags(requireOwner bool) []cli.Flag {
+	return append(
+		tableFlags(),
+		&cli.StringFlag{Name: optLockString, Usage: "The ID of the lock.", Required: true},
+		&cli.StringFlag{Name: optOwner, Usage: "The unique identifier of the holder of the lock.", Required: requireOwner},
+	)
+}
+
+func modeFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{Name: optMode, Usage: "The mode of the lock: exclusive, shared or semaphore.", Value: string(lock.ExclusiveMode)},
+		&cli.IntFlag{Name: optCapacity, Usage: "The maximum number of holders of a lock in semaphore mode."},
+	}
+}
+
+func acquireFlags() []cli.Flag {
+	return append(
+		modeFlags(),
+		&cli.StringFlag{Name: optDescription, Usage: "A description of why the lock is held."},
+		&cli.DurationFlag{Name: optLease, Usage: "How long the lock is held for before it expires, unless it is renewed. By default, the lock never expires."},
+		&cli.IntFlag{Name: optMaxRetries, Usage: "How many times to retry acquiring the lock if it is held by someone else.", Value: 0},
+		&cli.DurationFlag{Name: optSleepBetweenRetries, Usage: "How long to sleep for between retries.", Value: 10 * time.Second},
+		&cli.DurationFlag{Name: optTimeout, Usage: "How long to wait for the lock for at most. By default, this is only bounded by the retries."},
+		&cli.BoolFlag{Name: optTerraform, Usage: "Also record Terraform's lock info on the lock, so that Terraform can show who holds it."},
+	)
+}
+
+// newOptions returns the lock options for the given invocation of the command.
+func newOptions(cliContext *cli.Context) *lock.Options {
+	options := &lock.Options{
+		AwsRegion:           cliContext.String(optRegion),
+		LockTable:           cliContext.String(optTable),
+		LockString:          cliContext.String(optLockString),
+		Owner:               cliContext.String(optOwner),
+		Description:         cliContext.String(optDescription),
+		Mode:                lock.LockMode(cliContext.String(optMode)),
+		Capacity:            cliContext.Int(optCapacity),
+		LeaseDuration:       cliContext.Duration(optLease),
+		MaxRetries:          cliContext.Int(optMaxRetries),
+		SleepBetweenRetries: cliContext.Duration(optSleepBetweenRetries),
+		Logger:              logging.GetLogger(cliContext.App.Name, cliContext.App.Version),
+	}
+	if cliContext.Bool(optTerraform) {
+		options.TerraformLockInfo = &lock.TerraformLockInfo{Operation: cliContext.Command.Name, Version: version}
+	}
+	return options
+}
+
+// acquireContext returns the context to use to acquire a lock, which is bounded by the timeout flag if it is set.
+func acquireContext(cliContext *cli.Context) (context.Context, context.CancelFunc) {
+	if timeout := cliContext.Duration(optTimeout); timeout > 0 {
+		return context.WithTimeout(cliContext.Context, timeout)
+	}
+	return context.WithCancel(cliContext.Context)
+}
+
+func acquireCommand(newLocker newLockerFunc) cli.ActionFunc {
+	return func(cliContext *cli.Context) error {
+		ctx, cancel := acquireContext(cliContext)
+		defer cancel()
+
+		acquired, err := newLocker(cliContext).AcquireLock(ctx, newOptions(cliContext))
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(cliContext.App.Writer, acquired.FencingToken)
+		return errors.WithStackTrace(err)
+	}
+}
+
+func releaseCommand(newLocker newLockerFunc) cli.ActionFunc {
+	return func(cliContext *cli.Context) error {
+		return newLocker(cliContext).ReleaseLock(cliContext.Context, newOptions(cliContext))
+	}
+}
+
+func statusCommand(newLocker newLockerFunc) cli.ActionFunc {
+	return func(cliContext *cli.Context) error {
+		status, err := newLocker(cliContext).GetLockStatus(cliContext.Context, newOptions(cliContext))
+		if err != nil || status == nil {
+			return err
+		}
+
+		encoder := json.NewEncoder(cliContext.App.Writer)
+		encoder.SetIndent("", "  ")
+		return errors.WithStackTrace(encoder.Encode(status))
+	}
+}
+
+func listCommand(newLocker newLockerFunc) cli.ActionFunc {
+	return func(cliContext *cli.Context) error {
+		filter := lock.LockFilter{IDPrefix: cliContext.String(optPrefix), Owner: cliContext.String(optOwner)}
+		locks, err := newLocker(cliContext).ListLocks(cliContext.Context, newOptions(cliContext), filter)
+		if err != nil {
+			return err
+		}
+		return printLocks(cliContext.App.Writer, locks, time.Now())
+	}
+}
+
+// printLocks prints a table of the given locks, with their owner, age and remaining lease as of the given time.
+func printLocks(writer io.Writer, locks []lock.Lock, now time.Time) error {
+	tabWriter := tabwriter.NewWriter(writer, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tabWriter, "ID\tMODE\tOWNER\tAGE\tLEASE")
+
+	for _, heldLock := range locks {
+		mode := heldLock.Mode
+		if mode == "" {
+			mode = lock.ExclusiveMode
+		}
+
+		owner := heldLock.Owner
+		if owner == "" {
+			owner = fmt.Sprintf("%d holders", len(heldLock.Holders))
+		}
+
+		lease := "-"
+		if heldLock.ExpiresAt != 0 {
+			lease = heldLock.LeaseRemaining(now).Round(time.Second).String()
+		}
+
+		fmt.Fprintf(tabWriter, "%s\t%s\t%s\t%s\t%s\n", heldLock.ID, mode, owner, heldLock.Age(now).Round(time.Second), lease)
+	}
+
+	return errors.WithStackTrace(tabWriter.Flush())
+}
+
+func forceUnlockCommand(newLocker newLockerFunc) cli.ActionFunc {
+	return func(cliContext *cli.Context) error {
+		if cliContext.NArg() != 1 {
+			return errors.WithStackTrace(MissingLockIDError{})
+		}
+
+		locker, canForceRelease := newLocker(cliContext).(lock.ForceReleaser)
+		if !canForceRelease {
+			return errors.WithStackTrace(ForceUnlockNotSupportedError{})
+		}
+		return locker.ForceReleaseLock(cliContext.Context, newOptions(cliContext), cliContext.Args().First())
+	}
+}
+
+// MissingLockIDError is returned when force-unlock is called without a lock ID.
+type MissingLockIDError struct{}
+
+func (err MissingLockIDError) Error() string {
+	return "You must pass in the ID of the lock to force-unlock"
+}
+
+// ForceUnlockNotSupportedError is returned when force-unlock is called on a lock backend that can't release the locks
+// of other holders.
+type ForceUnlockNotSupportedError struct{}
+
+func (err ForceUnlockNotSupportedError) Error() string {
+	return "The lock backend does not support force-unlock"
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/random"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+
+	"github.com/gruntwork-io/go-commons/errors"
+	"github.com/gruntwork-io/go-commons/lock"
+	"github.com/gruntwork-io/go-commons/logging"
+)
+
+func TestAcquireStatusListAndRelease(t *testing.T) {
+	t.Parallel()
+
+	app, output := newTestApp(lock.NewMemoryLocker())
+	lockString := "test-lock-string-" + random.UniqueId()
+	lockArgs := []string{"--table", "test-lock-table", "--lock-string", lockString, "--owner", "test-owner"}
+
+	require.NoError(t, app.Run(append([]string{"go-commons-lock", "acquire"}, lockArgs...)))
+	assert.Equal(t, "1\n", output.String())
+
+	output.Reset()
+	require.NoError(t, app.Run(append([]string{"go-commons-lock", "status"}, lockArgs...)))
+	assert.Contains(t, output.String(), `"Owner": "test-owner"`)
+
+	output.Reset()
+	require.NoError(t, app.Run([]string{"go-commons-lock", "list", "--table", "test-lock-table", "--prefix", lockString}))
+	assert.Contains(t, output.String(), lockString)
+	assert.Contains(t, output.String(), "test-owner")
+
+	require.NoError(t, app.Run(append([]string{"go-commons-lock", "release"}, lockArgs...)))
+
+	output.Reset()
+	require.NoError(t, app.Run(append([]string{"go-commons-lock", "status"}, lockArgs...)))
+	assert.Empty(t, output.String())
+}
+
+func TestExecReturnsExitCodeOfCommand(t *testing.T) {
+	t.Parallel()
+
+	locker := lock.NewMemoryLocker()
+	app, _ := newTestApp(locker)
+	lockString := "test-lock-string-" + random.UniqueId()
+
+	err := app.Run([]string{"go-commons-lock", "exec", "--table", "test-lock-table", "--lock-string", lockString, "--", "sh", "-c", "exit 3"})
+	require.Error(t, err)
+	exitErr, isExitErr := errors.Unwrap(err).(errors.ErrorWithExitCode)
+	require.True(t, isExitErr, "expected an ErrorWithExitCode, got %v", err)
+	assert.Equal(t, 3, exitErr.ExitCode)
+
+	status, err := locker.GetLockStatus(context.Background(), &lock.Options{LockTable: "test-lock-table", LockString: lockString})
+	require.NoError(t, err)
+	assert.Nil(t, status)
+}
+
+func TestExecDoesNotRunCommandWithoutLock(t *testing.T) {
+	t.Parallel()
+
+	locker := lock.NewMemoryLocker()
+	app, _ := newTestApp(locker)
+	lockString := "test-lock-string-" + random.UniqueId()
+	marker := filepath.Join(t.TempDir(), "ran")
+
+	options := &lock.Options{LockTable: "test-lock-table", LockString: lockString, Owner: "other-owner", Logger: logging.GetLogger(t.Name(), "")}
+	_, err := locker.AcquireLock(context.Background(), options)
+	require.NoError(t, err)
+
+	err = app.Run([]string{"go-commons-lock", "exec", "--table", "test-lock-table", "--lock-string", lockString, "--sleep-between-retries", "1ms", "--", "touch", marker})
+	assert.Error(t, err)
+	assert.NoFileExists(t, marker)
+}
+
+func TestExecTimeoutOnlyAppliesToAcquiringLock(t *testing.T) {
+	t.Parallel()
+
+	app, _ := newTestApp(lock.NewMemoryLocker())
+	lockString := "test-lock-string-" + random.UniqueId()
+	marker := filepath.Join(t.TempDir(), "ran")
+
+	require.NoError(t, app.Run([]string{"go-commons-lock", "exec", "--table", "test-lock-table", "--lock-string", lockString, "--timeout", "1m", "--", "touch", marker}))
+	assert.FileExists(t, marker)
+}
+
+func TestForceUnlockReleasesLockOfOtherOwner(t *testing.T) {
+	t.Parallel()
+
+	locker := lock.NewMemoryLocker()
+	app, _ := newTestApp(locker)
+	lockString := "test-lock-string-" + random.UniqueId()
+
+	options := &lock.Options{LockTable: "test-lock-table", LockString: lockString, Owner: "other-owner", Logger: logging.GetLogger(t.Name(), "")}
+	_, err := locker.AcquireLock(context.Background(), options)
+	require.NoError(t, err)
+
+	err = app.Run([]string{"go-commons-lock", "force-unlock", "--table", "test-lock-table", "--lock-string", lockString, "wrong-owner"})
+	require.Error(t, err)
+	assert.IsType(t, lock.LockIDMismatchError{}, errors.Unwrap(err))
+
+	require.NoError(t, app.Run([]string{"go-commons-lock", "force-unlock", "--table", "test-lock-table", "--lock-string", lockString, "other-owner"}))
+
+	status, err := locker.GetLockStatus(context.Background(), options)
+	require.NoError(t, err)
+	assert.Nil(t, status)
+}
+
+func newTestApp(locker lock.Locker) (*cli.App, *bytes.Buffer) {
+	app := newApp(func(cliContext *cli.Context) lock.Locker { return locker })
+	output := &bytes.Buffer{}
+	app.Writer = output
+	return app, output
+}
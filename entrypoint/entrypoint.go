@@ -1,6 +1,7 @@
 package entrypoint
 
 import (
+	goerrors "errors"
 	"os"
 
 	"github.com/urfave/cli/v2"
@@ -60,17 +61,28 @@ func logError(err error, app *cli.App) {
 	}
 }
 
+// exitStatusError is implemented by errors that carry the exit status of a child process, such as
+// shell.ShellCommandError.
+type exitStatusError interface {
+	error
+	ExitStatus() int
+}
+
 // getExitCode will return an exit code to use for the CLI app. This will either be:
 // - defaultSuccessExitCode if there is no error.
 // - defaultErrorExitCode if there is a standard error.
 // - error exit code if there is an error that indicates an exit code.
+// - the exit status of a child process if there is an error from a failed child process (e.g. shell.ShellCommandError).
 func getExitCode(err error) int {
 	exitCode := defaultSuccessExitCode
 	if err != nil {
 		errWithoutStackTrace := errors.Unwrap(err)
 		errorWithExitCode, isErrorWithExitCode := errWithoutStackTrace.(errors.ErrorWithExitCode)
+		var exitStatusErr exitStatusError
 		if isErrorWithExitCode {
 			exitCode = errorWithExitCode.ExitCode
+		} else if goerrors.As(err, &exitStatusErr) && exitStatusErr.ExitStatus() > 0 {
+			exitCode = exitStatusErr.ExitStatus()
 		} else {
 			exitCode = defaultErrorExitCode
 		}
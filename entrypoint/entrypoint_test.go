@@ -41,6 +41,11 @@ func TestEntrypointGetExitCode(t *testing.T) {
 			),
 			127,
 		},
+		{
+			"TestErrorWithExitStatusWithStackTrace",
+			errors.WithStackTrace(fmt.Errorf("Running tests: %w", testExitStatusError{exitStatus: 3})),
+			3,
+		},
 	}
 	for _, testCase := range testCases {
 		t.Run(testCase.name, func(t *testing.T) {
@@ -49,6 +54,19 @@ func TestEntrypointGetExitCode(t *testing.T) {
 	}
 }
 
+// testExitStatusError is an error that carries the exit status of a child process, like shell.ShellCommandError.
+type testExitStatusError struct {
+	exitStatus int
+}
+
+func (err testExitStatusError) Error() string {
+	return fmt.Sprintf("exit status %d", err.exitStatus)
+}
+
+func (err testExitStatusError) ExitStatus() int {
+	return err.exitStatus
+}
+
 func TestEntrypointNewAppWrapsAppHelpPrinter(t *testing.T) {
 	app := createSampleApp()
 	fakeStdout := bytes.NewBufferString("")
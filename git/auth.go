@@ -3,6 +3,7 @@ package git
 import (
 	"fmt"
 
+	"github.com/gruntwork-io/go-commons/logging"
 	"github.com/gruntwork-io/go-commons/shell"
 	"github.com/hashicorp/go-multierror"
 	"github.com/sirupsen/logrus"
@@ -142,7 +143,8 @@ func ConfigureCacheCredentialsHelper(logger *logrus.Entry, options CacheCredenti
 }
 
 // StoreCacheCredentials stores the given git credentials for the vcs host and path pair to the git credential-cache
-// helper.
+// helper. The token is registered as a secret with the logging package, so that it is masked in all logs and in the
+// output of the commands run with the shell package.
 func StoreCacheCredentials(
 	logger *logrus.Entry,
 	gitUsername string,
@@ -151,6 +153,8 @@ func StoreCacheCredentials(
 	vcsPath string,
 	socketPath string,
 ) error {
+	logging.RegisterSecret(gitOauthToken)
+
 	opts := shell.NewShellOptions()
 	if logger != nil {
 		opts.Logger = logger
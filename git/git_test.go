@@ -7,6 +7,7 @@ import (
 
 	"github.com/gruntwork-io/go-commons/files"
 	"github.com/gruntwork-io/go-commons/logging"
+	"github.com/gruntwork-io/go-commons/shell"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -29,3 +30,15 @@ func TestGitCheckout(t *testing.T) {
 	require.NoError(t, Checkout(logging.GetLogger(t.Name(), ""), "v0.10.0", tmpDir))
 	assert.False(t, files.FileExists(filepath.Join(tmpDir, "git", "git_test.go")))
 }
+
+// This test sets the default executor of the shell package, so it must not run in parallel.
+func TestGitCheckoutRunsGitInTargetDir(t *testing.T) {
+	fake := shell.NewFakeExecutor()
+	fake.On("git", "checkout", "v0.10.0")
+	defer shell.SetDefaultExecutor(fake)()
+
+	tmpDir := t.TempDir()
+	require.NoError(t, Checkout(logging.GetLogger(t.Name(), ""), "v0.10.0", tmpDir))
+	require.Len(t, fake.Calls(), 1)
+	assert.Equal(t, tmpDir, fake.Calls()[0].WorkingDir)
+}
@@ -1,3 +1,4 @@
 // Package lock contains helper functions for maintaining a lock table and utilizing it to guard critical sections in
-// the code.
+// the code. Locks are stored in DynamoDB by default, but the Locker interface also has implementations that store
+// locks in files on the local file system or in memory.
 package lock
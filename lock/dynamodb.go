@@ -0,0 +1,222 @@
+package lock
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/gruntwork-io/go-commons/errors"
+)
+
+// DynamoDBAPI is the subset of the DynamoDB client used by the lock package. It is satisfied by *dynamodb.Client, and
+// can be implemented by callers that want to inject a fake client in tests.
+type DynamoDBAPI interface {
+	CreateTable(ctx context.Context, params *dynamodb.CreateTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.CreateTableOutput, error)
+	DescribeTable(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error)
+	DescribeTimeToLive(ctx context.Context, params *dynamodb.DescribeTimeToLiveInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTimeToLiveOutput, error)
+	UpdateTimeToLive(ctx context.Context, params *dynamodb.UpdateTimeToLiveInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateTimeToLiveOutput, error)
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error)
+	DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+	Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
+}
+
+// DynamoDBLocker is a Locker that stores locks in a DynamoDB table, using a schema that is compatible with the
+// Terraform lock table. This is the backend used by the package level functions (AcquireLock, ReleaseLock, etc). The
+// region and authentication (or the DynamoDB client to use) are taken from the Options passed to each call.
+type DynamoDBLocker struct{}
+
+// NewDynamoDBLocker returns a Locker that stores locks in DynamoDB.
+func NewDynamoDBLocker() *DynamoDBLocker {
+	return &DynamoDBLocker{}
+}
+
+// AcquireLock acquires the lock in DynamoDB, creating the lock table if necessary. See the package level AcquireLock
+// function for details.
+func (locker *DynamoDBLocker) AcquireLock(ctx context.Context, options *Options) (*Lock, error) {
+	client, err := getDynamoDBClient(ctx, options)
+	if err != nil {
+		options.Logger.Errorf("Error authenticating to AWS: %s\n", err)
+		return nil, err
+	}
+
+	if err := createLockTableIfNecessary(ctx, options, client); err != nil {
+		return nil, errors.WithStackTrace(err)
+	}
+	if err := createHistoryTableIfNecessary(ctx, options, client); err != nil {
+		return nil, errors.WithStackTrace(err)
+	}
+
+	return acquireLockWithRetries(ctx, options, "DynamoDB", func() (*Lock, error) {
+		if lockMode(options).hasHolders() {
+			return acquireSharedLock(ctx, options, client)
+		}
+		return acquireLock(ctx, options, client)
+	})
+}
+
+// ReleaseLock releases the lock in DynamoDB. See the package level ReleaseLock function for details.
+func (locker *DynamoDBLocker) ReleaseLock(ctx context.Context, options *Options) error {
+	return ReleaseLockWithContext(ctx, options)
+}
+
+// ForceReleaseLock releases the lock in DynamoDB, whoever holds it. See the package level ForceReleaseLock function for
+// details.
+func (locker *DynamoDBLocker) ForceReleaseLock(ctx context.Context, options *Options, lockID string) error {
+	return ForceReleaseLockWithContext(ctx, options, lockID)
+}
+
+// GetLockStatus returns the record of the lock stored in DynamoDB, or nil if the lock is not held.
+func (locker *DynamoDBLocker) GetLockStatus(ctx context.Context, options *Options) (*Lock, error) {
+	output, err := getLockStatus(ctx, options)
+	if err != nil {
+		return nil, errors.WithStackTrace(err)
+	}
+	if len(output.Item) == 0 {
+		return nil, nil
+	}
+
+	lock := Lock{}
+	if err := attributevalue.UnmarshalMap(output.Item, &lock); err != nil {
+		return nil, errors.WithStackTrace(err)
+	}
+	now := time.Now()
+	if lock.IsExpired(now) {
+		return nil, nil
+	}
+	lock.dropExpiredHolders(now)
+	lock.fillFromTerraformInfo()
+	return &lock, nil
+}
+
+// ListLocks returns the records of the locks held in the DynamoDB lock table that are selected by the given filter,
+// sorted by ID. This pages through the whole table.
+func (locker *DynamoDBLocker) ListLocks(ctx context.Context, options *Options, filter LockFilter) ([]Lock, error) {
+	client, err := getDynamoDBClient(ctx, options)
+	if err != nil {
+		options.Logger.Errorf("Error authenticating to AWS: %s\n", err)
+		return nil, err
+	}
+
+	locks := []Lock{}
+	paginator := dynamodb.NewScanPaginator(client, newScanLocksInput(options, filter))
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, errors.WithStackTrace(err)
+		}
+
+		pageLocks, err := unmarshalLocks(page.Items, filter)
+		if err != nil {
+			return nil, err
+		}
+		locks = append(locks, pageLocks...)
+	}
+
+	sort.Slice(locks, func(i, j int) bool { return locks[i].ID < locks[j].ID })
+	return locks, nil
+}
+
+// ListLocksPage returns a single page of the records of the locks held in the DynamoDB lock table that are selected by
+// the given filter, starting from the given page token (empty for the first page). Up to pageSize items are read from
+// the table, or up to 1 MB of items if pageSize is 0. As the filter is applied to the items that were read, pages can
+// have fewer locks than that, including none at all. Keep calling this with the NextPageToken of the previous page
+// until it is empty.
+func (locker *DynamoDBLocker) ListLocksPage(ctx context.Context, options *Options, filter LockFilter, pageToken string, pageSize int32) (*LockPage, error) {
+	client, err := getDynamoDBClient(ctx, options)
+	if err != nil {
+		options.Logger.Errorf("Error authenticating to AWS: %s\n", err)
+		return nil, err
+	}
+
+	scanInput := newScanLocksInput(options, filter)
+	if pageToken != "" {
+		scanInput.ExclusiveStartKey = lockKey(pageToken)
+	}
+	if pageSize > 0 {
+		scanInput.Limit = aws.Int32(pageSize)
+	}
+
+	output, err := client.Scan(ctx, scanInput)
+	if err != nil {
+		return nil, errors.WithStackTrace(err)
+	}
+
+	locks, err := unmarshalLocks(output.Items, filter)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(locks, func(i, j int) bool { return locks[i].ID < locks[j].ID })
+
+	page := &LockPage{Locks: locks}
+	if lastKey, hasMorePages := output.LastEvaluatedKey[attributeLockId].(*types.AttributeValueMemberS); hasMorePages {
+		page.NextPageToken = lastKey.Value
+	}
+	return page, nil
+}
+
+// newScanLocksInput returns the input of a scan of the lock table that does as much of the filtering as possible in
+// DynamoDB. The owner is only filtered on once the locks are read, as the locks acquired by Terraform only record their
+// owner in their lock info.
+func newScanLocksInput(options *Options, filter LockFilter) *dynamodb.ScanInput {
+	scanInput := &dynamodb.ScanInput{
+		// Use a consistent read for our scan operation. Though this is more expensive, we do this because the results
+		// are used for reports, so we want to see the latest consistent status.
+		ConsistentRead: aws.Bool(true),
+		TableName:      aws.String(options.LockTable),
+	}
+
+	conditions := []string{}
+	names := map[string]string{}
+	values := map[string]types.AttributeValue{}
+
+	if filter.IDPrefix != "" {
+		conditions = append(conditions, "begins_with(#lockId, :idPrefix)")
+		names["#lockId"] = attributeLockId
+		values[":idPrefix"] = &types.AttributeValueMemberS{Value: filter.IDPrefix}
+	}
+
+	if len(conditions) > 0 {
+		scanInput.FilterExpression = aws.String(strings.Join(conditions, " AND "))
+		scanInput.ExpressionAttributeNames = names
+		scanInput.ExpressionAttributeValues = values
+	}
+	return scanInput
+}
+
+// unmarshalLocks returns the records of the locks in the given items of the lock table that are currently held and
+// selected by the given filter.
+func unmarshalLocks(items []map[string]types.AttributeValue, filter LockFilter) ([]Lock, error) {
+	now := time.Now()
+	locks := []Lock{}
+
+	for _, item := range items {
+		// Skip the state file checksums that Terraform keeps in the lock table.
+		if _, isDigest := item[attributeDigest]; isDigest {
+			continue
+		}
+
+		lock := Lock{}
+		if err := attributevalue.UnmarshalMap(item, &lock); err != nil {
+			return nil, errors.WithStackTrace(err)
+		}
+		if isFencingTokenKey(lock.ID) || isHistoryKey(lock.ID) || lock.IsExpired(now) {
+			continue
+		}
+
+		lock.dropExpiredHolders(now)
+		lock.fillFromTerraformInfo()
+		if filter.Matches(lock) {
+			locks = append(locks, lock)
+		}
+	}
+
+	return locks, nil
+}
@@ -0,0 +1,283 @@
+package lock
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gruntwork-io/go-commons/errors"
+)
+
+const lockFileExtension = ".lock"
+
+// errFileLocked is returned by lockFile when the file is locked by someone else.
+var errFileLocked = fmt.Errorf("file is locked by another process")
+
+// FileLockingNotSupportedError is returned by FileLocker on platforms that don't support flock.
+type FileLockingNotSupportedError struct{}
+
+func (err FileLockingNotSupportedError) Error() string {
+	return "File locking is not supported on this platform"
+}
+
+// FileLocker is a Locker that stores locks as files on the local file system, using flock(2) to ensure that only one
+// process holds a lock at a time. Each lock table is a subdirectory of Dir, and each lock is a file in that directory
+// containing the record of the lock. This is meant for tools that only need to coordinate with other processes on the
+// same host.
+//
+// As the operating system releases the locks of a process when it exits, locks can't outlive a crashed holder and
+// LeaseDuration is ignored. Shared locks are taken with a shared flock, and as their holders can't update the lock
+// file, they are not assigned fencing tokens, and their details are not recorded. Semaphores are not supported. Note
+// that flock is not supported on Windows.
+type FileLocker struct {
+	// The directory in which the lock files are stored
+	Dir string
+
+	mutex sync.Mutex
+	// The open lock files of the locks held through this FileLocker, keyed by path and owner. The lock is held for as
+	// long as the file is open.
+	held map[string]heldLockFile
+}
+
+// heldLockFile is an open lock file of a lock held through a FileLocker.
+type heldLockFile struct {
+	file *os.File
+	mode LockMode
+}
+
+// NewFileLocker returns a Locker that stores locks as files in the given directory.
+func NewFileLocker(dir string) *FileLocker {
+	return &FileLocker{
+		Dir:  dir,
+		held: map[string]heldLockFile{},
+	}
+}
+
+// AcquireLock acquires the lock, retrying according to the options if it is held by someone else.
+func (locker *FileLocker) AcquireLock(ctx context.Context, options *Options) (*Lock, error) {
+	if lockMode(options) == SemaphoreMode {
+		return nil, errors.WithStackTrace(UnsupportedLockModeError{Backend: "file", Mode: SemaphoreMode})
+	}
+
+	return acquireLockWithRetries(ctx, options, "file", func() (*Lock, error) {
+		return locker.acquireLock(options)
+	})
+}
+
+func (locker *FileLocker) acquireLock(options *Options) (*Lock, error) {
+	locker.mutex.Lock()
+	defer locker.mutex.Unlock()
+
+	path := locker.lockPath(options.LockTable, options.LockString)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, errors.WithStackTrace(err)
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, errors.WithStackTrace(err)
+	}
+
+	mode := lockMode(options)
+	if err := lockFile(file, mode != SharedMode); err != nil {
+		file.Close()
+		if err == errFileLocked {
+			return nil, errors.WithStackTrace(AlreadyLockedError{LockTable: options.LockTable, LockString: options.LockString})
+		}
+		return nil, err
+	}
+
+	if mode == SharedMode {
+		lock := newLock(options, time.Now())
+		lock.ExpiresAt = 0
+		lock.Mode = SharedMode
+		locker.held[heldLockKey(path, lock.Owner)] = heldLockFile{file: file, mode: mode}
+		return &lock, nil
+	}
+
+	// The file is kept around when the lock is released, so that it can carry the last fencing token over to the next
+	// holder.
+	previous, err := readLockFile(file)
+	if err != nil {
+		unlockFile(file)
+		file.Close()
+		return nil, err
+	}
+
+	lock := newLock(options, time.Now())
+	lock.ExpiresAt = 0
+	lock.FencingToken = previous.FencingToken + 1
+	if err := writeLockFile(file, lock); err != nil {
+		unlockFile(file)
+		file.Close()
+		return nil, err
+	}
+
+	locker.held[heldLockKey(path, lock.Owner)] = heldLockFile{file: file, mode: mode}
+	return &lock, nil
+}
+
+// ReleaseLock releases the lock. This returns a LockNotHeldError if the lock is not held through this FileLocker by
+// the owner in the options.
+func (locker *FileLocker) ReleaseLock(ctx context.Context, options *Options) error {
+	locker.mutex.Lock()
+	defer locker.mutex.Unlock()
+
+	notHeldErr := errors.WithStackTrace(LockNotHeldError{LockTable: options.LockTable, LockString: options.LockString, Owner: lockOwner(options)})
+
+	key := heldLockKey(locker.lockPath(options.LockTable, options.LockString), lockOwner(options))
+	held, isHeld := locker.held[key]
+	if !isHeld || held.mode != lockMode(options) {
+		return notHeldErr
+	}
+	file := held.file
+
+	if held.mode != SharedMode {
+		lock, err := readLockFile(file)
+		if err != nil {
+			return err
+		}
+		if lock.Owner != lockOwner(options) {
+			return notHeldErr
+		}
+
+		// Clear out the details of the holder, but keep the fencing token for the next holder.
+		if err := writeLockFile(file, Lock{ID: lock.ID, FencingToken: lock.FencingToken}); err != nil {
+			return err
+		}
+	}
+
+	delete(locker.held, key)
+	if err := unlockFile(file); err != nil {
+		file.Close()
+		return err
+	}
+	return errors.WithStackTrace(file.Close())
+}
+
+// GetLockStatus returns the record of the lock, or nil if the lock is not held by any process.
+func (locker *FileLocker) GetLockStatus(ctx context.Context, options *Options) (*Lock, error) {
+	return locker.getLockStatus(options.LockTable, options.LockString)
+}
+
+func (locker *FileLocker) getLockStatus(lockTable string, lockString string) (*Lock, error) {
+	file, err := os.Open(locker.lockPath(lockTable, lockString))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.WithStackTrace(err)
+	}
+	defer file.Close()
+
+	// If we can take an exclusive lock on the file, nobody holds the lock. If we can only take a shared lock, the lock
+	// is held in shared mode, and there are no further details to report.
+	err = lockFile(file, true)
+	if err == nil {
+		return nil, unlockFile(file)
+	}
+	if err != errFileLocked {
+		return nil, err
+	}
+
+	err = lockFile(file, false)
+	if err == nil {
+		return &Lock{ID: lockString, Mode: SharedMode}, unlockFile(file)
+	}
+	if err != errFileLocked {
+		return nil, err
+	}
+
+	lock, err := readLockFile(file)
+	if err != nil {
+		return nil, err
+	}
+	lock.ID = lockString
+	return &lock, nil
+}
+
+// ListLocks returns the records of the locks held in the lock table that are selected by the given filter, sorted by
+// ID. As the holders of shared locks are not recorded, filtering by owner never selects shared locks.
+func (locker *FileLocker) ListLocks(ctx context.Context, options *Options, filter LockFilter) ([]Lock, error) {
+	entries, err := os.ReadDir(filepath.Join(locker.Dir, url.PathEscape(options.LockTable)))
+	if os.IsNotExist(err) {
+		return []Lock{}, nil
+	}
+	if err != nil {
+		return nil, errors.WithStackTrace(err)
+	}
+
+	locks := []Lock{}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), lockFileExtension) {
+			continue
+		}
+
+		lockString, err := url.PathUnescape(strings.TrimSuffix(entry.Name(), lockFileExtension))
+		if err != nil || !strings.HasPrefix(lockString, filter.IDPrefix) {
+			continue
+		}
+
+		lock, err := locker.getLockStatus(options.LockTable, lockString)
+		if err != nil {
+			return nil, err
+		}
+		if lock != nil && filter.Matches(*lock) {
+			locks = append(locks, *lock)
+		}
+	}
+
+	sort.Slice(locks, func(i, j int) bool { return locks[i].ID < locks[j].ID })
+	return locks, nil
+}
+
+// lockPath returns the path of the file backing the given lock. Lock tables and lock strings are escaped so that they
+// can contain path separators.
+func (locker *FileLocker) lockPath(lockTable string, lockString string) string {
+	return filepath.Join(locker.Dir, url.PathEscape(lockTable), url.PathEscape(lockString)+lockFileExtension)
+}
+
+// heldLockKey returns the key of the given lock file held by the given owner in FileLocker.held.
+func heldLockKey(path string, owner string) string {
+	return path + "\x00" + owner
+}
+
+// readLockFile reads the lock record stored in the given lock file. An empty file yields an empty record.
+func readLockFile(file *os.File) (Lock, error) {
+	lock := Lock{}
+
+	if _, err := file.Seek(0, 0); err != nil {
+		return lock, errors.WithStackTrace(err)
+	}
+
+	decoder := json.NewDecoder(file)
+	if err := decoder.Decode(&lock); err != nil && err != io.EOF {
+		return lock, errors.WithStackTrace(err)
+	}
+	return lock, nil
+}
+
+// writeLockFile replaces the lock record stored in the given lock file. The new contents are written before the file
+// is truncated, so that concurrent readers never see an empty file.
+func writeLockFile(file *os.File, lock Lock) error {
+	contents, err := json.Marshal(lock)
+	if err != nil {
+		return errors.WithStackTrace(err)
+	}
+
+	if _, err := file.WriteAt(contents, 0); err != nil {
+		return errors.WithStackTrace(err)
+	}
+	if err := file.Truncate(int64(len(contents))); err != nil {
+		return errors.WithStackTrace(err)
+	}
+	return errors.WithStackTrace(file.Sync())
+}
@@ -0,0 +1,30 @@
+//go:build !windows
+
+package lock
+
+import (
+	"os"
+	"syscall"
+
+	"github.com/gruntwork-io/go-commons/errors"
+)
+
+// lockFile takes an exclusive or shared flock on the given file without blocking. This returns errFileLocked if the
+// file is locked by someone else.
+func lockFile(file *os.File, exclusive bool) error {
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+
+	err := syscall.Flock(int(file.Fd()), how|syscall.LOCK_NB)
+	if err == syscall.EWOULDBLOCK {
+		return errFileLocked
+	}
+	return errors.WithStackTrace(err)
+}
+
+// unlockFile releases the flock on the given file.
+func unlockFile(file *os.File) error {
+	return errors.WithStackTrace(syscall.Flock(int(file.Fd()), syscall.LOCK_UN))
+}
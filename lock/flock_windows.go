@@ -0,0 +1,17 @@
+//go:build windows
+
+package lock
+
+import (
+	"os"
+
+	"github.com/gruntwork-io/go-commons/errors"
+)
+
+func lockFile(file *os.File, exclusive bool) error {
+	return errors.WithStackTrace(FileLockingNotSupportedError{})
+}
+
+func unlockFile(file *os.File) error {
+	return errors.WithStackTrace(FileLockingNotSupportedError{})
+}
@@ -0,0 +1,113 @@
+package lock
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gruntwork-io/go-commons/errors"
+)
+
+// The heartbeats of the leased locks currently held by this process. Each backend chooses keys that identify a lock
+// uniquely within the backend, prefixed with the name of the backend.
+var (
+	heartbeats     = map[string]*heartbeat{}
+	heartbeatsLock = sync.Mutex{}
+)
+
+// heartbeat periodically extends the lease on a lock in the background until it is stopped.
+type heartbeat struct {
+	stop chan struct{}
+	done chan struct{}
+}
+
+// startHeartbeat starts a background goroutine that keeps extending the lease on the lock described by the given
+// options by calling renew. Any heartbeat already running under the same key is stopped first.
+func startHeartbeat(key string, options *Options, renew func() error) {
+	stopHeartbeat(key)
+
+	hb := &heartbeat{
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+
+	heartbeatsLock.Lock()
+	heartbeats[key] = hb
+	heartbeatsLock.Unlock()
+
+	go hb.run(options, renew)
+}
+
+// stopHeartbeat stops the heartbeat running under the given key, if there is one, and waits for it to exit.
+func stopHeartbeat(key string) {
+	heartbeatsLock.Lock()
+	hb, hasHeartbeat := heartbeats[key]
+	delete(heartbeats, key)
+	heartbeatsLock.Unlock()
+
+	if hasHeartbeat {
+		close(hb.stop)
+		<-hb.done
+	}
+}
+
+func (hb *heartbeat) run(options *Options, renew func() error) {
+	defer close(hb.done)
+
+	ticker := time.NewTicker(heartbeatInterval(options))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-hb.stop:
+			return
+		case <-ticker.C:
+			err := renew()
+			if err == nil {
+				continue
+			}
+
+			if _, isNotHeld := errors.Unwrap(err).(LockNotHeldError); isNotHeld {
+				// Someone else took over the lock, most likely because we failed to renew it in time. There is no point
+				// in continuing to renew it.
+				options.Logger.Errorf("Lost lock %s in table %s: lease could not be renewed\n", options.LockString, options.LockTable)
+				return
+			}
+			options.Logger.Warnf("Error renewing lease on lock %s in table %s (will retry): %s\n", options.LockString, options.LockTable, err)
+		}
+	}
+}
+
+// heartbeatInterval returns how often to renew the lease, defaulting to a third of the lease duration so that a couple
+// of failed renewals can be tolerated before the lease runs out.
+func heartbeatInterval(options *Options) time.Duration {
+	if options.HeartbeatInterval > 0 {
+		return options.HeartbeatInterval
+	}
+	return options.LeaseDuration / 3
+}
+
+// InvalidLeaseError is returned when acquiring a lock with a lease that can't be kept alive by the heartbeat, i.e. whose
+// heartbeat interval is not a positive duration shorter than the lease.
+type InvalidLeaseError struct {
+	LockString        string
+	LeaseDuration     time.Duration
+	HeartbeatInterval time.Duration
+}
+
+func (err InvalidLeaseError) Error() string {
+	return fmt.Sprintf("Invalid lease for lock %s: the heartbeat interval (%s) must be greater than zero and shorter than the lease duration (%s)\n", err.LockString, err.HeartbeatInterval, err.LeaseDuration)
+}
+
+// validateLease returns an InvalidLeaseError if the given options have a lease that the heartbeat can't renew in time.
+func validateLease(options *Options) error {
+	if options.LeaseDuration <= 0 {
+		return nil
+	}
+
+	interval := heartbeatInterval(options)
+	if interval <= 0 || interval >= options.LeaseDuration {
+		return errors.WithStackTrace(InvalidLeaseError{LockString: options.LockString, LeaseDuration: options.LeaseDuration, HeartbeatInterval: interval})
+	}
+	return nil
+}
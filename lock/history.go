@@ -0,0 +1,181 @@
+package lock
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/google/uuid"
+
+	"github.com/gruntwork-io/go-commons/errors"
+)
+
+// The infix of the keys of the history records of a lock. The lock table only has a hash key (which Terraform
+// requires), so every record needs a key of its own. Starting the keys with the lock string lets the history of a lock
+// be read with a single filtered scan.
+const historyKeyInfix = "::history::"
+
+// HistoryEvent is the kind of operation recorded in the history of a lock.
+type HistoryEvent string
+
+const (
+	// The lock was acquired
+	HistoryAcquire HistoryEvent = "acquire"
+	// The lease on the lock was renewed by the heartbeat
+	HistoryRenew HistoryEvent = "renew"
+	// The lock was released by its owner
+	HistoryRelease HistoryEvent = "release"
+	// The lock was released with ForceReleaseLock
+	HistoryForceRelease HistoryEvent = "force-release"
+)
+
+// HistoryRecord is an entry in the history of a lock, which is written when Options.RecordHistory is set.
+type HistoryRecord struct {
+	// The key of the record in the history table. This is unique to the record.
+	ID string `json:"LockID" dynamodbav:"LockID"`
+	// The lock string of the lock the record is about
+	LockString string `json:"LockString" dynamodbav:"LockString"`
+	// The operation that was done on the lock
+	Event HistoryEvent `json:"Event" dynamodbav:"Event"`
+	// The owner that held the lock. For force releases, this is the owner whose lock was released.
+	Owner string `json:"Owner,omitempty" dynamodbav:"Owner,omitempty"`
+	// The owner that force released the lock
+	ForcedBy string `json:"ForcedBy,omitempty" dynamodbav:"ForcedBy,omitempty"`
+	// The host and process ID that did the operation
+	Hostname string `json:"Hostname,omitempty" dynamodbav:"Hostname,omitempty"`
+	PID      int    `json:"PID,omitempty" dynamodbav:"PID,omitempty"`
+	// Why the operation was done, taken from Options.Description
+	Reason string `json:"Reason,omitempty" dynamodbav:"Reason,omitempty"`
+	// When the operation was done
+	Timestamp time.Time `json:"Timestamp" dynamodbav:"Timestamp"`
+	// The fencing token of the lock, if it was known at the time
+	FencingToken int64 `json:"FencingToken,omitempty" dynamodbav:"FencingToken,omitempty"`
+}
+
+// newHistoryRecord returns a history record of the given event on the lock described by the given options, done at
+// the given time by the owner in the options.
+func newHistoryRecord(options *Options, event HistoryEvent, now time.Time) HistoryRecord {
+	return HistoryRecord{
+		ID:         historyKey(options.LockString, now),
+		LockString: options.LockString,
+		Event:      event,
+		Owner:      lockOwner(options),
+		Hostname:   hostname,
+		PID:        os.Getpid(),
+		Reason:     options.Description,
+		Timestamp:  now.UTC(),
+	}
+}
+
+// recordHistory appends the given record to the history of the lock described by the given options, if
+// Options.RecordHistory is set. By the time this is called, the operation on the lock has already been done, so
+// failing to record it is logged rather than returned: it would be worse to report that acquiring or releasing the
+// lock failed when it didn't.
+func recordHistory(ctx context.Context, options *Options, client DynamoDBAPI, record HistoryRecord) {
+	if !options.RecordHistory {
+		return
+	}
+
+	item, err := attributevalue.MarshalMap(record)
+	if err == nil {
+		// The condition guards against ever overwriting an existing record, keeping the history append-only.
+		_, err = client.PutItem(ctx, &dynamodb.PutItemInput{
+			Item:                     item,
+			TableName:                aws.String(historyTable(options)),
+			ConditionExpression:      aws.String("attribute_not_exists(#lockId)"),
+			ExpressionAttributeNames: map[string]string{"#lockId": attributeLockId},
+		})
+	}
+	if err != nil {
+		options.Logger.Warnf("Error recording %s of lock %s in history table %s: %s\n", record.Event, options.LockString, historyTable(options), err)
+	}
+}
+
+// GetLockHistory returns the history of the lock described by the given options, oldest first, as recorded in the
+// history table (HistoryTable, or else LockTable). This scans the whole table, as its schema only allows items to be
+// looked up by their full key, so keep the history in a separate table if the lock table is large.
+func GetLockHistory(options *Options) ([]HistoryRecord, error) {
+	return GetLockHistoryWithContext(context.Background(), options)
+}
+
+// GetLockHistoryWithContext is like GetLockHistory, but uses the given context for the calls to DynamoDB.
+func GetLockHistoryWithContext(ctx context.Context, options *Options) ([]HistoryRecord, error) {
+	client, err := getDynamoDBClient(ctx, options)
+	if err != nil {
+		options.Logger.Errorf("Error authenticating to AWS: %s\n", err)
+		return nil, err
+	}
+
+	records := []HistoryRecord{}
+	paginator := dynamodb.NewScanPaginator(client, &dynamodb.ScanInput{
+		ConsistentRead:           aws.Bool(true),
+		TableName:                aws.String(historyTable(options)),
+		FilterExpression:         aws.String("begins_with(#lockId, :historyPrefix)"),
+		ExpressionAttributeNames: map[string]string{"#lockId": attributeLockId},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":historyPrefix": &types.AttributeValueMemberS{Value: historyKeyPrefix(options.LockString)},
+		},
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, errors.WithStackTrace(err)
+		}
+
+		pageRecords := []HistoryRecord{}
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &pageRecords); err != nil {
+			return nil, errors.WithStackTrace(err)
+		}
+		records = append(records, pageRecords...)
+	}
+
+	sort.SliceStable(records, func(i, j int) bool { return records[i].Timestamp.Before(records[j].Timestamp) })
+	return records, nil
+}
+
+// createHistoryTableIfNecessary creates the history table of the lock described by the given options if it is
+// separate from the lock table and doesn't exist yet. It has the same schema as the lock table.
+func createHistoryTableIfNecessary(ctx context.Context, options *Options, client DynamoDBAPI) error {
+	if !options.RecordHistory || historyTable(options) == options.LockTable {
+		return nil
+	}
+
+	tableExists, err := lockTableExistsAndIsActive(ctx, options.HistoryTable, client)
+	if err != nil || tableExists {
+		return err
+	}
+
+	options.Logger.Infof("History table %s does not exist in DynamoDB. Will need to create it just this first time.\n", options.HistoryTable)
+	historyOptions := *options
+	historyOptions.LockTable = options.HistoryTable
+	return createLockTable(ctx, &historyOptions, client)
+}
+
+// historyTable returns the table in which to record the history of the lock described by the given options.
+func historyTable(options *Options) string {
+	if options.HistoryTable != "" {
+		return options.HistoryTable
+	}
+	return options.LockTable
+}
+
+// historyKey returns a new key for a history record of the given lock made at the given time. The keys of the records
+// of a lock sort in the order they were made, and the random suffix keeps records made at the same time apart.
+func historyKey(lockString string, now time.Time) string {
+	return fmt.Sprintf("%s%020d::%s", historyKeyPrefix(lockString), now.UnixNano(), uuid.NewString())
+}
+
+func historyKeyPrefix(lockString string) string {
+	return lockString + historyKeyInfix
+}
+
+func isHistoryKey(lockID string) bool {
+	return strings.Contains(lockID, historyKeyInfix)
+}
@@ -0,0 +1,138 @@
+package lock
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLockHistoryRecordsAcquireAndRelease(t *testing.T) {
+	t.Parallel()
+
+	client := newHistoryDynamoDBClient()
+	options := newTestLockerOptions(t, "test-dynamodb-lock-table")
+	options.RecordHistory = true
+	options.HistoryTable = "test-dynamodb-history-table"
+	options.DynamoDBClient = client
+
+	_, err := acquireLock(context.Background(), options, client)
+	require.NoError(t, err)
+	require.NoError(t, releaseLock(context.Background(), options, client))
+
+	history, err := GetLockHistory(options)
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	assert.Equal(t, HistoryAcquire, history[0].Event)
+	assert.Equal(t, int64(1), history[0].FencingToken)
+	assert.Equal(t, HistoryRelease, history[1].Event)
+	for _, record := range history {
+		assert.Equal(t, options.LockString, record.LockString)
+		assert.Equal(t, options.Owner, record.Owner)
+		assert.Equal(t, options.Description, record.Reason)
+		assert.False(t, record.Timestamp.IsZero())
+	}
+	assert.Equal(t, 2, client.itemCount("test-dynamodb-history-table"))
+}
+
+func TestLockHistoryIsNotRecordedByDefault(t *testing.T) {
+	t.Parallel()
+
+	client := newHistoryDynamoDBClient()
+	options := newTestLockerOptions(t, "test-dynamodb-lock-table")
+	options.DynamoDBClient = client
+
+	_, err := acquireLock(context.Background(), options, client)
+	require.NoError(t, err)
+
+	history, err := GetLockHistory(options)
+	require.NoError(t, err)
+	assert.Empty(t, history)
+}
+
+func TestListLocksSkipsHistoryRecords(t *testing.T) {
+	t.Parallel()
+
+	client := newHistoryDynamoDBClient()
+	options := newTestLockerOptions(t, "test-dynamodb-lock-table")
+	options.RecordHistory = true
+	options.DynamoDBClient = client
+
+	_, err := acquireLock(context.Background(), options, client)
+	require.NoError(t, err)
+
+	locks, err := NewDynamoDBLocker().ListLocks(context.Background(), options, LockFilter{IDPrefix: options.LockString})
+	require.NoError(t, err)
+	assert.Equal(t, []string{options.LockString}, lockIDs(locks))
+}
+
+// historyDynamoDBClient is a fake DynamoDB client that keeps the items of each table in memory. It ignores condition
+// expressions, and only supports the filter expressions on key prefixes that the history is read with. Calling any
+// method that isn't overridden panics.
+type historyDynamoDBClient struct {
+	DynamoDBAPI
+	tables map[string]map[string]map[string]types.AttributeValue
+	mutex  sync.Mutex
+}
+
+func newHistoryDynamoDBClient() *historyDynamoDBClient {
+	return &historyDynamoDBClient{tables: map[string]map[string]map[string]types.AttributeValue{}}
+}
+
+func (client *historyDynamoDBClient) itemCount(table string) int {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	return len(client.tables[table])
+}
+
+func (client *historyDynamoDBClient) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	if client.tables[*params.TableName] == nil {
+		client.tables[*params.TableName] = map[string]map[string]types.AttributeValue{}
+	}
+	id := params.Item[attributeLockId].(*types.AttributeValueMemberS).Value
+	client.tables[*params.TableName][id] = params.Item
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (client *historyDynamoDBClient) UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	return &dynamodb.UpdateItemOutput{
+		Attributes: map[string]types.AttributeValue{attributeFencingToken: numberAttribute(1)},
+	}, nil
+}
+
+func (client *historyDynamoDBClient) DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	id := params.Key[attributeLockId].(*types.AttributeValueMemberS).Value
+	delete(client.tables[*params.TableName], id)
+	return &dynamodb.DeleteItemOutput{}, nil
+}
+
+func (client *historyDynamoDBClient) Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	prefix := ""
+	for _, value := range params.ExpressionAttributeValues {
+		if stringValue, isString := value.(*types.AttributeValueMemberS); isString && strings.HasPrefix(*params.FilterExpression, "begins_with") {
+			prefix = stringValue.Value
+		}
+	}
+
+	items := []map[string]types.AttributeValue{}
+	for id, item := range client.tables[*params.TableName] {
+		if strings.HasPrefix(id, prefix) {
+			items = append(items, item)
+		}
+	}
+	return &dynamodb.ScanOutput{Items: items}, nil
+}
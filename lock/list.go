@@ -0,0 +1,52 @@
+package lock
+
+import (
+	"strings"
+	"time"
+)
+
+// LockFilter selects the locks returned by Locker.ListLocks. The zero value selects all the locks.
+type LockFilter struct {
+	// Only return the locks whose ID starts with this prefix
+	IDPrefix string
+	// Only return the locks held by this owner. For locks with several holders, this matches if the owner is one of
+	// them.
+	Owner string
+}
+
+// Matches returns true if the given lock is selected by the filter.
+func (filter LockFilter) Matches(lock Lock) bool {
+	if !strings.HasPrefix(lock.ID, filter.IDPrefix) {
+		return false
+	}
+	if filter.Owner == "" || lock.Owner == filter.Owner {
+		return true
+	}
+	_, isHolder := lock.Holders[filter.Owner]
+	return isHolder
+}
+
+// LockPage is a page of the records of the locks in a lock table.
+type LockPage struct {
+	// The records of the locks in this page, sorted by ID
+	Locks []Lock
+	// The token to pass in to get the next page, or empty if this is the last page
+	NextPageToken string
+}
+
+// Age returns how long the lock has been held at the given time.
+func (lock Lock) Age(now time.Time) time.Duration {
+	if lock.AcquiredAt.IsZero() {
+		return 0
+	}
+	return now.Sub(lock.AcquiredAt)
+}
+
+// LeaseRemaining returns how long is left on the lease of the lock at the given time. This is 0 for locks without a
+// lease, and negative for locks whose lease has run out.
+func (lock Lock) LeaseRemaining(now time.Time) time.Duration {
+	if lock.ExpiresAt == 0 {
+		return 0
+	}
+	return time.Unix(lock.ExpiresAt, 0).Sub(now)
+}
@@ -1,29 +1,41 @@
 package lock
 
 import (
+	"context"
+	goerrors "errors"
 	"fmt"
+	"os"
+	"strconv"
 	"time"
 
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/dynamodb"
-	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/sirupsen/logrus"
+
+	"github.com/gruntwork-io/go-commons/awscommons/v2"
 	"github.com/gruntwork-io/go-commons/errors"
 	"github.com/gruntwork-io/go-commons/retry"
-	"github.com/sirupsen/logrus"
 )
 
 const (
 	// Terraform requires the DynamoDB table to have a primary key with this name
 	attributeLockId = "LockID"
 
+	// The attribute that stores the time (in seconds since the epoch) at which the lease on the lock runs out. This is
+	// stored as a number so that it can be used as the TTL attribute of the table.
+	attributeExpiresAt = "ExpiresAt"
+
+	// The attributes that record who holds the lock
+	attributeOwner        = "Owner"
+	attributeFencingToken = "FencingToken"
+
 	// This is used as the value for maximum retries when creating the DynamoDB table
 	// Default is to retry for up to 5 minutes
 	maxRetriesWaitingForTableToBeActive = 30
 	sleepBetweenTableStatusChecks       = 10 * time.Second
-
-	dynamoDbPayPerRequestBillingMode = "PAY_PER_REQUEST"
 )
 
 type Options struct {
@@ -42,9 +54,48 @@ type Options struct {
 	SleepBetweenRetries time.Duration
 	// The logger to use for the lock
 	Logger *logrus.Entry
+	// How long the lock is held for before it expires. When set, the lock item records an expiry timestamp (which is
+	// also used as the DynamoDB TTL attribute) and a background heartbeat keeps extending the lease for as long as the
+	// lock is held. Once the lease runs out, other callers can take over the lock. When zero, the lock never expires.
+	LeaseDuration time.Duration
+	// How often the heartbeat extends the lease. Defaults to a third of LeaseDuration.
+	HeartbeatInterval time.Duration
+	// A unique identifier of the holder of the lock. Only the owner can renew or release the lock. Defaults to an
+	// identifier that is unique to the current process.
+	Owner string
+	// A human readable description of why the lock is held (e.g. the pipeline or the operation being run), recorded on
+	// the lock item.
+	Description string
+	// The mode in which to acquire the lock. Defaults to ExclusiveMode. Use SharedMode for callers that only read the
+	// resource guarded by the lock, so that they can run at the same time, or SemaphoreMode to allow up to Capacity
+	// callers to hold the lock at the same time.
+	Mode LockMode
+	// The maximum number of holders of a lock acquired in SemaphoreMode.
+	Capacity int
+	// When set, exclusive locks also record Terraform's lock info, so that they can be inspected and released with
+	// Terraform (e.g. `terraform force-unlock`). The ID of the info is always the owner of the lock, and the other
+	// fields that are left empty are filled in from the lock.
+	TerraformLockInfo *TerraformLockInfo
+	// When set, an append-only history record is written every time the lock is acquired, renewed, released or force
+	// released, recording who did it, when and why (Description). See GetLockHistory.
+	RecordHistory bool
+	// The name of the DynamoDB table to write the history records to, which is created if necessary. Defaults to
+	// LockTable.
+	HistoryTable string
+
+	// Custom AWS config to use to authenticate to AWS in the SDK. If nil, the config is loaded from the default
+	// authentication chain in the SDK, for AwsRegion.
+	AwsConfig *aws.Config
+	// Custom DynamoDB client to use. This takes precedence over AwsConfig, and can be used to point the lock at a
+	// DynamoDB Local endpoint, or to inject a fake client in tests.
+	DynamoDBClient DynamoDBAPI
 
 	// Custom session to use to authenticate to AWS in the SDK. If nil, constructs the session based on the default
 	// authentication chain in the SDK.
+	//
+	// Deprecated: The lock package uses aws-sdk-go-v2, so only the region, credentials, endpoint, HTTP client and
+	// maximum number of retries of the session are used: custom retryers and request handlers are ignored. Use
+	// AwsConfig instead.
 	AwsSession *session.Session
 }
 
@@ -58,6 +109,17 @@ func (err TimeoutExceeded) Error() string {
 	return fmt.Sprintf("Timeout trying to acquire lock %s in table %s (timeout was %s)\n", err.LockString, err.LockTable, err.Timeout)
 }
 
+// LockNotHeldError is returned when trying to renew or release a lock that is not held by the given owner.
+type LockNotHeldError struct {
+	LockTable  string
+	LockString string
+	Owner      string
+}
+
+func (err LockNotHeldError) Error() string {
+	return fmt.Sprintf("Lock %s in table %s is not held by %s\n", err.LockString, err.LockTable, err.Owner)
+}
+
 type TableNotActiveError struct {
 	LockTable string
 }
@@ -66,76 +128,71 @@ func (err TableNotActiveError) Error() string {
 	return fmt.Sprintf("Table %s is not active\n", err.LockTable)
 }
 
-// NewAuthenticatedSession gets an AWS Session, checking that the user has credentials properly configured in their environment
-func NewAuthenticatedSession(awsRegion string) (*session.Session, error) {
-	sessionOptions := session.Options{
-		SharedConfigState: session.SharedConfigEnable,
-		Config:            *aws.NewConfig().WithRegion(awsRegion),
-	}
-	sess, err := session.NewSessionWithOptions(sessionOptions)
-
-	if err != nil {
-		return nil, errors.WithStackTrace(err)
-	}
-
-	if _, err = sess.Config.Credentials.Get(); err != nil {
-		return nil, errors.WithStackTrace(err)
-	}
-
-	return sess, nil
-}
-
 // AcquireLock will attempt to acquire a lock in DynamoDB table while taking the configuration options into account.
 // We are using DynamoDB to create a table to help us track the lock status of different resources.
 // The acquiring of a lock attempts to create a table. The intention is that we have 1 table per resource in a single region.
 // This would allow the locking mechanism to flexibly decide if a resource is locked or not. For test cases where the AWS resource
 // is multi-region, or global, the configuration of which regions to use should reflect that.
+// If LeaseDuration is set, the lock expires unless it is renewed, and a background heartbeat renews it until ReleaseLock
+// is called. This ensures that a process that crashes while holding the lock doesn't block everyone else forever.
 func AcquireLock(options *Options) error {
-	client, err := getDynamoDBClient(options)
-	if err != nil {
-		options.Logger.Errorf("Error authenticating to AWS: %s\n", err)
-		return err
-	}
-
-	if err := createLockTableIfNecessary(options, client); err != nil {
-		return errors.WithStackTrace(err)
-	}
+	return AcquireLockWithContext(context.Background(), options)
+}
 
-	return acquireLockWithRetries(options, client)
+// AcquireLockWithContext is like AcquireLock, but gives up as soon as the given context is done, returning the error of
+// the context. This can be used to bound the time spent waiting for the lock, or to stop waiting when the user presses
+// Ctrl-C.
+func AcquireLockWithContext(ctx context.Context, options *Options) error {
+	_, err := NewDynamoDBLocker().AcquireLock(ctx, options)
+	return err
 }
 
-// acquireLockWithRetries will attempt to acquire the lock defined by the provided lock string in the configured lock table
-// for the configured region. This will retry on failure, until reaching a timeout
-func acquireLockWithRetries(options *Options, client *dynamodb.DynamoDB) error {
-	return retry.DoWithRetry(
-		options.Logger,
-		fmt.Sprintf("Trying to acquire DynamoDB lock %s in table %s\n", options.LockString, options.LockTable),
-		options.MaxRetries,
-		options.SleepBetweenRetries,
-		func() error {
-			return acquireLock(options, client)
-		},
-	)
+// AcquireLockAndGetFencingToken is like AcquireLock, but also returns the fencing token of the lock. Fencing tokens
+// increase every time the lock is acquired, so downstream systems can reject writes that carry a token lower than the
+// highest one they have seen, which is what a holder whose lock has gone stale (e.g. after a long GC pause) would send.
+func AcquireLockAndGetFencingToken(options *Options) (int64, error) {
+	lock, err := NewDynamoDBLocker().AcquireLock(context.Background(), options)
+	if err != nil {
+		return 0, err
+	}
+	return lock.FencingToken, nil
 }
 
 // acquireLock will attempt to acquire the lock defined by the provided lock string in the configured lock table for the
-// configured region
-func acquireLock(options *Options, client *dynamodb.DynamoDB) error {
+// configured region. On success, this returns the record of the acquired lock.
+func acquireLock(ctx context.Context, options *Options, client DynamoDBAPI) (*Lock, error) {
 	options.Logger.Infof("Attempting to acquire lock %s in table %s in region %s\n",
 		options.LockString,
 		options.LockTable,
 		options.AwsRegion,
 	)
 
+	now := time.Now()
+	lock := newLock(options, now)
+	item, err := attributevalue.MarshalMap(lock)
+	if err != nil {
+		return nil, errors.WithStackTrace(err)
+	}
+
+	// The lock can be taken if nobody holds it, or if the lease of the current holder has run out. Locks written
+	// without a lease have no ExpiresAt attribute, so the second clause never matches them. Because the condition is
+	// evaluated atomically with the write, at most one caller can win a takeover of an expired lock.
 	putParams := &dynamodb.PutItemInput{
-		Item: map[string]*dynamodb.AttributeValue{
-			attributeLockId: {S: aws.String(options.LockString)},
-		},
+		Item:                item,
 		TableName:           aws.String(options.LockTable),
-		ConditionExpression: aws.String(fmt.Sprintf("attribute_not_exists(%s)", attributeLockId)),
+		ConditionExpression: aws.String("attribute_not_exists(#lockId) OR #expiresAt < :now"),
+		ExpressionAttributeNames: map[string]string{
+			"#lockId":    attributeLockId,
+			"#expiresAt": attributeExpiresAt,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":now": numberAttribute(now.Unix()),
+		},
 	}
-	_, err := client.PutItem(putParams)
-	if err != nil {
+	if _, err := client.PutItem(ctx, putParams); err != nil {
+		if isConditionalCheckFailedError(err) {
+			err = AlreadyLockedError{LockTable: options.LockTable, LockString: options.LockString}
+		}
 		options.Logger.Errorf(
 			"Error acquiring lock %s in table %s in region %s (already locked?): %s\n",
 			options.LockString,
@@ -143,22 +200,94 @@ func acquireLock(options *Options, client *dynamodb.DynamoDB) error {
 			options.AwsRegion,
 			err,
 		)
+		return nil, errors.WithStackTrace(err)
+	}
+
+	// The fencing token is only assigned once we hold the lock. This guarantees tokens are handed out in the order the
+	// lock was acquired: a holder that stalls before getting its token will fail to record it below, as the lock will
+	// have been taken over by then.
+	lock.FencingToken, err = assignFencingToken(ctx, options, client)
+	if err != nil {
+		options.Logger.Errorf("Error assigning fencing token to lock %s in table %s: %s\n", options.LockString, options.LockTable, err)
+		// Give the lock back, as retrying would otherwise find it held, and a lock without a lease would never be freed.
+		// This must happen even if the token couldn't be assigned because the context is done.
+		_, deleteErr := client.DeleteItem(context.WithoutCancel(ctx), newReleaseLockInput(options))
+		if deleteErr != nil && !isConditionalCheckFailedError(deleteErr) {
+			options.Logger.Errorf("Error releasing lock %s in table %s after failing to assign its fencing token: %s\n", options.LockString, options.LockTable, deleteErr)
+		}
+		return nil, err
+	}
+
+	options.Logger.Infof("Acquired lock '%s' in table %s with fencing token %d\n", options.LockString, options.LockTable, lock.FencingToken)
+
+	record := newHistoryRecord(options, HistoryAcquire, now)
+	record.FencingToken = lock.FencingToken
+	recordHistory(ctx, options, client, record)
+
+	if options.LeaseDuration > 0 {
+		startHeartbeat(dynamoDBHeartbeatKey(options), options, func() error {
+			return renewLease(context.Background(), options, client)
+		})
+	}
+	return &lock, nil
+}
+
+// renewLease extends the lease on the lock. The update is conditional on the lock still being held by the owner in the
+// given options, so that we never extend a lock that has since been taken over by someone else.
+func renewLease(ctx context.Context, options *Options, client DynamoDBAPI) error {
+	now := time.Now()
+	expiresAt := leaseExpiry(now, options.LeaseDuration)
+
+	_, err := client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		Key:                 lockKey(options.LockString),
+		TableName:           aws.String(options.LockTable),
+		UpdateExpression:    aws.String("SET #expiresAt = :expiresAt"),
+		ConditionExpression: aws.String("#owner = :owner"),
+		ExpressionAttributeNames: map[string]string{
+			"#owner":     attributeOwner,
+			"#expiresAt": attributeExpiresAt,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":owner":     &types.AttributeValueMemberS{Value: lockOwner(options)},
+			":expiresAt": numberAttribute(expiresAt.Unix()),
+		},
+	})
+	if isConditionalCheckFailedError(err) {
+		return errors.WithStackTrace(LockNotHeldError{LockTable: options.LockTable, LockString: options.LockString, Owner: lockOwner(options)})
+	}
+	if err != nil {
 		return errors.WithStackTrace(err)
 	}
-	options.Logger.Infof("Acquired lock '%s' in table %s\n", options.LockString, options.LockTable)
+
+	recordHistory(ctx, options, client, newHistoryRecord(options, HistoryRenew, now))
 	return nil
 }
 
+func dynamoDBHeartbeatKey(options *Options) string {
+	return fmt.Sprintf("dynamodb/%s/%s/%s/%s", options.AwsRegion, options.LockTable, options.LockString, lockOwner(options))
+}
+
+// leaseExpiry returns the time at which a lease of the given duration starting at now runs out. DynamoDB TTL works at
+// second granularity, so the expiry is rounded up to the next whole second.
+func leaseExpiry(now time.Time, leaseDuration time.Duration) time.Time {
+	return now.Add(leaseDuration + time.Second - 1).Truncate(time.Second)
+}
+
 // ReleaseLock will attempt to release the lock defined by the provided lock string in the configured lock table for the
 // configured region
 func ReleaseLock(options *Options) error {
-	client, err := getDynamoDBClient(options)
+	return ReleaseLockWithContext(context.Background(), options)
+}
+
+// ReleaseLockWithContext is like ReleaseLock, but uses the given context for the calls to DynamoDB.
+func ReleaseLockWithContext(ctx context.Context, options *Options) error {
+	client, err := getDynamoDBClient(ctx, options)
 	if err != nil {
 		options.Logger.Errorf("Error authenticating to AWS: %s\n", err)
 		return err
 	}
 
-	tableExists, err := lockTableExistsAndIsActive(options.LockTable, client)
+	tableExists, err := lockTableExistsAndIsActive(ctx, options.LockTable, client)
 	if err != nil {
 		options.Logger.Errorf("Error checking if DynamoDB table %s exists and is active\n", options.LockTable)
 		return err
@@ -166,14 +295,20 @@ func ReleaseLock(options *Options) error {
 
 	if !tableExists {
 		options.Logger.Errorf("DynamoDB table %s does not exist\n", options.LockTable)
-		return err
+		return errors.WithStackTrace(TableNotActiveError{LockTable: options.LockTable})
 	}
 
-	return releaseLock(options, client)
+	// Stop extending the lease before deleting the item, so the heartbeat doesn't race with the release.
+	stopHeartbeat(dynamoDBHeartbeatKey(options))
+
+	if lockMode(options).hasHolders() {
+		return releaseSharedLock(ctx, options, client)
+	}
+	return releaseLock(ctx, options, client)
 }
 
 // releaseLock will try to delete the DynamoDB item that serves as the lock object
-func releaseLock(options *Options, client *dynamodb.DynamoDB) error {
+func releaseLock(ctx context.Context, options *Options, client DynamoDBAPI) error {
 	options.Logger.Infof(
 		"Attempting to release lock %s in table %s in region %s\n",
 		options.LockString,
@@ -181,13 +316,10 @@ func releaseLock(options *Options, client *dynamodb.DynamoDB) error {
 		options.AwsRegion,
 	)
 
-	params := &dynamodb.DeleteItemInput{
-		Key: map[string]*dynamodb.AttributeValue{
-			attributeLockId: {S: aws.String(options.LockString)},
-		},
-		TableName: aws.String(options.LockTable),
+	_, err := client.DeleteItem(ctx, newReleaseLockInput(options))
+	if isConditionalCheckFailedError(err) {
+		err = LockNotHeldError{LockTable: options.LockTable, LockString: options.LockString, Owner: lockOwner(options)}
 	}
-	_, err := client.DeleteItem(params)
 
 	if err != nil {
 		options.Logger.Errorf(
@@ -200,40 +332,89 @@ func releaseLock(options *Options, client *dynamodb.DynamoDB) error {
 		return errors.WithStackTrace(err)
 	}
 	options.Logger.Infof("Released lock '%s' in table %s\n", options.LockString, options.LockTable)
+	recordHistory(ctx, options, client, newHistoryRecord(options, HistoryRelease, time.Now()))
 	return nil
 }
 
+// newReleaseLockInput returns the input of the deletion of the item of the lock described by the given options. Only the
+// owner may release the lock, so that a process whose lease ran out can't release a lock that has since been taken over
+// by someone else.
+func newReleaseLockInput(options *Options) *dynamodb.DeleteItemInput {
+	return &dynamodb.DeleteItemInput{
+		Key:                      lockKey(options.LockString),
+		TableName:                aws.String(options.LockTable),
+		ConditionExpression:      aws.String("#owner = :owner"),
+		ExpressionAttributeNames: map[string]string{"#owner": attributeOwner},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":owner": &types.AttributeValueMemberS{Value: lockOwner(options)},
+		},
+	}
+}
+
 // createLockTableIfNecessary will create the lock table in DynamoDB if it doesn't already exist
-func createLockTableIfNecessary(options *Options, client *dynamodb.DynamoDB) error {
-	tableExists, err := lockTableExistsAndIsActive(options.LockTable, client)
+func createLockTableIfNecessary(ctx context.Context, options *Options, client DynamoDBAPI) error {
+	tableExists, err := lockTableExistsAndIsActive(ctx, options.LockTable, client)
 	if err != nil {
 		return err
 	}
 
 	if !tableExists {
 		options.Logger.Infof("Lock table %s does not exist in DynamoDB. Will need to create it just this first time.\n", options.LockTable)
-		return createLockTable(options, client)
+		if err := createLockTable(ctx, options, client); err != nil {
+			return err
+		}
+	}
+
+	if options.LeaseDuration > 0 {
+		enableTimeToLiveIfNecessary(ctx, options, client)
 	}
 
 	return nil
 }
 
+// enableTimeToLiveIfNecessary turns on DynamoDB TTL for the ExpiresAt attribute of the lock table, so that DynamoDB
+// eventually cleans up locks whose lease has run out. Expired locks can be taken over regardless of whether TTL is
+// enabled, so failures here are logged rather than returned.
+func enableTimeToLiveIfNecessary(ctx context.Context, options *Options, client DynamoDBAPI) {
+	output, err := client.DescribeTimeToLive(ctx, &dynamodb.DescribeTimeToLiveInput{TableName: aws.String(options.LockTable)})
+	if err != nil {
+		options.Logger.Warnf("Error checking TTL status of DynamoDB table %s: %s\n", options.LockTable, err)
+		return
+	}
+
+	if output.TimeToLiveDescription != nil && output.TimeToLiveDescription.TimeToLiveStatus != types.TimeToLiveStatusDisabled {
+		return
+	}
+
+	options.Logger.Infof("Enabling TTL on attribute %s of DynamoDB table %s\n", attributeExpiresAt, options.LockTable)
+	_, err = client.UpdateTimeToLive(ctx, &dynamodb.UpdateTimeToLiveInput{
+		TableName: aws.String(options.LockTable),
+		TimeToLiveSpecification: &types.TimeToLiveSpecification{
+			AttributeName: aws.String(attributeExpiresAt),
+			Enabled:       aws.Bool(true),
+		},
+	})
+	if err != nil {
+		options.Logger.Warnf("Error enabling TTL on DynamoDB table %s: %s\n", options.LockTable, err)
+	}
+}
+
 // createLockTable will attempt to create a lock table in DynamoDB and wait until it is in "active" state. If the table already exists, merely wait
 // until it is in "active" state
-func createLockTable(options *Options, client *dynamodb.DynamoDB) error {
+func createLockTable(ctx context.Context, options *Options, client DynamoDBAPI) error {
 	options.Logger.Infof("Creating table %s in DynamoDB...\n", options.LockTable)
 
-	attributeDefinitions := []*dynamodb.AttributeDefinition{
-		{AttributeName: aws.String(attributeLockId), AttributeType: aws.String(dynamodb.ScalarAttributeTypeS)},
+	attributeDefinitions := []types.AttributeDefinition{
+		{AttributeName: aws.String(attributeLockId), AttributeType: types.ScalarAttributeTypeS},
 	}
 
-	keySchema := []*dynamodb.KeySchemaElement{
-		{AttributeName: aws.String(attributeLockId), KeyType: aws.String(dynamodb.KeyTypeHash)},
+	keySchema := []types.KeySchemaElement{
+		{AttributeName: aws.String(attributeLockId), KeyType: types.KeyTypeHash},
 	}
 
-	_, err := client.CreateTable(&dynamodb.CreateTableInput{
+	_, err := client.CreateTable(ctx, &dynamodb.CreateTableInput{
 		TableName:            aws.String(options.LockTable),
-		BillingMode:          aws.String(dynamoDbPayPerRequestBillingMode),
+		BillingMode:          types.BillingModePayPerRequest,
 		AttributeDefinitions: attributeDefinitions,
 		KeySchema:            keySchema,
 	})
@@ -246,22 +427,29 @@ func createLockTable(options *Options, client *dynamodb.DynamoDB) error {
 		}
 	}
 
-	return waitForTableToBeActive(options, client)
+	return waitForTableToBeActive(ctx, options, client)
 }
 
 // isTableAlreadyBeingCreatedOrUpdatedError will return true if the given error is the error message returned by AWS when the resource already exists and is being
 // updated by someone else
 func isTableAlreadyBeingCreatedOrUpdatedError(err error) bool {
-	awsErr, isAwsErr := err.(awserr.Error)
-	return isAwsErr && awsErr.Code() == "ResourceInUseException"
+	var resourceInUseErr *types.ResourceInUseException
+	return goerrors.As(errors.Unwrap(err), &resourceInUseErr)
+}
+
+// isConditionalCheckFailedError returns true if the given error is the error returned by DynamoDB when the condition on
+// a write does not hold.
+func isConditionalCheckFailedError(err error) bool {
+	var conditionalCheckFailedErr *types.ConditionalCheckFailedException
+	return goerrors.As(errors.Unwrap(err), &conditionalCheckFailedErr)
 }
 
 // waitForTableToBeActive will wait for the given DynamoDB table to be in the "active" state. If it's not in "active" state, this function will sleep for the
 // specified amount of time, and try again, up to a maximum of maxRetries retries. Note this is different from the MaxRetires value for how many times to retry when acquiring the lock.
-func waitForTableToBeActive(options *Options, client *dynamodb.DynamoDB) error {
-	return retry.DoWithRetry(options.Logger, fmt.Sprintf("Waiting for Table %s to be active...\n", options.LockTable), maxRetriesWaitingForTableToBeActive, sleepBetweenTableStatusChecks,
+func waitForTableToBeActive(ctx context.Context, options *Options, client DynamoDBAPI) error {
+	return retry.DoWithRetryWithContext(ctx, options.Logger, fmt.Sprintf("Waiting for Table %s to be active...\n", options.LockTable), maxRetriesWaitingForTableToBeActive, sleepBetweenTableStatusChecks,
 		func() error {
-			isReady, err := lockTableExistsAndIsActive(options.LockTable, client)
+			isReady, err := lockTableExistsAndIsActive(ctx, options.LockTable, client)
 			if err != nil {
 				return err
 			}
@@ -277,36 +465,39 @@ func waitForTableToBeActive(options *Options, client *dynamodb.DynamoDB) error {
 }
 
 // lockTableExistsAndIsActive will return true if the lock table exists in DynamoDB and is in "active" state
-func lockTableExistsAndIsActive(tableName string, client *dynamodb.DynamoDB) (bool, error) {
-	output, err := client.DescribeTable(&dynamodb.DescribeTableInput{TableName: aws.String(tableName)})
+func lockTableExistsAndIsActive(ctx context.Context, tableName string, client DynamoDBAPI) (bool, error) {
+	output, err := client.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(tableName)})
 	if err != nil {
-		if awsErr, isAwsErr := err.(awserr.Error); isAwsErr && awsErr.Code() == "ResourceNotFoundException" {
+		var resourceNotFoundErr *types.ResourceNotFoundException
+		if goerrors.As(err, &resourceNotFoundErr) {
 			return false, nil
 		} else {
 			return false, errors.WithStackTrace(err)
 		}
 	}
 
-	return *output.Table.TableStatus == dynamodb.TableStatusActive, nil
+	return output.Table.TableStatus == types.TableStatusActive, nil
 }
 
-// GetLockStatus attempts to acquire the lock and check if the expected item is there
-// If there's the expected Item with the correct `LockString` value - then the status is `locked`, if the item is not there - then the status is `not locked`
-func GetLockStatus(options *Options) (*dynamodb.GetItemOutput, error) {
-	client, err := getDynamoDBClient(options)
+// GetLockStatusV2 reads the item of the lock from the lock table, which is empty if the lock is not held. Use
+// DynamoDBLocker.GetLockStatus to get the decoded record of the lock instead.
+func GetLockStatusV2(options *Options) (*dynamodb.GetItemOutput, error) {
+	return getLockStatus(context.Background(), options)
+}
+
+func getLockStatus(ctx context.Context, options *Options) (*dynamodb.GetItemOutput, error) {
+	client, err := getDynamoDBClient(ctx, options)
 	if err != nil {
 		options.Logger.Errorf("Error authenticating to AWS: %s\n", err)
 		return nil, err
 	}
 
 	getItemParams := &dynamodb.GetItemInput{
-		Key: map[string]*dynamodb.AttributeValue{
-			attributeLockId: {S: aws.String(options.LockString)},
-		},
+		Key:       lockKey(options.LockString),
 		TableName: aws.String(options.LockTable),
 	}
 
-	item, err := client.GetItem(getItemParams)
+	item, err := client.GetItem(ctx, getItemParams)
 	if err != nil {
 		options.Logger.Errorf("Error getting lock status: %s\n", err)
 		return nil, err
@@ -317,62 +508,111 @@ func GetLockStatus(options *Options) (*dynamodb.GetItemOutput, error) {
 
 type Lock struct {
 	// In the DynamoDB lock table, "LockID" will be the key and the deployment URL will be the value
-	ID string `json:"LockID"`
+	ID string `json:"LockID" dynamodbav:"LockID"`
+	// The unique identifier of the holder of the lock
+	Owner string `json:"Owner,omitempty" dynamodbav:"Owner,omitempty"`
+	// The host and process ID of the holder of the lock
+	Hostname string `json:"Hostname,omitempty" dynamodbav:"Hostname,omitempty"`
+	PID      int    `json:"PID,omitempty" dynamodbav:"PID,omitempty"`
+	// The description of why the lock is held, as passed in through Options
+	Description string `json:"Description,omitempty" dynamodbav:"Description,omitempty"`
+	// When the lock was acquired
+	AcquiredAt time.Time `json:"AcquiredAt" dynamodbav:"AcquiredAt"`
+	// When the lease on the lock runs out, in seconds since the epoch. This is 0 for locks without a lease.
+	ExpiresAt int64 `json:"ExpiresAt,omitempty" dynamodbav:"ExpiresAt,omitempty"`
+	// The fencing token assigned to the lock when it was acquired
+	FencingToken int64 `json:"FencingToken,omitempty" dynamodbav:"FencingToken,omitempty"`
+	// The mode in which the lock is held. This is empty for exclusive locks, so that their records stay compatible
+	// with the Terraform lock table.
+	Mode LockMode `json:"Mode,omitempty" dynamodbav:"Mode,omitempty"`
+	// The maximum number of holders of a semaphore
+	Capacity int `json:"Capacity,omitempty" dynamodbav:"Capacity,omitempty"`
+	// The holders of a shared lock or semaphore, keyed by owner. Each holder has its own owner details, lease and
+	// fencing token, so the corresponding fields of the lock itself are left empty, except for ExpiresAt, which is when
+	// the last lease runs out.
+	Holders map[string]Holder `json:"Holders,omitempty" dynamodbav:"Holders,omitempty"`
+	// The JSON encoded Terraform lock info of the lock. This is set on the locks acquired by Terraform, and on the
+	// locks acquired with Options.TerraformLockInfo set. See TerraformInfo.
+	Info string `json:"Info,omitempty" dynamodbav:"Info,omitempty"`
+	// The revision of the record of a shared lock, which changes on every write. Holders update shared locks with
+	// writes that are conditional on the revision they read, so that concurrent updates can't overwrite each other.
+	Version string `json:"Version,omitempty" dynamodbav:"Version,omitempty"`
+}
+
+// newLock returns the record of a lock acquired at the given time by the owner in the given options. The fencing
+// token is assigned separately, once the lock is held.
+func newLock(options *Options, now time.Time) Lock {
+	lock := Lock{
+		ID:          options.LockString,
+		Owner:       lockOwner(options),
+		Hostname:    hostname,
+		PID:         os.Getpid(),
+		Description: options.Description,
+		AcquiredAt:  now.UTC(),
+	}
+	if options.LeaseDuration > 0 {
+		lock.ExpiresAt = leaseExpiry(now, options.LeaseDuration).Unix()
+	}
+	if options.TerraformLockInfo != nil && lockMode(options) == ExclusiveMode {
+		lock.Info = newTerraformLockInfo(options, lock)
+	}
+	return lock
 }
 
 // ScanLocks will perform a scan operation on the indicated DynamoDB table. This operation is useful
 // in certain cases, for example when we want to generate a report of all currently active ref arch
 // deployments tracked in our lock table. It returns a slice of strings representing a list of lock
-// IDs on the table that are currently held
+// IDs on the table that are currently held. Use DynamoDBLocker.ListLocks or DynamoDBLocker.ListLocksPage to get the
+// full records of the locks, or to filter them.
 func ScanLocks(options *Options) ([]string, error) {
-	client, err := getDynamoDBClient(options)
+	locks, err := NewDynamoDBLocker().ListLocks(context.Background(), options, LockFilter{})
 	if err != nil {
-		options.Logger.Errorf("Error authenticating to AWS: %s\n", err)
+		options.Logger.Errorf("Error scanning Lock Table: %s\n", err)
 		return nil, err
 	}
 
-	scanParams := &dynamodb.ScanInput{
-		// Use a consistent read for our scan operation. Though this is more expensive,
-		// we do this because we're using our results for report output, so we want to
-		// see the latest consistent status
-		ConsistentRead: aws.Bool(true),
-		TableName:      aws.String(options.LockTable),
+	lockIDs := []string{}
+	for _, lock := range locks {
+		lockIDs = append(lockIDs, lock.ID)
 	}
+	return lockIDs, nil
+}
 
-	result, err := client.Scan(scanParams)
-	if err != nil {
-		options.Logger.Errorf("Error scanning Lock Table: %s\n", err)
+// getDynamoDBClient returns the DynamoDB client to use for the given options: the injected client if there is one, or
+// else a new client built from the AWS config (or the deprecated session) in the options or from the default
+// authentication chain.
+func getDynamoDBClient(ctx context.Context, options *Options) (DynamoDBAPI, error) {
+	if options.DynamoDBClient != nil {
+		return options.DynamoDBClient, nil
 	}
-
-	// Create a slice to hold the locks we fetch from DynamoDB
-	retrievedLocks := make([]string, len(result.Items))
-
-	//Unmarshal the returned items into strings for easy printing
-	for _, heldLock := range result.Items {
-		lock := Lock{}
-		err := dynamodbattribute.UnmarshalMap(heldLock, &lock)
-		if err != nil {
-			options.Logger.Errorf("Error unmarshalling deployment from DynamoDB: %s", err)
-		}
-		retrievedLocks = append(retrievedLocks, lock.ID)
+	if options.AwsConfig != nil {
+		return dynamodb.NewFromConfig(*options.AwsConfig), nil
+	}
+	if options.AwsSession != nil {
+		return dynamodb.NewFromConfig(awsConfigFromSession(options.AwsSession)), nil
 	}
 
-	return retrievedLocks, nil
+	awsOptions := awscommons.NewOptions(options.AwsRegion)
+	awsOptions.Context = ctx
+	return NewDynamoDBClient(awsOptions)
 }
 
-func getDynamoDBClient(options *Options) (*dynamodb.DynamoDB, error) {
-	if options.AwsSession == nil {
-		return NewDynamoDb(options.AwsRegion)
+// NewDynamoDBClient will return a new AWS SDK client for interacting with DynamoDB.
+func NewDynamoDBClient(opts *awscommons.Options) (*dynamodb.Client, error) {
+	cfg, err := awscommons.NewDefaultConfig(opts)
+	if err != nil {
+		return nil, errors.WithStackTrace(err)
 	}
-	return dynamodb.New(options.AwsSession), nil
+	return dynamodb.NewFromConfig(cfg), nil
 }
 
-// NewDynamoDb returns an authenticated client object for accessing DynamoDb
-func NewDynamoDb(awsRegion string) (*dynamodb.DynamoDB, error) {
-	sess, err := NewAuthenticatedSession(awsRegion)
-	if err != nil {
-		return nil, err
+// lockKey returns the primary key of the item for the given lock string.
+func lockKey(lockString string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		attributeLockId: &types.AttributeValueMemberS{Value: lockString},
 	}
-	dynamodbSvc := dynamodb.New(sess)
-	return dynamodbSvc, nil
+}
+
+func numberAttribute(value int64) *types.AttributeValueMemberN {
+	return &types.AttributeValueMemberN{Value: strconv.FormatInt(value, 10)}
 }
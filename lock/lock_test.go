@@ -1,14 +1,26 @@
 package lock
 
 import (
+	"context"
 	"fmt"
-	"github.com/gruntwork-io/terratest/modules/random"
-	"github.com/stretchr/testify/require"
+	"net/http"
+	"strconv"
 	"testing"
 	"time"
 
-	"github.com/gruntwork-io/go-commons/logging"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	awsv1 "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/gruntwork-io/terratest/modules/random"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gruntwork-io/go-commons/errors"
+	"github.com/gruntwork-io/go-commons/logging"
+	"github.com/gruntwork-io/go-commons/retry"
 )
 
 func TestAcquireLockWithRetries(t *testing.T) {
@@ -47,3 +59,363 @@ func assertLockReleased(t *testing.T, options *Options) {
 
 	assert.Empty(t, item.Item)
 }
+
+func TestAcquireLockKeepsLeaseAliveWithHeartbeat(t *testing.T) {
+	t.Parallel()
+
+	var options = Options{
+		AwsRegion:           "us-east-1",
+		LockTable:           "test-dynamodb-lock-table",
+		LockString:          "test-dynamodb-lock-string-" + random.UniqueId(),
+		MaxRetries:          2,
+		SleepBetweenRetries: 1 * time.Second,
+		Logger:              logging.GetLogger("TestAcquireLockKeepsLeaseAliveWithHeartbeat", ""),
+		LeaseDuration:       3 * time.Second,
+		HeartbeatInterval:   1 * time.Second,
+	}
+
+	defer assertLockReleased(t, &options)
+	defer ReleaseLock(&options)
+
+	require.NoError(t, AcquireLock(&options))
+
+	// Wait until the original lease would have run out: the heartbeat should have extended it in the meantime.
+	time.Sleep(2 * options.LeaseDuration)
+
+	otherOptions := options
+	otherOptions.MaxRetries = 0
+	assert.Error(t, AcquireLock(&otherOptions))
+}
+
+func TestAcquireLockTakesOverExpiredLease(t *testing.T) {
+	t.Parallel()
+
+	var options = Options{
+		AwsRegion:           "us-east-1",
+		LockTable:           "test-dynamodb-lock-table",
+		LockString:          "test-dynamodb-lock-string-" + random.UniqueId(),
+		MaxRetries:          0,
+		SleepBetweenRetries: 1 * time.Second,
+		Logger:              logging.GetLogger("TestAcquireLockTakesOverExpiredLease", ""),
+		LeaseDuration:       2 * time.Second,
+	}
+
+	defer assertLockReleased(t, &options)
+	defer ReleaseLock(&options)
+
+	require.NoError(t, AcquireLock(&options))
+
+	// Simulate a holder that crashed by stopping the heartbeat without releasing the lock.
+	stopHeartbeat(dynamoDBHeartbeatKey(&options))
+	// Leases are tracked at second granularity, so allow for rounding on both ends.
+	time.Sleep(options.LeaseDuration + 3*time.Second)
+
+	assert.NoError(t, AcquireLock(&options))
+}
+
+func TestReleaseLockRequiresOwner(t *testing.T) {
+	t.Parallel()
+
+	var options = Options{
+		AwsRegion:           "us-east-1",
+		LockTable:           "test-dynamodb-lock-table",
+		LockString:          "test-dynamodb-lock-string-" + random.UniqueId(),
+		MaxRetries:          0,
+		SleepBetweenRetries: 1 * time.Second,
+		Logger:              logging.GetLogger("TestReleaseLockRequiresOwner", ""),
+		Owner:               "owner-" + random.UniqueId(),
+		Description:         "TestReleaseLockRequiresOwner",
+	}
+
+	defer assertLockReleased(t, &options)
+	defer ReleaseLock(&options)
+
+	require.NoError(t, AcquireLock(&options))
+
+	otherOptions := options
+	otherOptions.Owner = "other-owner-" + random.UniqueId()
+	err := ReleaseLock(&otherOptions)
+	require.Error(t, err)
+	assert.IsType(t, LockNotHeldError{}, errors.Unwrap(err))
+
+	item, err := GetLockStatusV2(&options)
+	require.NoError(t, err)
+	assert.Equal(t, &types.AttributeValueMemberS{Value: options.Owner}, item.Item[attributeOwner])
+}
+
+func TestAcquireLockFencingTokenIncreases(t *testing.T) {
+	t.Parallel()
+
+	var options = Options{
+		AwsRegion:           "us-east-1",
+		LockTable:           "test-dynamodb-lock-table",
+		LockString:          "test-dynamodb-lock-string-" + random.UniqueId(),
+		MaxRetries:          0,
+		SleepBetweenRetries: 1 * time.Second,
+		Logger:              logging.GetLogger("TestAcquireLockFencingTokenIncreases", ""),
+	}
+
+	defer assertLockReleased(t, &options)
+
+	firstToken, err := AcquireLockAndGetFencingToken(&options)
+	require.NoError(t, err)
+	require.NoError(t, ReleaseLock(&options))
+
+	secondToken, err := AcquireLockAndGetFencingToken(&options)
+	require.NoError(t, err)
+	require.NoError(t, ReleaseLock(&options))
+
+	assert.Greater(t, secondToken, firstToken)
+}
+
+func TestAcquireLockUsesInjectedClient(t *testing.T) {
+	t.Parallel()
+
+	client := &alreadyLockedDynamoDBClient{}
+	var options = Options{
+		LockTable:           "test-dynamodb-lock-table",
+		LockString:          "test-dynamodb-lock-string-" + random.UniqueId(),
+		MaxRetries:          1,
+		SleepBetweenRetries: 1 * time.Millisecond,
+		Logger:              logging.GetLogger("TestAcquireLockUsesInjectedClient", ""),
+		DynamoDBClient:      client,
+	}
+
+	_, err := NewDynamoDBLocker().AcquireLock(context.Background(), &options)
+	require.Error(t, err)
+	assert.IsType(t, retry.MaxRetriesExceeded{}, errors.Unwrap(err))
+	assert.Equal(t, 2, client.putItemCalls)
+
+	_, err = acquireLock(context.Background(), &options, client)
+	require.Error(t, err)
+	assert.IsType(t, AlreadyLockedError{}, errors.Unwrap(err))
+}
+
+// alreadyLockedDynamoDBClient is a fake DynamoDB client with an active lock table in which every lock is already held.
+// Calling any method that isn't overridden panics.
+type alreadyLockedDynamoDBClient struct {
+	DynamoDBAPI
+	putItemCalls int
+}
+
+func (client *alreadyLockedDynamoDBClient) DescribeTable(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error) {
+	return &dynamodb.DescribeTableOutput{Table: &types.TableDescription{TableStatus: types.TableStatusActive}}, nil
+}
+
+func (client *alreadyLockedDynamoDBClient) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	client.putItemCalls++
+	return nil, &types.ConditionalCheckFailedException{}
+}
+
+func TestAcquireLockReleasesLockWhenFencingTokenFails(t *testing.T) {
+	t.Parallel()
+
+	client := &flakyFencingTokenDynamoDBClient{failedUpdates: 1, lockIDs: map[string]bool{}}
+	var options = Options{
+		LockTable:      "test-dynamodb-lock-table",
+		LockString:     "test-dynamodb-lock-string-" + random.UniqueId(),
+		Logger:         logging.GetLogger("TestAcquireLockReleasesLockWhenFencingTokenFails", ""),
+		DynamoDBClient: client,
+	}
+
+	_, err := acquireLock(context.Background(), &options, client)
+	require.Error(t, err)
+	assert.Empty(t, client.lockIDs)
+
+	lock, err := acquireLock(context.Background(), &options, client)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), lock.FencingToken)
+}
+
+// flakyFencingTokenDynamoDBClient is a fake DynamoDB client that keeps track of which locks are held, and fails the
+// given number of updates of fencing tokens before succeeding. Calling any method that isn't overridden panics.
+type flakyFencingTokenDynamoDBClient struct {
+	DynamoDBAPI
+	failedUpdates int
+	lockIDs       map[string]bool
+}
+
+func (client *flakyFencingTokenDynamoDBClient) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	lockID := params.Item[attributeLockId].(*types.AttributeValueMemberS).Value
+	if client.lockIDs[lockID] {
+		return nil, &types.ConditionalCheckFailedException{}
+	}
+	client.lockIDs[lockID] = true
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (client *flakyFencingTokenDynamoDBClient) UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	if client.failedUpdates > 0 {
+		client.failedUpdates--
+		return nil, fmt.Errorf("expected error")
+	}
+	return &dynamodb.UpdateItemOutput{Attributes: map[string]types.AttributeValue{
+		attributeFencingToken: &types.AttributeValueMemberN{Value: "1"},
+	}}, nil
+}
+
+func (client *flakyFencingTokenDynamoDBClient) DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	delete(client.lockIDs, params.Key[attributeLockId].(*types.AttributeValueMemberS).Value)
+	return &dynamodb.DeleteItemOutput{}, nil
+}
+
+func TestReleaseLockFailsWithoutLockTable(t *testing.T) {
+	t.Parallel()
+
+	var options = Options{
+		LockTable:      "test-dynamodb-lock-table",
+		LockString:     "test-dynamodb-lock-string-" + random.UniqueId(),
+		Logger:         logging.GetLogger("TestReleaseLockFailsWithoutLockTable", ""),
+		DynamoDBClient: &missingTableDynamoDBClient{},
+	}
+
+	err := ReleaseLock(&options)
+	require.Error(t, err)
+	assert.IsType(t, TableNotActiveError{}, errors.Unwrap(err))
+}
+
+// missingTableDynamoDBClient is a fake DynamoDB client in which the lock table doesn't exist. Calling any method that
+// isn't overridden panics.
+type missingTableDynamoDBClient struct {
+	DynamoDBAPI
+}
+
+func (client *missingTableDynamoDBClient) DescribeTable(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error) {
+	return nil, &types.ResourceNotFoundException{}
+}
+
+func TestAwsConfigFromSessionKeepsEndpoint(t *testing.T) {
+	t.Parallel()
+
+	httpClient := &http.Client{}
+	sess, err := session.NewSession(awsv1.NewConfig().
+		WithRegion("us-west-2").
+		WithEndpoint("http://localhost:8000").
+		WithHTTPClient(httpClient).
+		WithMaxRetries(2).
+		WithCredentials(credentials.NewStaticCredentials("access-key", "secret-key", "")))
+	require.NoError(t, err)
+
+	config := awsConfigFromSession(sess)
+	assert.Equal(t, "us-west-2", config.Region)
+	assert.Equal(t, "http://localhost:8000", aws.ToString(config.BaseEndpoint))
+	assert.Same(t, httpClient, config.HTTPClient)
+	assert.Equal(t, 3, config.RetryMaxAttempts)
+
+	value, err := config.Credentials.Retrieve(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "access-key", value.AccessKeyID)
+}
+
+func TestGetLockStatusReturnsV1Item(t *testing.T) {
+	t.Parallel()
+
+	client := &getItemDynamoDBClient{item: map[string]types.AttributeValue{
+		attributeLockId:       &types.AttributeValueMemberS{Value: "test-lock-string"},
+		attributeFencingToken: &types.AttributeValueMemberN{Value: "42"},
+		"Holders": &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{
+			"owner": &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{
+				"PID": &types.AttributeValueMemberN{Value: "123"},
+			}},
+		}},
+	}}
+	var options = Options{
+		LockTable:      "test-dynamodb-lock-table",
+		LockString:     "test-lock-string",
+		Logger:         logging.GetLogger("TestGetLockStatusReturnsV1Item", ""),
+		DynamoDBClient: client,
+	}
+
+	output, err := GetLockStatus(&options)
+	require.NoError(t, err)
+	assert.Equal(t, "test-lock-string", *output.Item[attributeLockId].S)
+	assert.Equal(t, "42", *output.Item[attributeFencingToken].N)
+	assert.Equal(t, "123", *output.Item["Holders"].M["owner"].M["PID"].N)
+}
+
+// getItemDynamoDBClient is a fake DynamoDB client that returns the same item for every GetItem call. Calling any
+// method that isn't overridden panics.
+type getItemDynamoDBClient struct {
+	DynamoDBAPI
+	item map[string]types.AttributeValue
+}
+
+func (client *getItemDynamoDBClient) GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	return &dynamodb.GetItemOutput{Item: client.item}, nil
+}
+
+func TestScanLocksReadsAllPages(t *testing.T) {
+	t.Parallel()
+
+	client := &pagedScanDynamoDBClient{
+		pages: [][]string{
+			{"lock-a", "lock-a" + fencingTokenKeySuffix},
+			{},
+			{"lock-b"},
+		},
+	}
+	var options = Options{
+		LockTable:      "test-dynamodb-lock-table",
+		Logger:         logging.GetLogger("TestScanLocksReadsAllPages", ""),
+		DynamoDBClient: client,
+	}
+
+	lockIDs, err := ScanLocks(&options)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"lock-a", "lock-b"}, lockIDs)
+
+	page, err := NewDynamoDBLocker().ListLocksPage(context.Background(), &options, LockFilter{}, "", 0)
+	require.NoError(t, err)
+	assert.Equal(t, "lock-a", page.Locks[0].ID)
+	assert.Equal(t, "0", page.NextPageToken)
+
+	page, err = NewDynamoDBLocker().ListLocksPage(context.Background(), &options, LockFilter{}, page.NextPageToken, 0)
+	require.NoError(t, err)
+	assert.Empty(t, page.Locks)
+	assert.Equal(t, "1", page.NextPageToken)
+}
+
+func TestScanLocksReturnsScanErrors(t *testing.T) {
+	t.Parallel()
+
+	var options = Options{
+		LockTable:      "test-dynamodb-lock-table",
+		Logger:         logging.GetLogger("TestScanLocksReturnsScanErrors", ""),
+		DynamoDBClient: &pagedScanDynamoDBClient{err: fmt.Errorf("expected error")},
+	}
+
+	_, err := ScanLocks(&options)
+	assert.Error(t, err)
+}
+
+// pagedScanDynamoDBClient is a fake DynamoDB client that returns the given pages of lock IDs when scanning, using the
+// index of the page as its last evaluated key, or the given error. Calling any method that isn't overridden panics.
+type pagedScanDynamoDBClient struct {
+	DynamoDBAPI
+	pages [][]string
+	err   error
+}
+
+func (client *pagedScanDynamoDBClient) Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	if client.err != nil {
+		return nil, client.err
+	}
+
+	pageIndex := 0
+	if startKey, hasStartKey := params.ExclusiveStartKey[attributeLockId].(*types.AttributeValueMemberS); hasStartKey {
+		index, err := strconv.Atoi(startKey.Value)
+		if err != nil {
+			return nil, err
+		}
+		pageIndex = index + 1
+	}
+
+	output := &dynamodb.ScanOutput{}
+	for _, lockID := range client.pages[pageIndex] {
+		output.Items = append(output.Items, lockKey(lockID))
+	}
+	if pageIndex < len(client.pages)-1 {
+		output.LastEvaluatedKey = lockKey(strconv.Itoa(pageIndex))
+	}
+	return output, nil
+}
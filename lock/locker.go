@@ -0,0 +1,96 @@
+package lock
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gruntwork-io/go-commons/errors"
+	"github.com/gruntwork-io/go-commons/retry"
+)
+
+// Locker is the interface implemented by each of the backends that can store locks. All the backends share the same
+// semantics:
+//   - A lock is identified by Options.LockTable and Options.LockString, and can be held by one owner at a time, unless
+//     it is acquired in SharedMode or SemaphoreMode (see Options.Mode).
+//   - Acquiring a lock that is already held is retried according to Options.MaxRetries and
+//     Options.SleepBetweenRetries.
+//   - Only the owner (Options.Owner) of a lock can release it.
+//   - Each acquisition of a lock is assigned a fencing token that is greater than that of any previous acquisition.
+//   - Waiting for a lock stops as soon as the given context is done, in which case the error of the context is
+//     returned.
+type Locker interface {
+	// AcquireLock acquires the lock described by the given options and returns the record of the acquired lock. For
+	// locks with several holders, the record has the details of the caller filled in.
+	AcquireLock(ctx context.Context, options *Options) (*Lock, error)
+
+	// ReleaseLock releases the lock described by the given options. This returns a LockNotHeldError if the lock is not
+	// held by the owner in the given options.
+	ReleaseLock(ctx context.Context, options *Options) error
+
+	// GetLockStatus returns the record of the lock described by the given options, or nil if the lock is not held.
+	GetLockStatus(ctx context.Context, options *Options) (*Lock, error)
+
+	// ListLocks returns the records of all the locks currently held in the lock table of the given options that are
+	// selected by the given filter, sorted by ID.
+	ListLocks(ctx context.Context, options *Options, filter LockFilter) ([]Lock, error)
+}
+
+// ForceReleaser is implemented by the Lockers that can release a lock whoever holds it, like `terraform force-unlock`.
+type ForceReleaser interface {
+	// ForceReleaseLock releases the lock described by the given options, whoever holds it. The given lock ID must
+	// identify the current holder of the lock, as described in the package level ForceReleaseLock function. For locks
+	// with several holders, only the holder with that ID is removed.
+	ForceReleaseLock(ctx context.Context, options *Options, lockID string) error
+}
+
+// AlreadyLockedError is returned by the backends when the lock is held by someone else.
+type AlreadyLockedError struct {
+	LockTable  string
+	LockString string
+}
+
+func (err AlreadyLockedError) Error() string {
+	return fmt.Sprintf("Lock %s in table %s is already held\n", err.LockString, err.LockTable)
+}
+
+// UnsupportedLockModeError is returned by the backends that can't hold locks in the requested mode.
+type UnsupportedLockModeError struct {
+	Backend string
+	Mode    LockMode
+}
+
+func (err UnsupportedLockModeError) Error() string {
+	return fmt.Sprintf("The %s lock backend does not support %s locks\n", err.Backend, err.Mode)
+}
+
+// IsExpired returns true if the lock has a lease that has run out by the given time.
+func (lock Lock) IsExpired(now time.Time) bool {
+	return lock.ExpiresAt != 0 && lock.ExpiresAt < now.Unix()
+}
+
+// acquireLockWithRetries calls the given function to acquire the lock described by the given options, retrying on
+// failure according to the options until the given context is done.
+func acquireLockWithRetries(ctx context.Context, options *Options, backend string, acquire func() (*Lock, error)) (*Lock, error) {
+	if lockMode(options) == SemaphoreMode && options.Capacity < 1 {
+		return nil, errors.WithStackTrace(InvalidLockCapacityError{LockString: options.LockString, Capacity: options.Capacity})
+	}
+	if err := validateLease(options); err != nil {
+		return nil, err
+	}
+
+	lock, err := retry.DoWithRetryInterfaceWithContext(
+		ctx,
+		options.Logger,
+		fmt.Sprintf("Trying to acquire %s lock %s in table %s\n", backend, options.LockString, options.LockTable),
+		options.MaxRetries,
+		options.SleepBetweenRetries,
+		func() (interface{}, error) {
+			return acquire()
+		},
+	)
+	if err != nil {
+		return nil, errors.WithStackTrace(err)
+	}
+	return lock.(*Lock), nil
+}
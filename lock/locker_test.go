@@ -0,0 +1,430 @@
+package lock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gruntwork-io/terratest/modules/random"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gruntwork-io/go-commons/errors"
+	"github.com/gruntwork-io/go-commons/logging"
+)
+
+func TestMemoryLocker(t *testing.T) {
+	t.Parallel()
+
+	testLockerConformance(t, NewMemoryLocker(), "test-memory-lock-table")
+}
+
+func TestFileLocker(t *testing.T) {
+	t.Parallel()
+
+	testLockerConformance(t, NewFileLocker(t.TempDir()), "test-file-lock-table")
+}
+
+func TestDynamoDBLocker(t *testing.T) {
+	t.Parallel()
+
+	testLockerConformance(t, NewDynamoDBLocker(), "test-dynamodb-lock-table")
+}
+
+func TestMemoryLockerTakesOverExpiredLease(t *testing.T) {
+	t.Parallel()
+
+	locker := NewMemoryLocker()
+	options := newTestLockerOptions(t, "test-memory-lock-table")
+	options.LeaseDuration = 1 * time.Second
+
+	_, err := locker.AcquireLock(context.Background(), options)
+	require.NoError(t, err)
+
+	// Simulate a holder that crashed by stopping the heartbeat without releasing the lock.
+	stopHeartbeat(locker.heartbeatKey(options))
+	time.Sleep(options.LeaseDuration + 3*time.Second)
+
+	status, err := locker.GetLockStatus(context.Background(), options)
+	require.NoError(t, err)
+	assert.Nil(t, status)
+
+	otherOptions := *options
+	otherOptions.Owner = "other-owner-" + random.UniqueId()
+	_, err = locker.AcquireLock(context.Background(), &otherOptions)
+	require.NoError(t, err)
+	assert.NoError(t, locker.ReleaseLock(context.Background(), &otherOptions))
+}
+
+// testLockerConformance runs the checks that every Locker implementation must pass against the given locker.
+func testLockerConformance(t *testing.T, locker Locker, lockTable string) {
+	t.Run("AcquireAndRelease", func(t *testing.T) {
+		t.Parallel()
+
+		options := newTestLockerOptions(t, lockTable)
+
+		lock, err := locker.AcquireLock(context.Background(), options)
+		require.NoError(t, err)
+		assert.Equal(t, options.LockString, lock.ID)
+		assert.Equal(t, options.Owner, lock.Owner)
+		assert.Equal(t, options.Description, lock.Description)
+		assert.NotEmpty(t, lock.Hostname)
+		assert.NotZero(t, lock.PID)
+
+		require.NoError(t, locker.ReleaseLock(context.Background(), options))
+
+		status, err := locker.GetLockStatus(context.Background(), options)
+		require.NoError(t, err)
+		assert.Nil(t, status)
+	})
+
+	t.Run("AcquireHeldLockFails", func(t *testing.T) {
+		t.Parallel()
+
+		options := newTestLockerOptions(t, lockTable)
+		_, err := locker.AcquireLock(context.Background(), options)
+		require.NoError(t, err)
+		defer locker.ReleaseLock(context.Background(), options)
+
+		otherOptions := *options
+		otherOptions.Owner = "other-owner-" + random.UniqueId()
+		_, err = locker.AcquireLock(context.Background(), &otherOptions)
+		assert.Error(t, err)
+	})
+
+	t.Run("AcquireStopsWhenContextIsDone", func(t *testing.T) {
+		t.Parallel()
+
+		options := newTestLockerOptions(t, lockTable)
+		_, err := locker.AcquireLock(context.Background(), options)
+		require.NoError(t, err)
+		defer locker.ReleaseLock(context.Background(), options)
+
+		otherOptions := *options
+		otherOptions.Owner = "other-owner-" + random.UniqueId()
+		otherOptions.MaxRetries = 100
+
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+
+		start := time.Now()
+		_, err = locker.AcquireLock(ctx, &otherOptions)
+		require.Error(t, err)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Less(t, time.Since(start), 10*time.Second)
+	})
+
+	t.Run("ReleaseRequiresOwner", func(t *testing.T) {
+		t.Parallel()
+
+		options := newTestLockerOptions(t, lockTable)
+		_, err := locker.AcquireLock(context.Background(), options)
+		require.NoError(t, err)
+		defer locker.ReleaseLock(context.Background(), options)
+
+		otherOptions := *options
+		otherOptions.Owner = "other-owner-" + random.UniqueId()
+		err = locker.ReleaseLock(context.Background(), &otherOptions)
+		require.Error(t, err)
+		assert.IsType(t, LockNotHeldError{}, errors.Unwrap(err))
+
+		status, err := locker.GetLockStatus(context.Background(), options)
+		require.NoError(t, err)
+		require.NotNil(t, status)
+		assert.Equal(t, options.Owner, status.Owner)
+	})
+
+	t.Run("ReleaseUnheldLockFails", func(t *testing.T) {
+		t.Parallel()
+
+		options := newTestLockerOptions(t, lockTable)
+		err := locker.ReleaseLock(context.Background(), options)
+		require.Error(t, err)
+		assert.IsType(t, LockNotHeldError{}, errors.Unwrap(err))
+	})
+
+	t.Run("GetLockStatus", func(t *testing.T) {
+		t.Parallel()
+
+		options := newTestLockerOptions(t, lockTable)
+
+		status, err := locker.GetLockStatus(context.Background(), options)
+		require.NoError(t, err)
+		assert.Nil(t, status)
+
+		lock, err := locker.AcquireLock(context.Background(), options)
+		require.NoError(t, err)
+		defer locker.ReleaseLock(context.Background(), options)
+
+		status, err = locker.GetLockStatus(context.Background(), options)
+		require.NoError(t, err)
+		require.NotNil(t, status)
+		assert.Equal(t, options.LockString, status.ID)
+		assert.Equal(t, options.Owner, status.Owner)
+		assert.Equal(t, lock.FencingToken, status.FencingToken)
+	})
+
+	t.Run("ListLocks", func(t *testing.T) {
+		t.Parallel()
+
+		options := newTestLockerOptions(t, lockTable)
+		_, err := locker.AcquireLock(context.Background(), options)
+		require.NoError(t, err)
+
+		locks, err := locker.ListLocks(context.Background(), options, LockFilter{})
+		require.NoError(t, err)
+		assert.Contains(t, lockIDs(locks), options.LockString)
+
+		require.NoError(t, locker.ReleaseLock(context.Background(), options))
+
+		locks, err = locker.ListLocks(context.Background(), options, LockFilter{})
+		require.NoError(t, err)
+		assert.NotContains(t, lockIDs(locks), options.LockString)
+	})
+
+	t.Run("SharedLocks", func(t *testing.T) {
+		t.Parallel()
+
+		reader := newTestLockerOptions(t, lockTable)
+		reader.Mode = SharedMode
+
+		otherReader := *reader
+		otherReader.Owner = "other-reader-" + random.UniqueId()
+
+		writer := *reader
+		writer.Owner = "writer-" + random.UniqueId()
+		writer.Mode = ExclusiveMode
+
+		lock, err := locker.AcquireLock(context.Background(), reader)
+		require.NoError(t, err)
+		assert.Equal(t, SharedMode, lock.Mode)
+		assert.Equal(t, reader.Owner, lock.Owner)
+
+		_, err = locker.AcquireLock(context.Background(), &otherReader)
+		require.NoError(t, err)
+
+		status, err := locker.GetLockStatus(context.Background(), reader)
+		require.NoError(t, err)
+		require.NotNil(t, status)
+		assert.Equal(t, SharedMode, status.Mode)
+
+		_, err = locker.AcquireLock(context.Background(), &writer)
+		assert.Error(t, err)
+
+		// Writers have to wait until all the readers are gone.
+		require.NoError(t, locker.ReleaseLock(context.Background(), reader))
+		_, err = locker.AcquireLock(context.Background(), &writer)
+		assert.Error(t, err)
+
+		require.NoError(t, locker.ReleaseLock(context.Background(), &otherReader))
+		_, err = locker.AcquireLock(context.Background(), &writer)
+		require.NoError(t, err)
+
+		// Readers have to wait until the writer is gone.
+		_, err = locker.AcquireLock(context.Background(), reader)
+		assert.Error(t, err)
+
+		require.NoError(t, locker.ReleaseLock(context.Background(), &writer))
+	})
+
+	t.Run("ReleaseSharedLockRequiresHolder", func(t *testing.T) {
+		t.Parallel()
+
+		options := newTestLockerOptions(t, lockTable)
+		options.Mode = SharedMode
+		_, err := locker.AcquireLock(context.Background(), options)
+		require.NoError(t, err)
+		defer locker.ReleaseLock(context.Background(), options)
+
+		otherOptions := *options
+		otherOptions.Owner = "other-owner-" + random.UniqueId()
+		err = locker.ReleaseLock(context.Background(), &otherOptions)
+		require.Error(t, err)
+		assert.IsType(t, LockNotHeldError{}, errors.Unwrap(err))
+	})
+
+	t.Run("Semaphore", func(t *testing.T) {
+		t.Parallel()
+
+		options := newTestLockerOptions(t, lockTable)
+		options.Mode = SemaphoreMode
+		options.Capacity = 2
+
+		_, err := locker.AcquireLock(context.Background(), options)
+		if _, isUnsupported := errors.Unwrap(err).(UnsupportedLockModeError); isUnsupported {
+			t.Skip("Semaphores are not supported by this backend")
+		}
+		require.NoError(t, err)
+
+		second := *options
+		second.Owner = "second-owner-" + random.UniqueId()
+		_, err = locker.AcquireLock(context.Background(), &second)
+		require.NoError(t, err)
+
+		third := *options
+		third.Owner = "third-owner-" + random.UniqueId()
+		_, err = locker.AcquireLock(context.Background(), &third)
+		assert.Error(t, err)
+
+		exclusive := *options
+		exclusive.Owner = "exclusive-owner-" + random.UniqueId()
+		exclusive.Mode = ExclusiveMode
+		_, err = locker.AcquireLock(context.Background(), &exclusive)
+		assert.Error(t, err)
+
+		status, err := locker.GetLockStatus(context.Background(), options)
+		require.NoError(t, err)
+		require.NotNil(t, status)
+		assert.Equal(t, SemaphoreMode, status.Mode)
+		assert.Len(t, status.Holders, 2)
+
+		// Releasing a slot frees it up for the next holder.
+		require.NoError(t, locker.ReleaseLock(context.Background(), options))
+		_, err = locker.AcquireLock(context.Background(), &third)
+		require.NoError(t, err)
+
+		require.NoError(t, locker.ReleaseLock(context.Background(), &second))
+		require.NoError(t, locker.ReleaseLock(context.Background(), &third))
+
+		status, err = locker.GetLockStatus(context.Background(), options)
+		require.NoError(t, err)
+		assert.Nil(t, status)
+	})
+
+	t.Run("ListLocksWithFilter", func(t *testing.T) {
+		t.Parallel()
+
+		prefix := "test-lock-prefix-" + random.UniqueId()
+
+		first := newTestLockerOptions(t, lockTable)
+		first.LockString = prefix + "-first"
+		second := newTestLockerOptions(t, lockTable)
+		second.LockString = prefix + "-second"
+		other := newTestLockerOptions(t, lockTable)
+
+		for _, options := range []*Options{first, second, other} {
+			_, err := locker.AcquireLock(context.Background(), options)
+			require.NoError(t, err)
+			defer locker.ReleaseLock(context.Background(), options)
+		}
+
+		locks, err := locker.ListLocks(context.Background(), first, LockFilter{IDPrefix: prefix})
+		require.NoError(t, err)
+		assert.Equal(t, []string{first.LockString, second.LockString}, lockIDs(locks))
+
+		locks, err = locker.ListLocks(context.Background(), first, LockFilter{IDPrefix: prefix, Owner: second.Owner})
+		require.NoError(t, err)
+		assert.Equal(t, []string{second.LockString}, lockIDs(locks))
+
+		locks, err = locker.ListLocks(context.Background(), first, LockFilter{Owner: other.Owner})
+		require.NoError(t, err)
+		require.Len(t, locks, 1)
+		assert.Equal(t, other.LockString, locks[0].ID)
+		assert.Equal(t, other.Owner, locks[0].Owner)
+		assert.GreaterOrEqual(t, locks[0].Age(time.Now()), time.Duration(0))
+	})
+
+	t.Run("FencingTokenIncreases", func(t *testing.T) {
+		t.Parallel()
+
+		options := newTestLockerOptions(t, lockTable)
+
+		first, err := locker.AcquireLock(context.Background(), options)
+		require.NoError(t, err)
+		require.NoError(t, locker.ReleaseLock(context.Background(), options))
+
+		otherOptions := *options
+		otherOptions.Owner = "other-owner-" + random.UniqueId()
+		second, err := locker.AcquireLock(context.Background(), &otherOptions)
+		require.NoError(t, err)
+		require.NoError(t, locker.ReleaseLock(context.Background(), &otherOptions))
+
+		assert.Greater(t, second.FencingToken, first.FencingToken)
+	})
+}
+
+func TestMemoryLockerSemaphoreSlotsExpire(t *testing.T) {
+	t.Parallel()
+
+	locker := NewMemoryLocker()
+	options := newTestLockerOptions(t, "test-memory-lock-table")
+	options.Mode = SemaphoreMode
+	options.Capacity = 1
+	options.LeaseDuration = 1 * time.Second
+
+	_, err := locker.AcquireLock(context.Background(), options)
+	require.NoError(t, err)
+
+	// Simulate a holder that crashed by stopping the heartbeat without releasing its slot.
+	stopHeartbeat(locker.heartbeatKey(options))
+	time.Sleep(options.LeaseDuration + 3*time.Second)
+
+	otherOptions := *options
+	otherOptions.Owner = "other-owner-" + random.UniqueId()
+	_, err = locker.AcquireLock(context.Background(), &otherOptions)
+	require.NoError(t, err)
+	assert.NoError(t, locker.ReleaseLock(context.Background(), &otherOptions))
+}
+
+func TestAcquireSemaphoreRequiresCapacity(t *testing.T) {
+	t.Parallel()
+
+	options := newTestLockerOptions(t, "test-memory-lock-table")
+	options.Mode = SemaphoreMode
+
+	_, err := NewMemoryLocker().AcquireLock(context.Background(), options)
+	require.Error(t, err)
+	assert.IsType(t, InvalidLockCapacityError{}, errors.Unwrap(err))
+}
+
+func TestAcquireLockRequiresValidLease(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name              string
+		leaseDuration     time.Duration
+		heartbeatInterval time.Duration
+	}{
+		{"lease too short for the default interval", 2 * time.Nanosecond, 0},
+		{"interval as long as the lease", 1 * time.Second, 1 * time.Second},
+		{"interval longer than the lease", 1 * time.Second, 2 * time.Second},
+	}
+
+	for _, testCase := range testCases {
+		// Store a copy in scope so all the test cases don't end up running the last item in the loop
+		testCase := testCase
+
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			options := newTestLockerOptions(t, "test-memory-lock-table")
+			options.LeaseDuration = testCase.leaseDuration
+			options.HeartbeatInterval = testCase.heartbeatInterval
+
+			_, err := NewMemoryLocker().AcquireLock(context.Background(), options)
+			require.Error(t, err)
+			assert.IsType(t, InvalidLeaseError{}, errors.Unwrap(err))
+		})
+	}
+}
+
+func newTestLockerOptions(t *testing.T, lockTable string) *Options {
+	return &Options{
+		AwsRegion:           "us-east-1",
+		LockTable:           lockTable,
+		LockString:          "test-lock-string-" + random.UniqueId(),
+		MaxRetries:          0,
+		SleepBetweenRetries: 1 * time.Second,
+		Logger:              logging.GetLogger(t.Name(), ""),
+		Owner:               "owner-" + random.UniqueId(),
+		Description:         t.Name(),
+	}
+}
+
+func lockIDs(locks []Lock) []string {
+	ids := []string{}
+	for _, lock := range locks {
+		ids = append(ids, lock.ID)
+	}
+	return ids
+}
@@ -0,0 +1,244 @@
+package lock
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gruntwork-io/go-commons/errors"
+)
+
+// MemoryLocker is a Locker that keeps locks in memory. Locks are only visible within the MemoryLocker they were
+// acquired from, which makes this backend useful for unit tests and for guarding critical sections within a single
+// process.
+type MemoryLocker struct {
+	mutex sync.Mutex
+	// The locks that are currently held, keyed by lock table and then by lock string
+	tables map[string]map[string]Lock
+	// The last fencing token handed out for each lock, keyed by lockKey
+	fencingTokens map[string]int64
+}
+
+// NewMemoryLocker returns a Locker that keeps locks in memory.
+func NewMemoryLocker() *MemoryLocker {
+	return &MemoryLocker{
+		tables:        map[string]map[string]Lock{},
+		fencingTokens: map[string]int64{},
+	}
+}
+
+// AcquireLock acquires the lock, retrying according to the options if it is held by someone else. If LeaseDuration is
+// set, the lock is kept alive by a background heartbeat until it is released.
+func (locker *MemoryLocker) AcquireLock(ctx context.Context, options *Options) (*Lock, error) {
+	lock, err := acquireLockWithRetries(ctx, options, "in-memory", func() (*Lock, error) {
+		return locker.acquireLock(options)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if options.LeaseDuration > 0 {
+		startHeartbeat(locker.heartbeatKey(options), options, func() error {
+			return locker.renewLease(options)
+		})
+	}
+	return lock, nil
+}
+
+func (locker *MemoryLocker) acquireLock(options *Options) (*Lock, error) {
+	locker.mutex.Lock()
+	defer locker.mutex.Unlock()
+
+	now := time.Now()
+	table := locker.table(options.LockTable)
+	key := locker.lockKey(options)
+
+	if lockMode(options).hasHolders() {
+		holder := newHolder(options, now)
+		holder.FencingToken = locker.fencingTokens[key] + 1
+		lock, err := addSharedHolder(locker.existingLock(table, options.LockString), options, holder, now)
+		if err != nil {
+			return nil, err
+		}
+
+		locker.fencingTokens[key]++
+		table[options.LockString] = lock
+		return lock.holderView(holder), nil
+	}
+
+	if existing, isHeld := table[options.LockString]; isHeld && !existing.IsExpired(now) {
+		return nil, errors.WithStackTrace(AlreadyLockedError{LockTable: options.LockTable, LockString: options.LockString})
+	}
+
+	locker.fencingTokens[key]++
+
+	lock := newLock(options, now)
+	lock.FencingToken = locker.fencingTokens[key]
+	table[options.LockString] = lock
+	return &lock, nil
+}
+
+func (locker *MemoryLocker) renewLease(options *Options) error {
+	locker.mutex.Lock()
+	defer locker.mutex.Unlock()
+
+	now := time.Now()
+	table := locker.table(options.LockTable)
+
+	if lockMode(options).hasHolders() {
+		lock, err := renewSharedHolder(locker.existingLock(table, options.LockString), options, now)
+		if err != nil {
+			return err
+		}
+		table[options.LockString] = lock
+		return nil
+	}
+
+	lock, isHeld := table[options.LockString]
+	if !isHeld || lock.Owner != lockOwner(options) {
+		return errors.WithStackTrace(LockNotHeldError{LockTable: options.LockTable, LockString: options.LockString, Owner: lockOwner(options)})
+	}
+
+	lock.ExpiresAt = leaseExpiry(now, options.LeaseDuration).Unix()
+	table[options.LockString] = lock
+	return nil
+}
+
+// ReleaseLock releases the lock. This returns a LockNotHeldError if the lock is not held by the owner in the options.
+func (locker *MemoryLocker) ReleaseLock(ctx context.Context, options *Options) error {
+	stopHeartbeat(locker.heartbeatKey(options))
+
+	locker.mutex.Lock()
+	defer locker.mutex.Unlock()
+
+	table := locker.table(options.LockTable)
+
+	if lockMode(options).hasHolders() {
+		lock, err := removeSharedHolder(locker.existingLock(table, options.LockString), options, time.Now())
+		if err != nil {
+			return err
+		}
+		if lock == nil {
+			delete(table, options.LockString)
+		} else {
+			table[options.LockString] = *lock
+		}
+		return nil
+	}
+
+	lock, isHeld := table[options.LockString]
+	if !isHeld || lock.Owner != lockOwner(options) {
+		return errors.WithStackTrace(LockNotHeldError{LockTable: options.LockTable, LockString: options.LockString, Owner: lockOwner(options)})
+	}
+
+	delete(table, options.LockString)
+	return nil
+}
+
+// ForceReleaseLock releases the lock, whoever holds it, as long as the given lock ID identifies its current holder.
+// For locks with several holders, only the holder with that ID is removed.
+func (locker *MemoryLocker) ForceReleaseLock(ctx context.Context, options *Options, lockID string) error {
+	locker.mutex.Lock()
+	defer locker.mutex.Unlock()
+
+	now := time.Now()
+	table := locker.table(options.LockTable)
+
+	existing := locker.existingLock(table, options.LockString)
+	if existing == nil || existing.IsExpired(now) {
+		return errors.WithStackTrace(LockNotHeldError{LockTable: options.LockTable, LockString: options.LockString, Owner: lockID})
+	}
+	if !existing.matchesLockID(lockID) {
+		return errors.WithStackTrace(LockIDMismatchError{LockTable: options.LockTable, LockString: options.LockString, LockID: lockID})
+	}
+
+	if _, isHolder := existing.sharedHolder(lockID); isHolder {
+		holderOptions := *options
+		holderOptions.Owner = lockID
+		lock, err := removeSharedHolder(existing, &holderOptions, now)
+		if err != nil {
+			return err
+		}
+		if lock != nil {
+			table[options.LockString] = *lock
+			return nil
+		}
+	}
+
+	delete(table, options.LockString)
+	return nil
+}
+
+// GetLockStatus returns the record of the lock, or nil if the lock is not held.
+func (locker *MemoryLocker) GetLockStatus(ctx context.Context, options *Options) (*Lock, error) {
+	locker.mutex.Lock()
+	defer locker.mutex.Unlock()
+
+	now := time.Now()
+	lock, isHeld := locker.table(options.LockTable)[options.LockString]
+	if !isHeld || lock.IsExpired(now) {
+		return nil, nil
+	}
+
+	lock = lock.copyHolders()
+	lock.dropExpiredHolders(now)
+	return &lock, nil
+}
+
+// ListLocks returns the records of the locks held in the lock table that are selected by the given filter, sorted by
+// ID.
+func (locker *MemoryLocker) ListLocks(ctx context.Context, options *Options, filter LockFilter) ([]Lock, error) {
+	locker.mutex.Lock()
+	defer locker.mutex.Unlock()
+
+	now := time.Now()
+	locks := []Lock{}
+	for _, lock := range locker.table(options.LockTable) {
+		if lock.IsExpired(now) {
+			continue
+		}
+		lock = lock.copyHolders()
+		lock.dropExpiredHolders(now)
+		if filter.Matches(lock) {
+			locks = append(locks, lock)
+		}
+	}
+
+	sort.Slice(locks, func(i, j int) bool { return locks[i].ID < locks[j].ID })
+	return locks, nil
+}
+
+// table returns the locks held in the given lock table, creating the table if necessary. The caller must hold the
+// mutex.
+func (locker *MemoryLocker) table(lockTable string) map[string]Lock {
+	table, exists := locker.tables[lockTable]
+	if !exists {
+		table = map[string]Lock{}
+		locker.tables[lockTable] = table
+	}
+	return table
+}
+
+// existingLock returns the record of the given lock in the given table, or nil if the lock isn't held. The caller must
+// hold the mutex.
+func (locker *MemoryLocker) existingLock(table map[string]Lock, lockString string) *Lock {
+	lock, isHeld := table[lockString]
+	if !isHeld {
+		return nil
+	}
+	return &lock
+}
+
+// lockKey returns a key that identifies the lock described by the given options across all the MemoryLockers in this
+// process.
+func (locker *MemoryLocker) lockKey(options *Options) string {
+	return fmt.Sprintf("memory/%p/%s/%s", locker, options.LockTable, options.LockString)
+}
+
+// heartbeatKey returns the key of the heartbeat that renews the lock described by the given options on behalf of its
+// owner.
+func (locker *MemoryLocker) heartbeatKey(options *Options) string {
+	return locker.lockKey(options) + "/" + lockOwner(options)
+}
@@ -0,0 +1,357 @@
+package lock
+
+import (
+	"context"
+	goerrors "errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	multierror "github.com/hashicorp/go-multierror"
+
+	"github.com/gruntwork-io/go-commons/errors"
+)
+
+// MultiRegionLocker is a Locker that holds the same lock in several regions, for resources that are global or span
+// multiple regions. A lock counts as held once it has been acquired in a quorum of the regions: a majority of them by
+// default, or all of them if RequireAll is set. If the quorum can't be reached, the acquisitions that did succeed are
+// rolled back. This keeps the lock available while a minority of the regions are unreachable, as any two majorities
+// of the regions overlap in at least one region.
+//
+// The lock is acquired in each region through the Locker of that region, with the AwsRegion of the options set to the
+// region. As each region needs its own lock table, Options.DynamoDBClient can't be used with a MultiRegionLocker: set
+// DynamoDBClients to inject a client for each region instead. The fencing token of a multi-region lock is the highest
+// of the fencing tokens of the regions in which it was acquired.
+type MultiRegionLocker struct {
+	// The Locker to use in each region, keyed by region.
+	Lockers map[string]Locker
+	// Whether the lock must be acquired in all the regions, rather than in a majority of them.
+	RequireAll bool
+	// The DynamoDB client to use in each region, keyed by region, e.g. to point each region at its own DynamoDB Local
+	// endpoint. Regions without a client use the AWS config of the options.
+	DynamoDBClients map[string]DynamoDBAPI
+}
+
+// NewMultiRegionLocker returns a Locker that holds locks in the DynamoDB lock tables of the given regions.
+func NewMultiRegionLocker(regions []string, requireAll bool) *MultiRegionLocker {
+	lockers := map[string]Locker{}
+	for _, region := range regions {
+		lockers[region] = NewDynamoDBLocker()
+	}
+	return &MultiRegionLocker{Lockers: lockers, RequireAll: requireAll}
+}
+
+// QuorumNotReachedError is returned when a lock could not be acquired in enough regions.
+type QuorumNotReachedError struct {
+	LockString string
+	Acquired   int
+	Required   int
+	// The errors that prevented acquiring the lock, keyed by region
+	Errors map[string]error
+}
+
+func (err QuorumNotReachedError) Error() string {
+	regionErrs := &multierror.Error{}
+	for _, region := range sortedKeys(err.Errors) {
+		regionErrs = multierror.Append(regionErrs, fmt.Errorf("%s: %s", region, err.Errors[region]))
+	}
+	return fmt.Sprintf("Acquired lock %s in %d regions, but %d were required: %s", err.LockString, err.Acquired, err.Required, regionErrs)
+}
+
+// Unwrap returns the errors of the regions, so that errors.Is and errors.As can inspect them.
+func (err QuorumNotReachedError) Unwrap() []error {
+	errs := []error{}
+	for _, region := range sortedKeys(err.Errors) {
+		errs = append(errs, err.Errors[region])
+	}
+	return errs
+}
+
+// SharedDynamoDBClientError is returned when Options.DynamoDBClient is set on the options of a MultiRegionLocker, which
+// would make all the regions use the same lock table.
+type SharedDynamoDBClientError struct {
+	LockString string
+}
+
+func (err SharedDynamoDBClientError) Error() string {
+	return fmt.Sprintf("Can't use the same DynamoDB client in all the regions of multi-region lock %s: set MultiRegionLocker.DynamoDBClients instead of Options.DynamoDBClient\n", err.LockString)
+}
+
+// AcquireLock acquires the lock in all the regions at the same time, retrying in each region according to the
+// options. Retrying stops in all the regions as soon as the lock has been acquired in a quorum of them, or can no
+// longer be, but the lock is always tried at least once in each region. If the lock can't be acquired in a quorum of the regions, it is released in the regions where it was
+// acquired, and this returns a QuorumNotReachedError.
+func (locker *MultiRegionLocker) AcquireLock(ctx context.Context, options *Options) (*Lock, error) {
+	if err := locker.checkOptions(options); err != nil {
+		return nil, err
+	}
+
+	retryCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	locks := map[string]*Lock{}
+	failures := 0
+	locksMutex := sync.Mutex{}
+
+	errs := locker.forEachRegion(options, func(region string, regionLocker Locker, regionOptions *Options) error {
+		lock, err := acquireLockInRegion(ctx, retryCtx, regionLocker, regionOptions)
+
+		locksMutex.Lock()
+		defer locksMutex.Unlock()
+		if err != nil {
+			failures++
+		} else {
+			locks[region] = lock
+		}
+
+		// Stop waiting for the other regions once their outcome no longer matters.
+		if len(locks) >= locker.quorum() || failures > len(locker.Lockers)-locker.quorum() {
+			cancel()
+		}
+		return err
+	})
+
+	if len(locks) < locker.quorum() {
+		options.Logger.Errorf("Acquired lock %s in %d of the %d required regions, rolling back\n", options.LockString, len(locks), locker.quorum())
+		// Roll back even if the context was cancelled, as that is one of the reasons to get here. The regions in which
+		// acquiring the lock was cancelled are rolled back too, as it may have been cancelled after taking the lock.
+		rollbackRegions := sortedKeys(locks)
+		for region, err := range errs {
+			if goerrors.Is(err, context.Canceled) || goerrors.Is(err, context.DeadlineExceeded) {
+				rollbackRegions = append(rollbackRegions, region)
+			}
+		}
+		locker.rollback(context.WithoutCancel(ctx), options, rollbackRegions)
+		return nil, errors.WithStackTrace(QuorumNotReachedError{LockString: options.LockString, Acquired: len(locks), Required: locker.quorum(), Errors: errs})
+	}
+
+	var lock *Lock
+	for _, region := range sortedKeys(locks) {
+		if lock == nil {
+			lock = locks[region]
+		}
+		if locks[region].FencingToken > lock.FencingToken {
+			lock.FencingToken = locks[region].FencingToken
+		}
+	}
+	return lock, nil
+}
+
+// acquireLockInRegion acquires the lock with the given Locker of a region. The first attempt isn't cut short by the
+// given retry context, so that the lock is always taken in the regions where it is free, like it is by the other
+// holders. Otherwise, different holders could each hold a quorum of the slots of a semaphore, made of different
+// regions. Retrying stops as soon as the retry context is done.
+func acquireLockInRegion(ctx context.Context, retryCtx context.Context, regionLocker Locker, regionOptions *Options) (*Lock, error) {
+	firstAttemptOptions := *regionOptions
+	firstAttemptOptions.MaxRetries = 0
+	lock, err := regionLocker.AcquireLock(ctx, &firstAttemptOptions)
+	if err == nil || regionOptions.MaxRetries <= 0 {
+		return lock, err
+	}
+
+	select {
+	case <-retryCtx.Done():
+		// Report why the first attempt failed, unless the caller gave up on the lock.
+		if ctx.Err() != nil {
+			return nil, errors.WithStackTrace(ctx.Err())
+		}
+		return nil, err
+	case <-time.After(regionOptions.SleepBetweenRetries):
+	}
+
+	retryOptions := *regionOptions
+	retryOptions.MaxRetries--
+	return regionLocker.AcquireLock(retryCtx, &retryOptions)
+}
+
+// rollback releases the lock in the given regions, skipping those where it turns out not to be held. Failures are only
+// logged, as the leases (if any) will eventually release the lock anyway.
+func (locker *MultiRegionLocker) rollback(ctx context.Context, options *Options, regions []string) {
+	for _, region := range regions {
+		regionOptions := locker.regionOptions(options, region)
+		err := locker.Lockers[region].ReleaseLock(ctx, regionOptions)
+		if _, isNotHeld := errors.Unwrap(err).(LockNotHeldError); err != nil && !isNotHeld {
+			options.Logger.Errorf("Error rolling back lock %s in region %s: %s\n", options.LockString, region, err)
+		}
+	}
+}
+
+// ReleaseLock releases the lock in all the regions. Regions in which the lock is not held (e.g. because acquiring it
+// failed there) are skipped, but this returns a LockNotHeldError if the lock isn't held in any region.
+func (locker *MultiRegionLocker) ReleaseLock(ctx context.Context, options *Options) error {
+	if err := locker.checkOptions(options); err != nil {
+		return err
+	}
+
+	errs := locker.forEachRegion(options, func(region string, regionLocker Locker, regionOptions *Options) error {
+		return regionLocker.ReleaseLock(ctx, regionOptions)
+	})
+
+	allErrs := &multierror.Error{}
+	notHeldCount := 0
+	for _, region := range sortedKeys(errs) {
+		if _, isNotHeld := errors.Unwrap(errs[region]).(LockNotHeldError); isNotHeld {
+			notHeldCount++
+			continue
+		}
+		allErrs = multierror.Append(allErrs, errs[region])
+	}
+
+	if notHeldCount == len(locker.Lockers) {
+		return errors.WithStackTrace(LockNotHeldError{LockTable: options.LockTable, LockString: options.LockString, Owner: lockOwner(options)})
+	}
+	return errors.WithStackTrace(allErrs.ErrorOrNil())
+}
+
+// GetLockStatus returns the record of the lock if it is held in a quorum of the regions, or nil if it isn't. Regions
+// that can't be reached are tolerated, as long as they can't change the outcome.
+func (locker *MultiRegionLocker) GetLockStatus(ctx context.Context, options *Options) (*Lock, error) {
+	if err := locker.checkOptions(options); err != nil {
+		return nil, err
+	}
+
+	statuses := map[string]*Lock{}
+	statusesMutex := sync.Mutex{}
+
+	errs := locker.forEachRegion(options, func(region string, regionLocker Locker, regionOptions *Options) error {
+		status, err := regionLocker.GetLockStatus(ctx, regionOptions)
+		if err != nil || status == nil {
+			return err
+		}
+
+		statusesMutex.Lock()
+		defer statusesMutex.Unlock()
+		statuses[region] = status
+		return nil
+	})
+
+	if len(statuses) >= locker.quorum() {
+		return statuses[sortedKeys(statuses)[0]], nil
+	}
+	if len(statuses)+len(errs) >= locker.quorum() {
+		regionErrs := &multierror.Error{}
+		for _, region := range sortedKeys(errs) {
+			regionErrs = multierror.Append(regionErrs, errs[region])
+		}
+		return nil, errors.WithStackTrace(regionErrs)
+	}
+	return nil, nil
+}
+
+// ListLocks returns the records of the locks that are held in a quorum of the regions and selected by the given
+// filter, sorted by ID.
+func (locker *MultiRegionLocker) ListLocks(ctx context.Context, options *Options, filter LockFilter) ([]Lock, error) {
+	if err := locker.checkOptions(options); err != nil {
+		return nil, err
+	}
+
+	counts := map[string]int{}
+	records := map[string]Lock{}
+	resultsMutex := sync.Mutex{}
+
+	errs := locker.forEachRegion(options, func(region string, regionLocker Locker, regionOptions *Options) error {
+		locks, err := regionLocker.ListLocks(ctx, regionOptions, filter)
+		if err != nil {
+			return err
+		}
+
+		resultsMutex.Lock()
+		defer resultsMutex.Unlock()
+		for _, lock := range locks {
+			counts[lock.ID]++
+			if _, hasRecord := records[lock.ID]; !hasRecord {
+				records[lock.ID] = lock
+			}
+		}
+		return nil
+	})
+	if len(errs) > 0 {
+		regionErrs := &multierror.Error{}
+		for _, region := range sortedKeys(errs) {
+			regionErrs = multierror.Append(regionErrs, errs[region])
+		}
+		return nil, errors.WithStackTrace(regionErrs)
+	}
+
+	locks := []Lock{}
+	for id, count := range counts {
+		if count >= locker.quorum() {
+			locks = append(locks, records[id])
+		}
+	}
+	sort.Slice(locks, func(i, j int) bool { return locks[i].ID < locks[j].ID })
+	return locks, nil
+}
+
+// quorum returns the number of regions in which a lock must be held.
+func (locker *MultiRegionLocker) quorum() int {
+	if locker.RequireAll {
+		return len(locker.Lockers)
+	}
+	return len(locker.Lockers)/2 + 1
+}
+
+// forEachRegion calls the given function for each region at the same time, with the Locker and options of the region,
+// and returns the errors it returned, keyed by region.
+func (locker *MultiRegionLocker) forEachRegion(options *Options, action func(region string, regionLocker Locker, regionOptions *Options) error) map[string]error {
+	errs := map[string]error{}
+	errsMutex := sync.Mutex{}
+	waitGroup := sync.WaitGroup{}
+
+	for region, regionLocker := range locker.Lockers {
+		waitGroup.Add(1)
+		go func(region string, regionLocker Locker) {
+			defer waitGroup.Done()
+
+			if err := action(region, regionLocker, locker.regionOptions(options, region)); err != nil {
+				errsMutex.Lock()
+				defer errsMutex.Unlock()
+				errs[region] = err
+			}
+		}(region, regionLocker)
+	}
+
+	waitGroup.Wait()
+	return errs
+}
+
+// checkOptions returns a SharedDynamoDBClientError if the given options would make all the regions use the same
+// DynamoDB client.
+func (locker *MultiRegionLocker) checkOptions(options *Options) error {
+	if options.DynamoDBClient != nil && len(locker.Lockers) > 1 {
+		return errors.WithStackTrace(SharedDynamoDBClientError{LockString: options.LockString})
+	}
+	return nil
+}
+
+// regionOptions returns a copy of the given options for the given region, using the DynamoDB client of the region, if
+// there is one.
+func (locker *MultiRegionLocker) regionOptions(options *Options, region string) *Options {
+	regionOptions := regionOptions(options, region)
+	if client, hasClient := locker.DynamoDBClients[region]; hasClient {
+		regionOptions.DynamoDBClient = client
+	}
+	return regionOptions
+}
+
+// regionOptions returns a copy of the given options for the given region.
+func regionOptions(options *Options, region string) *Options {
+	regionOptions := *options
+	regionOptions.AwsRegion = region
+	if options.AwsConfig != nil {
+		awsConfig := options.AwsConfig.Copy()
+		awsConfig.Region = region
+		regionOptions.AwsConfig = &awsConfig
+	}
+	return &regionOptions
+}
+
+func sortedKeys[V any](values map[string]V) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,177 @@
+package lock
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gruntwork-io/go-commons/errors"
+)
+
+func TestMultiRegionLocker(t *testing.T) {
+	t.Parallel()
+
+	testLockerConformance(t, newTestMultiRegionLocker(false), "test-multi-region-lock-table")
+}
+
+func TestMultiRegionLockerAcquiresLockInMajorityOfRegions(t *testing.T) {
+	t.Parallel()
+
+	locker := newTestMultiRegionLocker(false)
+	options := newTestLockerOptions(t, "test-multi-region-lock-table")
+	holdLockInRegion(t, locker, "us-west-2", options)
+
+	lock, err := locker.AcquireLock(context.Background(), options)
+	require.NoError(t, err)
+	assert.Equal(t, options.Owner, lock.Owner)
+
+	status, err := locker.GetLockStatus(context.Background(), options)
+	require.NoError(t, err)
+	require.NotNil(t, status)
+	assert.Equal(t, options.Owner, status.Owner)
+
+	require.NoError(t, locker.ReleaseLock(context.Background(), options))
+	assertLockNotHeldInRegion(t, locker, "us-east-1", options)
+	assertLockNotHeldInRegion(t, locker, "eu-west-1", options)
+}
+
+func TestMultiRegionLockerRollsBackWithoutQuorum(t *testing.T) {
+	t.Parallel()
+
+	locker := newTestMultiRegionLocker(false)
+	options := newTestLockerOptions(t, "test-multi-region-lock-table")
+	holdLockInRegion(t, locker, "us-west-2", options)
+	holdLockInRegion(t, locker, "eu-west-1", options)
+
+	_, err := locker.AcquireLock(context.Background(), options)
+	require.Error(t, err)
+	quorumErr, isQuorumErr := errors.Unwrap(err).(QuorumNotReachedError)
+	require.True(t, isQuorumErr, "expected a QuorumNotReachedError, got %v", err)
+	assert.Equal(t, 1, quorumErr.Acquired)
+	assert.Equal(t, 2, quorumErr.Required)
+	assert.Contains(t, quorumErr.Errors, "us-west-2")
+	assert.Contains(t, quorumErr.Errors, "eu-west-1")
+
+	assertLockNotHeldInRegion(t, locker, "us-east-1", options)
+}
+
+func TestMultiRegionLockerRequireAll(t *testing.T) {
+	t.Parallel()
+
+	locker := newTestMultiRegionLocker(true)
+	options := newTestLockerOptions(t, "test-multi-region-lock-table")
+	holdLockInRegion(t, locker, "us-west-2", options)
+
+	_, err := locker.AcquireLock(context.Background(), options)
+	require.Error(t, err)
+	assert.IsType(t, QuorumNotReachedError{}, errors.Unwrap(err))
+
+	assertLockNotHeldInRegion(t, locker, "us-east-1", options)
+	assertLockNotHeldInRegion(t, locker, "eu-west-1", options)
+}
+
+func TestMultiRegionLockerStopsRetryingOnceQuorumIsReached(t *testing.T) {
+	t.Parallel()
+
+	locker := newTestMultiRegionLocker(false)
+	options := newTestLockerOptions(t, "test-multi-region-lock-table")
+	options.MaxRetries = 60
+	holdLockInRegion(t, locker, "us-west-2", options)
+
+	start := time.Now()
+	_, err := locker.AcquireLock(context.Background(), options)
+	require.NoError(t, err)
+	assert.Less(t, time.Since(start), 10*time.Second)
+
+	require.NoError(t, locker.ReleaseLock(context.Background(), options))
+}
+
+func TestMultiRegionLockerStopsRetryingOnceQuorumIsImpossible(t *testing.T) {
+	t.Parallel()
+
+	locker := newTestMultiRegionLocker(false)
+	locker.Lockers["us-east-1"] = unreachableLocker{}
+	locker.Lockers["eu-west-1"] = unreachableLocker{}
+	options := newTestLockerOptions(t, "test-multi-region-lock-table")
+	options.MaxRetries = 60
+	holdLockInRegion(t, locker, "us-west-2", options)
+
+	start := time.Now()
+	_, err := locker.AcquireLock(context.Background(), options)
+	require.Error(t, err)
+	assert.IsType(t, QuorumNotReachedError{}, errors.Unwrap(err))
+	assert.Less(t, time.Since(start), 10*time.Second)
+}
+
+func TestMultiRegionLockerRejectsSharedDynamoDBClient(t *testing.T) {
+	t.Parallel()
+
+	locker := NewMultiRegionLocker([]string{"us-east-1", "us-west-2"}, false)
+	options := newTestLockerOptions(t, "test-multi-region-lock-table")
+	options.DynamoDBClient = &alreadyLockedDynamoDBClient{}
+
+	_, err := locker.AcquireLock(context.Background(), options)
+	require.Error(t, err)
+	assert.IsType(t, SharedDynamoDBClientError{}, errors.Unwrap(err))
+}
+
+func TestMultiRegionLockerUsesDynamoDBClientOfEachRegion(t *testing.T) {
+	t.Parallel()
+
+	options := newTestLockerOptions(t, "test-multi-region-lock-table")
+	locker := NewMultiRegionLocker([]string{"us-east-1", "us-west-2"}, true)
+	locker.DynamoDBClients = map[string]DynamoDBAPI{
+		"us-east-1": &getItemDynamoDBClient{item: lockKey(options.LockString)},
+		"us-west-2": &getItemDynamoDBClient{},
+	}
+
+	// The lock is only held in one of the two regions.
+	status, err := locker.GetLockStatus(context.Background(), options)
+	require.NoError(t, err)
+	assert.Nil(t, status)
+
+	locker.DynamoDBClients["us-west-2"] = &getItemDynamoDBClient{item: lockKey(options.LockString)}
+	status, err = locker.GetLockStatus(context.Background(), options)
+	require.NoError(t, err)
+	require.NotNil(t, status)
+	assert.Equal(t, options.LockString, status.ID)
+}
+
+func newTestMultiRegionLocker(requireAll bool) *MultiRegionLocker {
+	return &MultiRegionLocker{
+		Lockers: map[string]Locker{
+			"us-east-1": NewMemoryLocker(),
+			"us-west-2": NewMemoryLocker(),
+			"eu-west-1": NewMemoryLocker(),
+		},
+		RequireAll: requireAll,
+	}
+}
+
+// unreachableLocker is a Locker for a region that can't be reached, which fails right away. Calling any method that
+// isn't overridden panics.
+type unreachableLocker struct {
+	Locker
+}
+
+func (locker unreachableLocker) AcquireLock(ctx context.Context, options *Options) (*Lock, error) {
+	return nil, fmt.Errorf("region %s is unreachable", options.AwsRegion)
+}
+
+// holdLockInRegion acquires the lock described by the given options for another owner, in the given region only.
+func holdLockInRegion(t *testing.T, locker *MultiRegionLocker, region string, options *Options) {
+	otherOptions := regionOptions(options, region)
+	otherOptions.Owner = "other-" + options.Owner
+	_, err := locker.Lockers[region].AcquireLock(context.Background(), otherOptions)
+	require.NoError(t, err)
+}
+
+func assertLockNotHeldInRegion(t *testing.T, locker *MultiRegionLocker, region string, options *Options) {
+	status, err := locker.Lockers[region].GetLockStatus(context.Background(), regionOptions(options, region))
+	require.NoError(t, err)
+	assert.Nil(t, status, "lock should not be held in region %s", region)
+}
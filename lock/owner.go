@@ -0,0 +1,112 @@
+package lock
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/google/uuid"
+
+	"github.com/gruntwork-io/go-commons/errors"
+)
+
+// The suffix of the key of the item that holds the fencing token counter of a lock. The counter lives in its own item
+// because the lock item is deleted when the lock is released, and fencing tokens must keep increasing across
+// acquisitions.
+const fencingTokenKeySuffix = "::fencing-token"
+
+var (
+	// The name of the host this process runs on, recorded on the locks it acquires.
+	hostname = getHostname()
+
+	// The owner of locks acquired by this process when Options.Owner is not set. This includes a random component, as
+	// hostnames and PIDs are routinely reused across containers.
+	defaultOwner = fmt.Sprintf("%s-%d-%s", hostname, os.Getpid(), uuid.NewString())
+)
+
+// lockOwner returns the owner to record on and check against the lock described by the given options.
+func lockOwner(options *Options) string {
+	if options.Owner != "" {
+		return options.Owner
+	}
+	return defaultOwner
+}
+
+func getHostname() string {
+	name, err := os.Hostname()
+	if err != nil {
+		return "unknown"
+	}
+	return name
+}
+
+// assignFencingToken increments the fencing token counter of the lock described by the given options and records the
+// new value on the lock item. Recording the token is conditional on the lock still being held by the owner, so a
+// holder that lost the lock while getting a token fails instead of carrying a token newer than the actual holder's.
+func assignFencingToken(ctx context.Context, options *Options, client DynamoDBAPI) (int64, error) {
+	fencingToken, err := nextFencingToken(ctx, options, client)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		Key:                 lockKey(options.LockString),
+		TableName:           aws.String(options.LockTable),
+		UpdateExpression:    aws.String("SET #fencingToken = :fencingToken"),
+		ConditionExpression: aws.String("#owner = :owner"),
+		ExpressionAttributeNames: map[string]string{
+			"#owner":        attributeOwner,
+			"#fencingToken": attributeFencingToken,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":owner":        &types.AttributeValueMemberS{Value: lockOwner(options)},
+			":fencingToken": numberAttribute(fencingToken),
+		},
+	})
+	if isConditionalCheckFailedError(err) {
+		return 0, errors.WithStackTrace(LockNotHeldError{LockTable: options.LockTable, LockString: options.LockString, Owner: lockOwner(options)})
+	}
+	if err != nil {
+		return 0, errors.WithStackTrace(err)
+	}
+	return fencingToken, nil
+}
+
+// nextFencingToken increments the fencing token counter of the lock described by the given options and returns the
+// new value.
+func nextFencingToken(ctx context.Context, options *Options, client DynamoDBAPI) (int64, error) {
+	counter, err := client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		Key:                       lockKey(fencingTokenKey(options.LockString)),
+		TableName:                 aws.String(options.LockTable),
+		UpdateExpression:          aws.String("ADD #fencingToken :one"),
+		ExpressionAttributeNames:  map[string]string{"#fencingToken": attributeFencingToken},
+		ExpressionAttributeValues: map[string]types.AttributeValue{":one": numberAttribute(1)},
+		ReturnValues:              types.ReturnValueUpdatedNew,
+	})
+	if err != nil {
+		return 0, errors.WithStackTrace(err)
+	}
+
+	counterValue, isNumber := counter.Attributes[attributeFencingToken].(*types.AttributeValueMemberN)
+	if !isNumber {
+		return 0, errors.WithStackTrace(fmt.Errorf("fencing token counter of lock %s in table %s is not a number", options.LockString, options.LockTable))
+	}
+	fencingToken, err := strconv.ParseInt(counterValue.Value, 10, 64)
+	if err != nil {
+		return 0, errors.WithStackTrace(err)
+	}
+	return fencingToken, nil
+}
+
+func fencingTokenKey(lockString string) string {
+	return lockString + fencingTokenKeySuffix
+}
+
+func isFencingTokenKey(lockID string) bool {
+	return strings.HasSuffix(lockID, fencingTokenKeySuffix)
+}
@@ -0,0 +1,46 @@
+package lock
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// WithLock acquires the DynamoDB lock described by the given options, runs the given function while holding it, and
+// then releases the lock. See WithLockerLock for details.
+func WithLock(ctx context.Context, options *Options, action func(ctx context.Context) error) error {
+	return WithLockerLock(ctx, NewDynamoDBLocker(), options, action)
+}
+
+// WithLockerLock acquires the lock described by the given options from the given Locker, runs the given function while
+// holding it, and then releases the lock. The lock is released whether the function returns an error or panics, in
+// which case the panic is propagated after the lock has been released.
+//
+// If the process receives SIGINT or SIGTERM while waiting for the lock or running the function, the context passed to
+// the function is cancelled, and the lock is released once the function returns. The function should therefore stop
+// as soon as its context is done. The error returned by the function takes precedence over the error releasing the
+// lock, which is then only logged.
+func WithLockerLock(ctx context.Context, locker Locker, options *Options, action func(ctx context.Context) error) (err error) {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if _, err := locker.AcquireLock(ctx, options); err != nil {
+		return err
+	}
+
+	defer func() {
+		// Release the lock even if the context has been cancelled, as that is the main reason for getting here early.
+		releaseErr := locker.ReleaseLock(context.WithoutCancel(ctx), options)
+		if releaseErr == nil {
+			return
+		}
+		if err != nil {
+			options.Logger.Errorf("Error releasing lock %s in table %s: %s\n", options.LockString, options.LockTable, releaseErr)
+			return
+		}
+		err = releaseErr
+	}()
+
+	return action(ctx)
+}
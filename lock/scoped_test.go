@@ -0,0 +1,79 @@
+package lock
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithLockerLockReleasesLock(t *testing.T) {
+	t.Parallel()
+
+	locker := NewMemoryLocker()
+	options := newTestLockerOptions(t, "test-memory-lock-table")
+
+	err := WithLockerLock(context.Background(), locker, options, func(ctx context.Context) error {
+		status, err := locker.GetLockStatus(ctx, options)
+		require.NoError(t, err)
+		assert.NotNil(t, status)
+		return nil
+	})
+	require.NoError(t, err)
+	assertMemoryLockReleased(t, locker, options)
+}
+
+func TestWithLockerLockReleasesLockOnError(t *testing.T) {
+	t.Parallel()
+
+	locker := NewMemoryLocker()
+	options := newTestLockerOptions(t, "test-memory-lock-table")
+	expectedErr := fmt.Errorf("expected error")
+
+	err := WithLockerLock(context.Background(), locker, options, func(ctx context.Context) error {
+		return expectedErr
+	})
+	assert.Equal(t, expectedErr, err)
+	assertMemoryLockReleased(t, locker, options)
+}
+
+func TestWithLockerLockReleasesLockOnPanic(t *testing.T) {
+	t.Parallel()
+
+	locker := NewMemoryLocker()
+	options := newTestLockerOptions(t, "test-memory-lock-table")
+
+	assert.PanicsWithValue(t, "expected panic", func() {
+		WithLockerLock(context.Background(), locker, options, func(ctx context.Context) error {
+			panic("expected panic")
+		})
+	})
+	assertMemoryLockReleased(t, locker, options)
+}
+
+func TestWithLockerLockDoesNotRunActionWhenLockIsHeld(t *testing.T) {
+	t.Parallel()
+
+	locker := NewMemoryLocker()
+	options := newTestLockerOptions(t, "test-memory-lock-table")
+	_, err := locker.AcquireLock(context.Background(), options)
+	require.NoError(t, err)
+	defer locker.ReleaseLock(context.Background(), options)
+
+	otherOptions := *options
+	otherOptions.Owner = "other-owner"
+
+	err = WithLockerLock(context.Background(), locker, &otherOptions, func(ctx context.Context) error {
+		t.Fatal("action must not run without the lock")
+		return nil
+	})
+	assert.Error(t, err)
+}
+
+func assertMemoryLockReleased(t *testing.T, locker *MemoryLocker, options *Options) {
+	status, err := locker.GetLockStatus(context.Background(), options)
+	require.NoError(t, err)
+	assert.Nil(t, status)
+}
@@ -0,0 +1,145 @@
+package lock
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	awsv1 "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	dynamodbv1 "github.com/aws/aws-sdk-go/service/dynamodb"
+
+	"github.com/gruntwork-io/go-commons/errors"
+)
+
+// This file has the entry points of the lock package that use aws-sdk-go v1. They are kept for backward compatibility
+// only: the locks themselves are read and written with aws-sdk-go-v2.
+
+// NewAuthenticatedSession gets an AWS Session, checking that the user has credentials properly configured in their environment
+//
+// Deprecated: The lock package uses aws-sdk-go-v2. Set Options.AwsConfig, or Options.DynamoDBClient, instead of
+// Options.AwsSession.
+func NewAuthenticatedSession(awsRegion string) (*session.Session, error) {
+	sessionOptions := session.Options{
+		SharedConfigState: session.SharedConfigEnable,
+		Config:            *awsv1.NewConfig().WithRegion(awsRegion),
+	}
+	sess, err := session.NewSessionWithOptions(sessionOptions)
+
+	if err != nil {
+		return nil, errors.WithStackTrace(err)
+	}
+
+	if _, err = sess.Config.Credentials.Get(); err != nil {
+		return nil, errors.WithStackTrace(err)
+	}
+
+	return sess, nil
+}
+
+// NewDynamoDb returns an authenticated client object for accessing DynamoDb
+//
+// Deprecated: Use NewDynamoDBClient, which returns an aws-sdk-go-v2 client.
+func NewDynamoDb(awsRegion string) (*dynamodbv1.DynamoDB, error) {
+	sess, err := NewAuthenticatedSession(awsRegion)
+	if err != nil {
+		return nil, err
+	}
+	dynamodbSvc := dynamodbv1.New(sess)
+	return dynamodbSvc, nil
+}
+
+// GetLockStatus attempts to acquire the lock and check if the expected item is there
+// If there's the expected Item with the correct `LockString` value - then the status is `locked`, if the item is not there - then the status is `not locked`
+//
+// Deprecated: Use GetLockStatusV2, which returns the aws-sdk-go-v2 output, or DynamoDBLocker.GetLockStatus, which
+// returns the decoded Lock.
+func GetLockStatus(options *Options) (*dynamodbv1.GetItemOutput, error) {
+	output, err := GetLockStatusV2(options)
+	if err != nil {
+		return nil, err
+	}
+
+	item := map[string]*dynamodbv1.AttributeValue{}
+	for name, value := range output.Item {
+		item[name] = toV1AttributeValue(value)
+	}
+	return &dynamodbv1.GetItemOutput{Item: item}, nil
+}
+
+// awsConfigFromSession returns an aws-sdk-go-v2 config with the region, credentials, endpoint, HTTP client and maximum
+// number of retries of the given aws-sdk-go v1 session, so that locks configured with the deprecated
+// Options.AwsSession keep working, including against DynamoDB Local. Custom retryers and request handlers of the
+// session can't be carried over.
+func awsConfigFromSession(sess *session.Session) aws.Config {
+	config := aws.Config{
+		Region: awsv1.StringValue(sess.Config.Region),
+		Credentials: aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+			// The v1 credentials cache themselves until they expire.
+			value, err := sess.Config.Credentials.GetWithContext(ctx)
+			if err != nil {
+				return aws.Credentials{}, errors.WithStackTrace(err)
+			}
+
+			credentials := aws.Credentials{
+				AccessKeyID:     value.AccessKeyID,
+				SecretAccessKey: value.SecretAccessKey,
+				SessionToken:    value.SessionToken,
+				Source:          value.ProviderName,
+			}
+			if expiresAt, err := sess.Config.Credentials.ExpiresAt(); err == nil {
+				credentials.CanExpire = true
+				credentials.Expires = expiresAt
+			}
+			return credentials, nil
+		}),
+	}
+
+	if endpoint := awsv1.StringValue(sess.Config.Endpoint); endpoint != "" {
+		config.BaseEndpoint = aws.String(endpoint)
+	}
+	if sess.Config.HTTPClient != nil {
+		config.HTTPClient = sess.Config.HTTPClient
+	}
+	// In v1, MaxRetries counts the retries after the first attempt, and is negative to use the default of the service.
+	if maxRetries := awsv1.IntValue(sess.Config.MaxRetries); sess.Config.MaxRetries != nil && maxRetries >= 0 {
+		config.RetryMaxAttempts = maxRetries + 1
+	}
+	return config
+}
+
+// toV1AttributeValue converts the given aws-sdk-go-v2 attribute value to its aws-sdk-go v1 equivalent.
+func toV1AttributeValue(value types.AttributeValue) *dynamodbv1.AttributeValue {
+	switch value := value.(type) {
+	case *types.AttributeValueMemberS:
+		return &dynamodbv1.AttributeValue{S: awsv1.String(value.Value)}
+	case *types.AttributeValueMemberN:
+		return &dynamodbv1.AttributeValue{N: awsv1.String(value.Value)}
+	case *types.AttributeValueMemberB:
+		return &dynamodbv1.AttributeValue{B: value.Value}
+	case *types.AttributeValueMemberBOOL:
+		return &dynamodbv1.AttributeValue{BOOL: awsv1.Bool(value.Value)}
+	case *types.AttributeValueMemberNULL:
+		return &dynamodbv1.AttributeValue{NULL: awsv1.Bool(value.Value)}
+	case *types.AttributeValueMemberSS:
+		return &dynamodbv1.AttributeValue{SS: awsv1.StringSlice(value.Value)}
+	case *types.AttributeValueMemberNS:
+		return &dynamodbv1.AttributeValue{NS: awsv1.StringSlice(value.Value)}
+	case *types.AttributeValueMemberBS:
+		return &dynamodbv1.AttributeValue{BS: value.Value}
+	case *types.AttributeValueMemberL:
+		list := []*dynamodbv1.AttributeValue{}
+		for _, element := range value.Value {
+			list = append(list, toV1AttributeValue(element))
+		}
+		return &dynamodbv1.AttributeValue{L: list}
+	case *types.AttributeValueMemberM:
+		members := map[string]*dynamodbv1.AttributeValue{}
+		for name, member := range value.Value {
+			members[name] = toV1AttributeValue(member)
+		}
+		return &dynamodbv1.AttributeValue{M: members}
+	default:
+		return &dynamodbv1.AttributeValue{}
+	}
+}
@@ -0,0 +1,418 @@
+package lock
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/google/uuid"
+
+	"github.com/gruntwork-io/go-commons/errors"
+)
+
+// LockMode is the mode in which a lock is held.
+type LockMode string
+
+const (
+	// ExclusiveMode locks can be held by a single owner at a time. This is the default.
+	ExclusiveMode LockMode = "exclusive"
+	// SharedMode locks can be held by any number of owners at the same time, but not while the lock is held in
+	// exclusive mode. Conversely, an exclusive lock can't be acquired until all the shared holders are gone.
+	SharedMode LockMode = "shared"
+	// SemaphoreMode locks can be held by up to Options.Capacity owners at the same time, which makes them counting
+	// semaphores. Like shared locks, each holder has its own lease.
+	SemaphoreMode LockMode = "semaphore"
+)
+
+// hasHolders returns true for the modes in which a lock can have several holders, which are recorded in Lock.Holders.
+func (mode LockMode) hasHolders() bool {
+	return mode == SharedMode || mode == SemaphoreMode
+}
+
+const (
+	// The attributes that store the holders and the revision of the record of a shared lock
+	attributeHolders = "Holders"
+	attributeVersion = "Version"
+
+	// How many times to retry an update of a shared lock that lost the race with a concurrent update by another holder
+	maxSharedLockUpdateAttempts = 10
+)
+
+// Holder is the record of one of the holders of a shared lock.
+type Holder struct {
+	// The unique identifier of the holder
+	Owner string `json:"Owner" dynamodbav:"Owner"`
+	// The host and process ID of the holder
+	Hostname string `json:"Hostname,omitempty" dynamodbav:"Hostname,omitempty"`
+	PID      int    `json:"PID,omitempty" dynamodbav:"PID,omitempty"`
+	// The description of why the lock is held, as passed in through Options
+	Description string `json:"Description,omitempty" dynamodbav:"Description,omitempty"`
+	// When the holder acquired the lock
+	AcquiredAt time.Time `json:"AcquiredAt" dynamodbav:"AcquiredAt"`
+	// When the lease of the holder runs out, in seconds since the epoch. This is 0 for holders without a lease.
+	ExpiresAt int64 `json:"ExpiresAt,omitempty" dynamodbav:"ExpiresAt,omitempty"`
+	// The fencing token assigned to the holder when it acquired the lock
+	FencingToken int64 `json:"FencingToken,omitempty" dynamodbav:"FencingToken,omitempty"`
+}
+
+// IsExpired returns true if the holder has a lease that has run out by the given time.
+func (holder Holder) IsExpired(now time.Time) bool {
+	return holder.ExpiresAt != 0 && holder.ExpiresAt < now.Unix()
+}
+
+// InvalidLockCapacityError is returned when acquiring a semaphore with a capacity of less than one.
+type InvalidLockCapacityError struct {
+	LockString string
+	Capacity   int
+}
+
+func (err InvalidLockCapacityError) Error() string {
+	return fmt.Sprintf("Invalid capacity %d for semaphore %s: the capacity must be at least 1\n", err.Capacity, err.LockString)
+}
+
+// SharedLockContentionError is returned when an update of a shared lock keeps losing the race with concurrent updates
+// by other holders.
+type SharedLockContentionError struct {
+	LockTable  string
+	LockString string
+}
+
+func (err SharedLockContentionError) Error() string {
+	return fmt.Sprintf("Gave up updating shared lock %s in table %s after %d concurrent updates\n", err.LockString, err.LockTable, maxSharedLockUpdateAttempts)
+}
+
+// lockMode returns the mode in which to acquire the lock described by the given options.
+func lockMode(options *Options) LockMode {
+	if options.Mode == "" {
+		return ExclusiveMode
+	}
+	return options.Mode
+}
+
+// newHolder returns the record of the owner in the given options holding a shared lock acquired at the given time.
+func newHolder(options *Options, now time.Time) Holder {
+	lock := newLock(options, now)
+	return Holder{
+		Owner:       lock.Owner,
+		Hostname:    lock.Hostname,
+		PID:         lock.PID,
+		Description: lock.Description,
+		AcquiredAt:  lock.AcquiredAt,
+		ExpiresAt:   lock.ExpiresAt,
+	}
+}
+
+// addSharedHolder returns the record of the shared lock or semaphore described by the given options, with the given
+// holder added to it. existing is the current record of the lock, or nil if there is none. This returns an
+// AlreadyLockedError if the lock is held in another mode, or if the semaphore has no free slots. The capacity of a
+// semaphore is always taken from the options of the caller, so that it can be changed without releasing all the slots.
+func addSharedHolder(existing *Lock, options *Options, holder Holder, now time.Time) (Lock, error) {
+	mode := lockMode(options)
+	alreadyLockedErr := errors.WithStackTrace(AlreadyLockedError{LockTable: options.LockTable, LockString: options.LockString})
+
+	if existing != nil && !existing.IsExpired(now) && existing.Mode != mode {
+		return Lock{}, alreadyLockedErr
+	}
+
+	lock := Lock{ID: options.LockString, Mode: mode, AcquiredAt: now.UTC()}
+	if existing != nil && !existing.IsExpired(now) {
+		lock = existing.copyHolders()
+		lock.dropExpiredHolders(now)
+	}
+	if lock.Holders == nil {
+		lock.Holders = map[string]Holder{}
+	}
+
+	if mode == SemaphoreMode {
+		lock.Capacity = options.Capacity
+		if _, isHolder := lock.Holders[holder.Owner]; !isHolder && len(lock.Holders) >= options.Capacity {
+			return Lock{}, alreadyLockedErr
+		}
+	}
+
+	lock.Holders[holder.Owner] = holder
+	lock.refreshSharedLock(now)
+	return lock, nil
+}
+
+// renewSharedHolder returns the record of the given shared lock, with the lease of the owner in the given options
+// extended. This returns a LockNotHeldError if the owner doesn't hold the lock.
+func renewSharedHolder(existing *Lock, options *Options, now time.Time) (Lock, error) {
+	holder, isHeld := existing.sharedHolder(lockOwner(options))
+	if !isHeld {
+		return Lock{}, errors.WithStackTrace(LockNotHeldError{LockTable: options.LockTable, LockString: options.LockString, Owner: lockOwner(options)})
+	}
+
+	lock := existing.copyHolders()
+	holder.ExpiresAt = leaseExpiry(now, options.LeaseDuration).Unix()
+	lock.Holders[holder.Owner] = holder
+	lock.refreshSharedLock(now)
+	return lock, nil
+}
+
+// removeSharedHolder returns the record of the given shared lock, with the owner in the given options removed from
+// its holders, or nil if nobody holds the lock anymore. This returns a LockNotHeldError if the owner doesn't hold the
+// lock.
+func removeSharedHolder(existing *Lock, options *Options, now time.Time) (*Lock, error) {
+	if _, isHeld := existing.sharedHolder(lockOwner(options)); !isHeld {
+		return nil, errors.WithStackTrace(LockNotHeldError{LockTable: options.LockTable, LockString: options.LockString, Owner: lockOwner(options)})
+	}
+
+	lock := existing.copyHolders()
+	delete(lock.Holders, lockOwner(options))
+	lock.refreshSharedLock(now)
+	if len(lock.Holders) == 0 {
+		return nil, nil
+	}
+	return &lock, nil
+}
+
+// sharedHolder returns the record of the given owner, if the lock is a shared lock or semaphore held by that owner.
+func (lock *Lock) sharedHolder(owner string) (Holder, bool) {
+	if lock == nil || !lock.Mode.hasHolders() {
+		return Holder{}, false
+	}
+	holder, isHeld := lock.Holders[owner]
+	return holder, isHeld
+}
+
+// copyHolders returns a copy of the lock that doesn't share its holders with the original.
+func (lock Lock) copyHolders() Lock {
+	if lock.Holders == nil {
+		return lock
+	}
+
+	holders := make(map[string]Holder, len(lock.Holders))
+	for owner, holder := range lock.Holders {
+		holders[owner] = holder
+	}
+	lock.Holders = holders
+	return lock
+}
+
+// refreshSharedLock drops the holders whose lease has run out and assigns the record a new revision.
+func (lock *Lock) refreshSharedLock(now time.Time) {
+	lock.dropExpiredHolders(now)
+	lock.Version = uuid.NewString()
+}
+
+// dropExpiredHolders drops the holders whose lease has run out from a shared lock or semaphore, and updates the expiry
+// of the lock to when the last of the remaining leases runs out.
+func (lock *Lock) dropExpiredHolders(now time.Time) {
+	if !lock.Mode.hasHolders() {
+		return
+	}
+
+	lock.ExpiresAt = 0
+	hasHolderWithoutLease := false
+
+	for owner, holder := range lock.Holders {
+		switch {
+		case holder.IsExpired(now):
+			delete(lock.Holders, owner)
+		case holder.ExpiresAt == 0:
+			hasHolderWithoutLease = true
+		case holder.ExpiresAt > lock.ExpiresAt:
+			lock.ExpiresAt = holder.ExpiresAt
+		}
+	}
+
+	// A single holder without a lease keeps the whole lock from expiring.
+	if hasHolderWithoutLease {
+		lock.ExpiresAt = 0
+	}
+}
+
+// holderView returns the record of the given shared lock as seen by the given holder, with the details of the holder
+// filled in. This is what AcquireLock returns for shared locks.
+func (lock Lock) holderView(holder Holder) *Lock {
+	lock = lock.copyHolders()
+	lock.Owner = holder.Owner
+	lock.Hostname = holder.Hostname
+	lock.PID = holder.PID
+	lock.Description = holder.Description
+	lock.AcquiredAt = holder.AcquiredAt
+	lock.ExpiresAt = holder.ExpiresAt
+	lock.FencingToken = holder.FencingToken
+	return &lock
+}
+
+// acquireSharedLock will attempt to acquire the lock described by the given options in shared or semaphore mode in
+// DynamoDB. On success, this returns the record of the lock as seen by the new holder.
+func acquireSharedLock(ctx context.Context, options *Options, client DynamoDBAPI) (*Lock, error) {
+	options.Logger.Infof("Attempting to acquire %s lock %s in table %s in region %s\n",
+		lockMode(options),
+		options.LockString,
+		options.LockTable,
+		options.AwsRegion,
+	)
+
+	// Unlike exclusive locks, the token is taken before the lock is acquired, as it is recorded along with the holder.
+	// Tokens taken by failed attempts are simply skipped.
+	fencingToken, err := nextFencingToken(ctx, options, client)
+	if err != nil {
+		return nil, err
+	}
+
+	var holder Holder
+	lock, err := updateSharedLock(ctx, options, client, func(existing *Lock, now time.Time) (*Lock, error) {
+		holder = newHolder(options, now)
+		holder.FencingToken = fencingToken
+		lock, err := addSharedHolder(existing, options, holder, now)
+		return &lock, err
+	})
+	if err != nil {
+		options.Logger.Errorf("Error acquiring %s lock %s in table %s in region %s: %s\n", lockMode(options), options.LockString, options.LockTable, options.AwsRegion, err)
+		return nil, err
+	}
+
+	options.Logger.Infof("Acquired %s lock '%s' in table %s with fencing token %d\n", lockMode(options), options.LockString, options.LockTable, fencingToken)
+
+	record := newHistoryRecord(options, HistoryAcquire, holder.AcquiredAt)
+	record.FencingToken = fencingToken
+	recordHistory(ctx, options, client, record)
+
+	if options.LeaseDuration > 0 {
+		startHeartbeat(dynamoDBHeartbeatKey(options), options, func() error {
+			return renewSharedLease(context.Background(), options, client)
+		})
+	}
+	return lock.holderView(holder), nil
+}
+
+// renewSharedLease extends the lease of the owner in the given options on the shared lock.
+func renewSharedLease(ctx context.Context, options *Options, client DynamoDBAPI) error {
+	_, err := updateSharedLock(ctx, options, client, func(existing *Lock, now time.Time) (*Lock, error) {
+		lock, err := renewSharedHolder(existing, options, now)
+		return &lock, err
+	})
+	if err != nil {
+		return err
+	}
+
+	recordHistory(ctx, options, client, newHistoryRecord(options, HistoryRenew, time.Now()))
+	return nil
+}
+
+// releaseSharedLock removes the owner in the given options from the holders of the shared lock or semaphore, deleting
+// the lock item when the last holder is gone.
+func releaseSharedLock(ctx context.Context, options *Options, client DynamoDBAPI) error {
+	options.Logger.Infof(
+		"Attempting to release shared lock %s in table %s in region %s\n",
+		options.LockString,
+		options.LockTable,
+		options.AwsRegion,
+	)
+
+	_, err := updateSharedLock(ctx, options, client, func(existing *Lock, now time.Time) (*Lock, error) {
+		return removeSharedHolder(existing, options, now)
+	})
+	if err != nil {
+		options.Logger.Errorf("Error releasing shared lock %s in table %s in region %s: %s\n", options.LockString, options.LockTable, options.AwsRegion, err)
+		return err
+	}
+
+	options.Logger.Infof("Released shared lock '%s' in table %s\n", options.LockString, options.LockTable)
+	recordHistory(ctx, options, client, newHistoryRecord(options, HistoryRelease, time.Now()))
+	return nil
+}
+
+// updateSharedLock reads the record of the lock described by the given options, passes it to update (or nil if there
+// is no record), and writes back the record that update returns, deleting the lock item if that is nil. The write is
+// conditional on the record not having changed since it was read, and the whole cycle is retried if it has. This
+// returns the record that was written.
+func updateSharedLock(ctx context.Context, options *Options, client DynamoDBAPI, update func(existing *Lock, now time.Time) (*Lock, error)) (*Lock, error) {
+	for attempt := 0; attempt < maxSharedLockUpdateAttempts; attempt++ {
+		existing, err := getLockItem(ctx, options, client)
+		if err != nil {
+			return nil, err
+		}
+
+		now := time.Now()
+		updated, err := update(existing, now)
+		if err != nil {
+			return nil, err
+		}
+
+		err = writeSharedLock(ctx, options, client, existing, updated, now)
+		if err == nil {
+			return updated, nil
+		}
+		if !isConditionalCheckFailedError(err) {
+			return nil, errors.WithStackTrace(err)
+		}
+		options.Logger.Debugf("Shared lock %s in table %s was updated concurrently, retrying\n", options.LockString, options.LockTable)
+	}
+
+	return nil, errors.WithStackTrace(SharedLockContentionError{LockTable: options.LockTable, LockString: options.LockString})
+}
+
+// writeSharedLock replaces the given existing record of a lock with the given updated record, or deletes it if updated
+// is nil. If the existing lock is live, the write is conditional on it still being at the same revision. Otherwise,
+// it is conditional on the lock still being absent or expired, just like the acquisition of an exclusive lock.
+func writeSharedLock(ctx context.Context, options *Options, client DynamoDBAPI, existing *Lock, updated *Lock, now time.Time) error {
+	conditionExpression := "#version = :version"
+	expressionAttributeNames := map[string]string{"#version": attributeVersion}
+	expressionAttributeValues := map[string]types.AttributeValue{}
+
+	if existing == nil || existing.IsExpired(now) {
+		conditionExpression = "attribute_not_exists(#lockId) OR #expiresAt < :now"
+		expressionAttributeNames = map[string]string{
+			"#lockId":    attributeLockId,
+			"#expiresAt": attributeExpiresAt,
+		}
+		expressionAttributeValues[":now"] = numberAttribute(now.Unix())
+	} else {
+		expressionAttributeValues[":version"] = &types.AttributeValueMemberS{Value: existing.Version}
+	}
+
+	if updated == nil {
+		_, err := client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+			Key:                       lockKey(options.LockString),
+			TableName:                 aws.String(options.LockTable),
+			ConditionExpression:       aws.String(conditionExpression),
+			ExpressionAttributeNames:  expressionAttributeNames,
+			ExpressionAttributeValues: expressionAttributeValues,
+		})
+		return err
+	}
+
+	item, err := attributevalue.MarshalMap(updated)
+	if err != nil {
+		return errors.WithStackTrace(err)
+	}
+	_, err = client.PutItem(ctx, &dynamodb.PutItemInput{
+		Item:                      item,
+		TableName:                 aws.String(options.LockTable),
+		ConditionExpression:       aws.String(conditionExpression),
+		ExpressionAttributeNames:  expressionAttributeNames,
+		ExpressionAttributeValues: expressionAttributeValues,
+	})
+	return err
+}
+
+// getLockItem returns the current record of the lock described by the given options, or nil if there is none. This
+// uses a consistent read, as the record is used to decide how to update the lock.
+func getLockItem(ctx context.Context, options *Options, client DynamoDBAPI) (*Lock, error) {
+	output, err := client.GetItem(ctx, &dynamodb.GetItemInput{
+		Key:            lockKey(options.LockString),
+		TableName:      aws.String(options.LockTable),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, errors.WithStackTrace(err)
+	}
+	if len(output.Item) == 0 {
+		return nil, nil
+	}
+
+	lock := Lock{}
+	if err := attributevalue.UnmarshalMap(output.Item, &lock); err != nil {
+		return nil, errors.WithStackTrace(err)
+	}
+	return &lock, nil
+}
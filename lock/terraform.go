@@ -0,0 +1,223 @@
+package lock
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/user"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/gruntwork-io/go-commons/errors"
+)
+
+const (
+	// The attribute in which Terraform stores the JSON encoded lock info
+	attributeInfo = "Info"
+
+	// The attribute of the items in which Terraform stores the checksum of a state file, next to the locks
+	attributeDigest = "Digest"
+)
+
+// TerraformLockInfo mirrors the lock info that Terraform records on the locks it acquires in the DynamoDB lock table,
+// and shows when it fails to acquire a lock.
+type TerraformLockInfo struct {
+	// The unique identifier of the lock, which is what `terraform force-unlock` takes
+	ID string
+	// The Terraform operation (e.g. OperationTypeApply) or other operation for which the lock is held
+	Operation string
+	// Extra information about the lock
+	Info string
+	// The user and host holding the lock, as user@host
+	Who string
+	// The version of Terraform, or of the tool holding the lock
+	Version string
+	// When the lock was acquired
+	Created time.Time
+	// The path of the state file the lock guards
+	Path string
+}
+
+// LockIDMismatchError is returned when force releasing a lock with an ID that doesn't match the lock.
+type LockIDMismatchError struct {
+	LockTable  string
+	LockString string
+	LockID     string
+}
+
+func (err LockIDMismatchError) Error() string {
+	return fmt.Sprintf("Lock %s in table %s does not have ID %s\n", err.LockString, err.LockTable, err.LockID)
+}
+
+// TerraformInfo returns the Terraform lock info recorded on the lock, or nil if there is none.
+func (lock Lock) TerraformInfo() (*TerraformLockInfo, error) {
+	if lock.Info == "" {
+		return nil, nil
+	}
+
+	info := TerraformLockInfo{}
+	if err := json.Unmarshal([]byte(lock.Info), &info); err != nil {
+		return nil, errors.WithStackTrace(err)
+	}
+	return &info, nil
+}
+
+// newTerraformLockInfo returns the JSON encoded Terraform lock info to record on the given lock, based on the info in
+// the given options. The ID of the info is the owner of the lock, so that `terraform force-unlock <owner>` works.
+func newTerraformLockInfo(options *Options, lock Lock) string {
+	info := *options.TerraformLockInfo
+	info.ID = lock.Owner
+	if info.Who == "" {
+		info.Who = fmt.Sprintf("%s@%s", currentUserName(), lock.Hostname)
+	}
+	if info.Info == "" {
+		info.Info = lock.Description
+	}
+	if info.Created.IsZero() {
+		info.Created = lock.AcquiredAt
+	}
+	if info.Path == "" {
+		info.Path = lock.ID
+	}
+
+	// Marshalling a struct of strings and a time can't fail.
+	contents, _ := json.Marshal(info)
+	return string(contents)
+}
+
+// fillFromTerraformInfo fills in the owner details of a lock acquired by Terraform from its lock info, so that it can
+// be inspected like the locks acquired through this package.
+func (lock *Lock) fillFromTerraformInfo() {
+	if lock.Owner != "" || lock.Info == "" {
+		return
+	}
+
+	info, err := lock.TerraformInfo()
+	if err != nil || info == nil {
+		return
+	}
+	lock.Owner = info.Who
+	lock.Description = info.Operation
+	lock.AcquiredAt = info.Created
+}
+
+// matchesLockID returns true if the given ID identifies the current holder of the lock: the ID of its Terraform lock
+// info, its owner, or one of its holders.
+func (lock Lock) matchesLockID(lockID string) bool {
+	if info, err := lock.TerraformInfo(); err == nil && info != nil && info.ID == lockID {
+		return true
+	}
+	if lock.Info == "" && lock.Owner == lockID {
+		return true
+	}
+	_, isHolder := lock.Holders[lockID]
+	return isHolder
+}
+
+func currentUserName() string {
+	current, err := user.Current()
+	if err != nil {
+		return "unknown"
+	}
+	return current.Username
+}
+
+// ForceReleaseLock releases the DynamoDB lock described by the given options, whoever holds it. Like `terraform
+// force-unlock`, this requires the ID of the lock, which is the ID in the Terraform lock info of the lock, or else its
+// owner (or the owner of any of the holders of a shared lock or semaphore, in which case only that holder is removed).
+// This works with locks acquired by Terraform as well as with locks acquired through this package. Only use this when
+// the holder is known to be gone.
+func ForceReleaseLock(options *Options, lockID string) error {
+	return ForceReleaseLockWithContext(context.Background(), options, lockID)
+}
+
+// ForceReleaseLockWithContext is like ForceReleaseLock, but uses the given context for the calls to DynamoDB.
+func ForceReleaseLockWithContext(ctx context.Context, options *Options, lockID string) error {
+	client, err := getDynamoDBClient(ctx, options)
+	if err != nil {
+		options.Logger.Errorf("Error authenticating to AWS: %s\n", err)
+		return err
+	}
+
+	options.Logger.Infof("Attempting to force release lock %s in table %s in region %s\n", options.LockString, options.LockTable, options.AwsRegion)
+
+	lock, err := getLockItem(ctx, options, client)
+	if err != nil {
+		return err
+	}
+	if lock == nil {
+		return errors.WithStackTrace(LockNotHeldError{LockTable: options.LockTable, LockString: options.LockString, Owner: lockID})
+	}
+	if !lock.matchesLockID(lockID) {
+		return errors.WithStackTrace(LockIDMismatchError{LockTable: options.LockTable, LockString: options.LockString, LockID: lockID})
+	}
+
+	if _, isHolder := lock.sharedHolder(lockID); isHolder {
+		if err := forceRemoveSharedHolder(ctx, options, client, lockID); err != nil {
+			return err
+		}
+	} else if err := forceDeleteLock(ctx, options, client, lock, lockID); err != nil {
+		return err
+	}
+
+	options.Logger.Infof("Force released lock '%s' in table %s\n", options.LockString, options.LockTable)
+
+	record := newHistoryRecord(options, HistoryForceRelease, time.Now())
+	record.Owner = lockID
+	if lock.Owner != "" {
+		record.Owner = lock.Owner
+	}
+	record.ForcedBy = lockOwner(options)
+	recordHistory(ctx, options, client, record)
+	return nil
+}
+
+// forceRemoveSharedHolder removes the given holder from the holders of the shared lock or semaphore described by the
+// given options, leaving the other holders alone. The lock item is only deleted if that was the last holder.
+func forceRemoveSharedHolder(ctx context.Context, options *Options, client DynamoDBAPI, owner string) error {
+	holderOptions := *options
+	holderOptions.Owner = owner
+
+	_, err := updateSharedLock(ctx, &holderOptions, client, func(existing *Lock, now time.Time) (*Lock, error) {
+		if _, isHolder := existing.sharedHolder(owner); !isHolder {
+			return nil, errors.WithStackTrace(LockIDMismatchError{LockTable: options.LockTable, LockString: options.LockString, LockID: owner})
+		}
+		return removeSharedHolder(existing, &holderOptions, now)
+	})
+	return err
+}
+
+// forceDeleteLock deletes the item of the given lock, as long as it hasn't changed since it was read.
+func forceDeleteLock(ctx context.Context, options *Options, client DynamoDBAPI, lock *Lock, lockID string) error {
+	// Only delete the lock if it hasn't changed hands since we read it, so that we never release the lock of a holder
+	// that took over in the meantime.
+	deleteParams := &dynamodb.DeleteItemInput{
+		Key:       lockKey(options.LockString),
+		TableName: aws.String(options.LockTable),
+	}
+	switch {
+	case lock.Version != "":
+		deleteParams.ConditionExpression = aws.String("#version = :version")
+		deleteParams.ExpressionAttributeNames = map[string]string{"#version": attributeVersion}
+		deleteParams.ExpressionAttributeValues = map[string]types.AttributeValue{":version": &types.AttributeValueMemberS{Value: lock.Version}}
+	case lock.Info != "":
+		deleteParams.ConditionExpression = aws.String("#info = :info")
+		deleteParams.ExpressionAttributeNames = map[string]string{"#info": attributeInfo}
+		deleteParams.ExpressionAttributeValues = map[string]types.AttributeValue{":info": &types.AttributeValueMemberS{Value: lock.Info}}
+	default:
+		deleteParams.ConditionExpression = aws.String("#owner = :owner")
+		deleteParams.ExpressionAttributeNames = map[string]string{"#owner": attributeOwner}
+		deleteParams.ExpressionAttributeValues = map[string]types.AttributeValue{":owner": &types.AttributeValueMemberS{Value: lock.Owner}}
+	}
+
+	if _, err := client.DeleteItem(ctx, deleteParams); err != nil {
+		if isConditionalCheckFailedError(err) {
+			return errors.WithStackTrace(LockIDMismatchError{LockTable: options.LockTable, LockString: options.LockString, LockID: lockID})
+		}
+		return errors.WithStackTrace(err)
+	}
+	return nil
+}
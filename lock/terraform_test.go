@@ -0,0 +1,175 @@
+package lock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gruntwork-io/go-commons/collections"
+	"github.com/gruntwork-io/go-commons/errors"
+	"github.com/gruntwork-io/go-commons/logging"
+)
+
+// The lock info of a lock acquired by Terraform, as recorded in the lock table
+const testTerraformInfo = `{"ID":"1a2b3c4d-0000-0000-0000-000000000000","Operation":"OperationTypeApply","Info":"","Who":"jane@ci-runner","Version":"1.5.7","Created":"2024-01-02T03:04:05.123456Z","Path":"my-bucket/prod/terraform.tfstate"}`
+
+func TestAcquireLockRecordsTerraformLockInfo(t *testing.T) {
+	t.Parallel()
+
+	options := newTestLockerOptions(t, "test-memory-lock-table")
+	options.TerraformLockInfo = &TerraformLockInfo{Operation: "OperationTypeApply", Version: "1.5.7"}
+
+	lock, err := NewMemoryLocker().AcquireLock(context.Background(), options)
+	require.NoError(t, err)
+
+	info, err := lock.TerraformInfo()
+	require.NoError(t, err)
+	require.NotNil(t, info)
+	assert.Equal(t, options.Owner, info.ID)
+	assert.Equal(t, "OperationTypeApply", info.Operation)
+	assert.Equal(t, "1.5.7", info.Version)
+	assert.Equal(t, options.LockString, info.Path)
+	assert.Equal(t, options.Description, info.Info)
+	assert.Contains(t, info.Who, "@"+hostname)
+	assert.Equal(t, lock.AcquiredAt, info.Created)
+}
+
+func TestGetLockStatusReadsTerraformLocks(t *testing.T) {
+	t.Parallel()
+
+	options := newTerraformTestOptions(t, &terraformLockDynamoDBClient{})
+
+	lock, err := NewDynamoDBLocker().GetLockStatus(context.Background(), options)
+	require.NoError(t, err)
+	require.NotNil(t, lock)
+	assert.Equal(t, "jane@ci-runner", lock.Owner)
+	assert.Equal(t, "OperationTypeApply", lock.Description)
+	assert.Equal(t, time.Date(2024, 1, 2, 3, 4, 5, 123456000, time.UTC), lock.AcquiredAt)
+}
+
+func TestListLocksFiltersTerraformLocksByOwner(t *testing.T) {
+	t.Parallel()
+
+	client := &terraformLockDynamoDBClient{}
+	options := newTerraformTestOptions(t, client)
+
+	locks, err := NewDynamoDBLocker().ListLocks(context.Background(), options, LockFilter{Owner: "jane@ci-runner"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"my-bucket/prod/terraform.tfstate"}, lockIDs(locks))
+
+	locks, err = NewDynamoDBLocker().ListLocks(context.Background(), options, LockFilter{Owner: "john@ci-runner"})
+	require.NoError(t, err)
+	assert.Empty(t, locks)
+}
+
+func TestForceReleaseLockRequiresLockID(t *testing.T) {
+	t.Parallel()
+
+	client := &terraformLockDynamoDBClient{}
+	options := newTerraformTestOptions(t, client)
+
+	err := ForceReleaseLock(options, "jane@ci-runner")
+	require.Error(t, err)
+	assert.IsType(t, LockIDMismatchError{}, errors.Unwrap(err))
+	assert.Nil(t, client.deleteItemInput)
+
+	require.NoError(t, ForceReleaseLock(options, "1a2b3c4d-0000-0000-0000-000000000000"))
+	require.NotNil(t, client.deleteItemInput)
+	assert.Equal(t, "#info = :info", *client.deleteItemInput.ConditionExpression)
+	assert.Equal(t, &types.AttributeValueMemberS{Value: testTerraformInfo}, client.deleteItemInput.ExpressionAttributeValues[":info"])
+}
+
+func TestForceReleaseLockOnlyRemovesGivenHolder(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	item, err := attributevalue.MarshalMap(Lock{
+		ID:   "test-lock-string",
+		Mode: SharedMode,
+		Holders: map[string]Holder{
+			"reader-1": {Owner: "reader-1", AcquiredAt: now},
+			"reader-2": {Owner: "reader-2", AcquiredAt: now},
+		},
+		Version: "test-version",
+	})
+	require.NoError(t, err)
+
+	client := &singleItemDynamoDBClient{item: item}
+	options := newTerraformTestOptions(t, client)
+	options.LockString = "test-lock-string"
+
+	require.NoError(t, ForceReleaseLock(options, "reader-1"))
+	require.NotNil(t, client.item)
+	lock := Lock{}
+	require.NoError(t, attributevalue.UnmarshalMap(client.item, &lock))
+	assert.Equal(t, []string{"reader-2"}, collections.Keys(lock.Holders))
+
+	require.NoError(t, ForceReleaseLock(options, "reader-2"))
+	assert.Nil(t, client.item)
+}
+
+func newTerraformTestOptions(t *testing.T, client DynamoDBAPI) *Options {
+	return &Options{
+		LockTable:      "test-dynamodb-lock-table",
+		LockString:     "my-bucket/prod/terraform.tfstate",
+		Logger:         logging.GetLogger(t.Name(), ""),
+		DynamoDBClient: client,
+	}
+}
+
+// terraformLockDynamoDBClient is a fake DynamoDB client with a single lock that was acquired by Terraform, which records
+// the input of DeleteItem. Calling any method that isn't overridden panics.
+type terraformLockDynamoDBClient struct {
+	DynamoDBAPI
+	deleteItemInput *dynamodb.DeleteItemInput
+}
+
+func (client *terraformLockDynamoDBClient) GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	return &dynamodb.GetItemOutput{
+		Item: map[string]types.AttributeValue{
+			attributeLockId: &types.AttributeValueMemberS{Value: "my-bucket/prod/terraform.tfstate"},
+			attributeInfo:   &types.AttributeValueMemberS{Value: testTerraformInfo},
+		},
+	}, nil
+}
+
+// Scan returns the lock unless the scan has a filter, which this fake doesn't evaluate.
+func (client *terraformLockDynamoDBClient) Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	if params.FilterExpression != nil {
+		return &dynamodb.ScanOutput{}, nil
+	}
+	output, _ := client.GetItem(ctx, &dynamodb.GetItemInput{})
+	return &dynamodb.ScanOutput{Items: []map[string]types.AttributeValue{output.Item}}, nil
+}
+
+func (client *terraformLockDynamoDBClient) DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	client.deleteItemInput = params
+	return &dynamodb.DeleteItemOutput{}, nil
+}
+
+// singleItemDynamoDBClient is a fake DynamoDB client with a table that holds at most one item, which ignores the
+// conditions on writes. Calling any method that isn't overridden panics.
+type singleItemDynamoDBClient struct {
+	DynamoDBAPI
+	item map[string]types.AttributeValue
+}
+
+func (client *singleItemDynamoDBClient) GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	return &dynamodb.GetItemOutput{Item: client.item}, nil
+}
+
+func (client *singleItemDynamoDBClient) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	client.item = params.Item
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (client *singleItemDynamoDBClient) DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	client.item = nil
+	return &dynamodb.DeleteItemOutput{}, nil
+}
@@ -31,6 +31,9 @@ func GetLogger(name string, version string) *logrus.Entry {
 			FullTimestamp: true,
 		}
 	}
+	// Mask the secrets registered with RegisterSecret and RegisterSecretPattern in everything that is logged.
+	logger.AddHook(globalRedactor.Hook())
+
 	return logger.WithField("binary", name).WithField("version", version)
 
 }
@@ -0,0 +1,158 @@
+package logging
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// RedactedText is what secrets are replaced with by a Redactor.
+const RedactedText = "[REDACTED]"
+
+// The registry of secrets that the loggers returned by GetLogger, and the shell package, mask by default.
+var globalRedactor = NewRedactor()
+
+// Redactor is a registry of secret values and patterns, which it masks in any text it is given. It is safe to use from
+// multiple goroutines.
+type Redactor struct {
+	mutex    sync.RWMutex
+	values   map[string]bool
+	patterns []*regexp.Regexp
+	// replaces all the values at once, longest first, so that a secret that contains another one is masked whole
+	replacer *strings.Replacer
+}
+
+// NewRedactor returns an empty Redactor. Most callers should use the global registry (see RegisterSecret) instead, so
+// that the secrets they register are masked everywhere.
+func NewRedactor() *Redactor {
+	return &Redactor{values: map[string]bool{}}
+}
+
+// AddSecret registers a secret value to mask. Empty values are ignored.
+func (redactor *Redactor) AddSecret(value string) {
+	if value == "" {
+		return
+	}
+
+	redactor.mutex.Lock()
+	defer redactor.mutex.Unlock()
+
+	if redactor.values[value] {
+		return
+	}
+	redactor.values[value] = true
+
+	values := make([]string, 0, len(redactor.values))
+	for value := range redactor.values {
+		values = append(values, value)
+	}
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+
+	oldNew := make([]string, 0, 2*len(values))
+	for _, value := range values {
+		oldNew = append(oldNew, value, RedactedText)
+	}
+	redactor.replacer = strings.NewReplacer(oldNew...)
+}
+
+// AddPattern registers a pattern of secrets to mask, such as `ghp_[A-Za-z0-9]{36}`. If the pattern has capturing
+// groups, only the text matched by the groups is masked (e.g. `password=(\S+)` keeps the `password=`). Otherwise, the
+// whole match is masked.
+func (redactor *Redactor) AddPattern(pattern *regexp.Regexp) {
+	redactor.mutex.Lock()
+	defer redactor.mutex.Unlock()
+
+	redactor.patterns = append(redactor.patterns, pattern)
+}
+
+// Redact returns the given text with all the registered secrets masked.
+func (redactor *Redactor) Redact(text string) string {
+	redactor.mutex.RLock()
+	defer redactor.mutex.RUnlock()
+
+	if redactor.replacer != nil {
+		text = redactor.replacer.Replace(text)
+	}
+	for _, pattern := range redactor.patterns {
+		text = redactPattern(pattern, text)
+	}
+	return text
+}
+
+func redactPattern(pattern *regexp.Regexp, text string) string {
+	matches := pattern.FindAllStringSubmatchIndex(text, -1)
+	if len(matches) == 0 {
+		return text
+	}
+
+	redacted := strings.Builder{}
+	last := 0
+	for _, match := range matches {
+		// The indexes of the whole match come first, followed by the indexes of each group, if any.
+		spans := match[:2]
+		if len(match) > 2 {
+			spans = match[2:]
+		}
+		for i := 0; i < len(spans); i += 2 {
+			start, end := spans[i], spans[i+1]
+			// Skip the groups that did not take part in the match, and the ones nested in a group already masked.
+			if start < 0 || start < last {
+				continue
+			}
+			redacted.WriteString(text[last:start])
+			redacted.WriteString(RedactedText)
+			last = end
+		}
+	}
+	redacted.WriteString(text[last:])
+	return redacted.String()
+}
+
+// Hook returns a logrus hook that masks the secrets registered with this Redactor in the message and string fields of
+// every log entry.
+func (redactor *Redactor) Hook() logrus.Hook {
+	return &redactionHook{redactor: redactor}
+}
+
+type redactionHook struct {
+	redactor *Redactor
+}
+
+func (hook *redactionHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (hook *redactionHook) Fire(entry *logrus.Entry) error {
+	entry.Message = hook.redactor.Redact(entry.Message)
+	for key, value := range entry.Data {
+		if stringValue, isString := value.(string); isString {
+			entry.Data[key] = hook.redactor.Redact(stringValue)
+		}
+	}
+	return nil
+}
+
+// GetRedactor returns the global registry of secrets, which the loggers returned by GetLogger, and the shell package,
+// mask by default.
+func GetRedactor() *Redactor {
+	return globalRedactor
+}
+
+// RegisterSecret registers a secret value to mask in the global registry. Call this as soon as a secret is read, e.g.
+// from Secrets Manager or the environment, so that it never shows up in logs or in the output of commands.
+func RegisterSecret(value string) {
+	globalRedactor.AddSecret(value)
+}
+
+// RegisterSecretPattern registers a pattern of secrets to mask in the global registry. See Redactor.AddPattern.
+func RegisterSecretPattern(pattern *regexp.Regexp) {
+	globalRedactor.AddPattern(pattern)
+}
+
+// Redact returns the given text with all the secrets in the global registry masked.
+func Redact(text string) string {
+	return globalRedactor.Redact(text)
+}
@@ -0,0 +1,64 @@
+package logging
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/random"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedactorMasksValues(t *testing.T) {
+	t.Parallel()
+
+	redactor := NewRedactor()
+	redactor.AddSecret("")
+	redactor.AddSecret("hunter2")
+	redactor.AddSecret("hunter2-and-more")
+
+	assert.Equal(t, "nothing to hide", redactor.Redact("nothing to hide"))
+	assert.Equal(t, "password is [REDACTED], or [REDACTED]", redactor.Redact("password is hunter2, or hunter2-and-more"))
+}
+
+func TestRedactorMasksPatterns(t *testing.T) {
+	t.Parallel()
+
+	redactor := NewRedactor()
+	redactor.AddPattern(regexp.MustCompile(`ghp_[A-Za-z0-9]{8}`))
+	redactor.AddPattern(regexp.MustCompile(`password=(\S+)`))
+
+	assert.Equal(t, "token [REDACTED] and token [REDACTED]", redactor.Redact("token ghp_abcd1234 and token ghp_efgh5678"))
+	assert.Equal(t, "user=jane password=[REDACTED] host=example.com", redactor.Redact("user=jane password=s3cr3t host=example.com"))
+}
+
+func TestRedactorHook(t *testing.T) {
+	t.Parallel()
+
+	buffer := &bytes.Buffer{}
+	redactor := NewRedactor()
+	redactor.AddSecret("hunter2")
+	logger := GetLogger("", "")
+	logger.Logger.Out = buffer
+	logger.Logger.AddHook(redactor.Hook())
+
+	logger.WithField("password", "hunter2").Infof("Logging in with hunter2")
+	assert.NotContains(t, buffer.String(), "hunter2")
+	assert.Contains(t, buffer.String(), "Logging in with [REDACTED]")
+	assert.Contains(t, buffer.String(), "password=\"[REDACTED]\"")
+}
+
+func TestGetLoggerMasksRegisteredSecrets(t *testing.T) {
+	t.Parallel()
+
+	secret := "secret-" + random.UniqueId()
+	RegisterSecret(secret)
+
+	buffer := &bytes.Buffer{}
+	logger := GetLogger("", "")
+	logger.Logger.Out = buffer
+
+	logger.Infof("The secret is %s", secret)
+	assert.NotContains(t, buffer.String(), secret)
+	assert.Equal(t, RedactedText, Redact(secret))
+}
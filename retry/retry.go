@@ -1,6 +1,7 @@
 package retry
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -36,12 +37,50 @@ func DoWithRetryInterface(
 	maxRetries int,
 	sleepBetweenRetries time.Duration,
 	action func() (interface{}, error),
+) (interface{}, error) {
+	return DoWithRetryInterfaceWithContext(context.Background(), logger, actionDescription, maxRetries, sleepBetweenRetries, action)
+}
+
+// DoWithRetryWithContext is like DoWithRetry, but stops retrying as soon as the given context is done, in which case
+// the error of the context is returned.
+func DoWithRetryWithContext(
+	ctx context.Context,
+	logger *logrus.Entry,
+	actionDescription string,
+	maxRetries int,
+	sleepBetweenRetries time.Duration,
+	action func() error,
+) error {
+	_, err := DoWithRetryInterfaceWithContext(
+		ctx,
+		logger,
+		actionDescription,
+		maxRetries,
+		sleepBetweenRetries,
+		func() (interface{}, error) { return nil, action() },
+	)
+	return err
+}
+
+// DoWithRetryInterfaceWithContext is like DoWithRetryInterface, but stops retrying as soon as the given context is
+// done, in which case the error of the context is returned.
+func DoWithRetryInterfaceWithContext(
+	ctx context.Context,
+	logger *logrus.Entry,
+	actionDescription string,
+	maxRetries int,
+	sleepBetweenRetries time.Duration,
+	action func() (interface{}, error),
 ) (interface{}, error) {
 	var output interface{}
 	var err error
 
 	for i := 0; i <= maxRetries; i++ {
-		logger.Infof(actionDescription)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return output, ctxErr
+		}
+
+		logger.Info(actionDescription)
 
 		output, err = action()
 		if err == nil {
@@ -54,7 +93,15 @@ func DoWithRetryInterface(
 		}
 
 		logger.Infof("%s returned an error: %s. Attempt %d of %d. Sleeping for %s and will retry.", actionDescription, err.Error(), i+1, maxRetries, sleepBetweenRetries)
-		time.Sleep(sleepBetweenRetries)
+
+		timer := time.NewTimer(sleepBetweenRetries)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			logger.Infof("Giving up on %s: %s", actionDescription, ctx.Err())
+			return output, ctx.Err()
+		case <-timer.C:
+		}
 	}
 
 	return output, MaxRetriesExceeded{Description: actionDescription, MaxRetries: maxRetries}
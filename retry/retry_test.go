@@ -1,6 +1,7 @@
 package retry
 
 import (
+	"context"
 	"fmt"
 	"testing"
 	"time"
@@ -65,3 +66,27 @@ func TestDoWithRetry(t *testing.T) {
 		})
 	}
 }
+
+func TestDoWithRetryWithContextStopsWhenContextIsDone(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	attempts := 0
+	start := time.Now()
+	err := DoWithRetryWithContext(
+		ctx,
+		logging.GetLogger("test", ""),
+		"Always fails",
+		100,
+		1*time.Second,
+		func() error {
+			attempts++
+			return fmt.Errorf("expected error")
+		},
+	)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Equal(t, 1, attempts)
+	assert.Less(t, time.Since(start), 1*time.Second)
+}
@@ -0,0 +1,186 @@
+package shell
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/gruntwork-io/go-commons/errors"
+)
+
+// Answers are the answers to prompts (see NewPrompt) by key, which are used instead of prompting the user when the
+// non-interactive flag is set. They come from an answers file, or from environment variables.
+type Answers struct {
+	values map[string][]string
+	// If set, the answer to the prompt with key "db-name" can also be set with the environment variable
+	// <EnvPrefix>DB_NAME, which takes precedence over the other answers. For lists, separate the items with commas.
+	EnvPrefix string
+}
+
+// NewAnswers returns Answers with the given answers by key.
+func NewAnswers(values map[string]string) *Answers {
+	answers := &Answers{values: map[string][]string{}}
+	for key, value := range values {
+		answers.values[key] = []string{value}
+	}
+	return answers
+}
+
+// LoadAnswersFile returns the Answers in the given file, which is parsed as JSON if its extension is .json, and as
+// YAML otherwise. The file must contain an object whose keys are the keys of the prompts, and whose values are the
+// answers, either as a scalar (string, number or boolean) or as a list of scalars (for multi-selections):
+//
+//	region: us-east-1
+//	modules: [vpc, eks]
+//	confirm: yes
+//
+// Scalars are kept exactly as they are written in the file, so e.g. 1.50 is the answer "1.50", and 2024-01-01 is the
+// answer "2024-01-01".
+func LoadAnswersFile(path string) (*Answers, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.WithStackTrace(err)
+	}
+
+	var values map[string][]string
+	if filepath.Ext(path) == ".json" {
+		values, err = parseJSONAnswers(contents)
+	} else {
+		values, err = parseYAMLAnswers(contents)
+	}
+	if err != nil {
+		return nil, errors.WithStackTrace(InvalidAnswersFileError{Path: path, Err: err})
+	}
+	return &Answers{values: values}, nil
+}
+
+// parseJSONAnswers returns the answers by key in the given JSON object. Numbers are decoded as json.Number, as
+// decoding them as float64 could change them (e.g. 123456789012 would become 1.23456789012e+11).
+func parseJSONAnswers(contents []byte) (map[string][]string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(contents))
+	decoder.UseNumber()
+
+	values := map[string]interface{}{}
+	if err := decoder.Decode(&values); err != nil {
+		return nil, err
+	}
+
+	answers := map[string][]string{}
+	for key, value := range values {
+		items, isList := value.([]interface{})
+		if !isList {
+			items = []interface{}{value}
+		}
+
+		answers[key] = []string{}
+		for _, item := range items {
+			switch typedItem := item.(type) {
+			case nil:
+				answers[key] = append(answers[key], "")
+			case string, bool, json.Number:
+				answers[key] = append(answers[key], fmt.Sprint(typedItem))
+			default:
+				return nil, UnsupportedAnswerError{Key: key}
+			}
+		}
+	}
+	return answers, nil
+}
+
+// parseYAMLAnswers returns the answers by key in the given YAML mapping. The answers are the values of the scalar
+// nodes, as decoding them could change them (e.g. dates would become time.Time).
+func parseYAMLAnswers(contents []byte) (map[string][]string, error) {
+	values := map[string]yaml.Node{}
+	if err := yaml.Unmarshal(contents, &values); err != nil {
+		return nil, err
+	}
+
+	answers := map[string][]string{}
+	for key, value := range values {
+		node := resolveAlias(&value)
+		items := []*yaml.Node{node}
+		if node.Kind == yaml.SequenceNode {
+			items = node.Content
+		}
+
+		answers[key] = []string{}
+		for _, item := range items {
+			item = resolveAlias(item)
+			if item.Kind != yaml.ScalarNode {
+				return nil, UnsupportedAnswerError{Key: key}
+			}
+			if item.Tag == "!!null" {
+				answers[key] = append(answers[key], "")
+			} else {
+				answers[key] = append(answers[key], item.Value)
+			}
+		}
+	}
+	return answers, nil
+}
+
+// resolveAlias returns the node the given YAML node is an alias of, or the node itself if it isn't an alias.
+func resolveAlias(node *yaml.Node) *yaml.Node {
+	for node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	return node
+}
+
+// Lookup returns the answer for the given key, from the environment variable for the key if EnvPrefix is set, or else
+// from the answers given when the Answers were created. The answer is a list, as it can be one in an answers file.
+func (answers *Answers) Lookup(key string) ([]string, bool) {
+	if answers == nil {
+		return nil, false
+	}
+
+	if envVar := answers.envVarName(key); envVar != "" {
+		if value, isSet := os.LookupEnv(envVar); isSet {
+			return []string{value}, true
+		}
+	}
+
+	values, found := answers.values[key]
+	return values, found
+}
+
+var nonEnvVarChars = regexp.MustCompile(`[^A-Za-z0-9]+`)
+
+// envVarName returns the name of the environment variable that sets the answer for the given key, or "" if answers
+// don't come from the environment.
+func (answers *Answers) envVarName(key string) string {
+	if answers == nil || answers.EnvPrefix == "" || key == "" {
+		return ""
+	}
+	return answers.EnvPrefix + strings.ToUpper(nonEnvVarChars.ReplaceAllString(key, "_"))
+}
+
+// InvalidAnswersFileError is returned when an answers file can't be parsed.
+type InvalidAnswersFileError struct {
+	Path string
+	Err  error
+}
+
+func (err InvalidAnswersFileError) Error() string {
+	return fmt.Sprintf("Invalid answers file %s: %s", err.Path, err.Err)
+}
+
+func (err InvalidAnswersFileError) Unwrap() error {
+	return err.Err
+}
+
+// UnsupportedAnswerError is returned when the answer for a key in an answers file is neither a scalar nor a list of
+// scalars.
+type UnsupportedAnswerError struct {
+	Key string
+}
+
+func (err UnsupportedAnswerError) Error() string {
+	return fmt.Sprintf("the answer for key '%s' must be a string, a number, a boolean or a list of those", err.Key)
+}
@@ -0,0 +1,213 @@
+package shell
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gruntwork-io/go-commons/errors"
+	"github.com/gruntwork-io/go-commons/logging"
+)
+
+func TestLoadAnswersFile(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		contents string
+	}{
+		{"answers.yaml", "region: us-east-1\nmodules: [vpc, eks]\nconfirm: true\nreplicas: 3\nempty:\n"},
+		{"answers.json", `{"region": "us-east-1", "modules": ["vpc", "eks"], "confirm": true, "replicas": 3, "empty": null}`},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			path := filepath.Join(t.TempDir(), testCase.name)
+			require.NoError(t, os.WriteFile(path, []byte(testCase.contents), 0644))
+
+			answers, err := LoadAnswersFile(path)
+			require.NoError(t, err)
+			for key, expected := range map[string][]string{
+				"region":   {"us-east-1"},
+				"modules":  {"vpc", "eks"},
+				"confirm":  {"true"},
+				"replicas": {"3"},
+				"empty":    {""},
+			} {
+				actual, found := answers.Lookup(key)
+				assert.True(t, found, key)
+				assert.Equal(t, expected, actual, key)
+			}
+
+			_, found := answers.Lookup("missing")
+			assert.False(t, found)
+		})
+	}
+}
+
+func TestLoadAnswersFileKeepsScalarsAsWritten(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		contents string
+	}{
+		{"answers.yaml", "account: 123456789012\nhuge: 123456789012345678901234567890\nprice: 1.50\nsince: 2024-01-01\nids: [123456789012, 2024-01-01]\n"},
+		{"answers.json", `{"account": 123456789012, "huge": 123456789012345678901234567890, "price": 1.50, "since": "2024-01-01", "ids": [123456789012, "2024-01-01"]}`},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			path := filepath.Join(t.TempDir(), testCase.name)
+			require.NoError(t, os.WriteFile(path, []byte(testCase.contents), 0644))
+
+			answers, err := LoadAnswersFile(path)
+			require.NoError(t, err)
+			for key, expected := range map[string][]string{
+				"account": {"123456789012"},
+				"huge":    {"123456789012345678901234567890"},
+				"price":   {"1.50"},
+				"since":   {"2024-01-01"},
+				"ids":     {"123456789012", "2024-01-01"},
+			} {
+				actual, found := answers.Lookup(key)
+				assert.True(t, found, key)
+				assert.Equal(t, expected, actual, key)
+			}
+		})
+	}
+}
+
+func TestLoadAnswersFileRejectsNestedAnswers(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "answers.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("database:\n  name: test\n"), 0644))
+
+	_, err := LoadAnswersFile(path)
+	var unsupportedErr UnsupportedAnswerError
+	require.ErrorAs(t, err, &unsupportedErr)
+	assert.Equal(t, "database", unsupportedErr.Key)
+}
+
+// This test sets environment variables, so it must not run in parallel.
+func TestAnswersFromEnvVars(t *testing.T) {
+	t.Setenv("MYAPP_DB_NAME", "from-env")
+
+	answers := NewAnswers(map[string]string{"db-name": "from-file", "region": "us-east-1"})
+	answers.EnvPrefix = "MYAPP_"
+
+	actual, found := answers.Lookup("db-name")
+	assert.True(t, found)
+	assert.Equal(t, []string{"from-env"}, actual)
+
+	actual, found = answers.Lookup("region")
+	assert.True(t, found)
+	assert.Equal(t, []string{"us-east-1"}, actual)
+}
+
+func nonInteractiveOptions(answers map[string]string) *ShellOptions {
+	options := NewShellOptions()
+	options.NonInteractive = true
+	options.Answers = NewAnswers(answers)
+	options.Answers.EnvPrefix = "GO_COMMONS_TEST_ANSWER_"
+	return options
+}
+
+func TestPromptUsesAnswersWhenNonInteractive(t *testing.T) {
+	t.Parallel()
+
+	options := nonInteractiveOptions(map[string]string{
+		"region":  "eu-west-1",
+		"confirm": "no",
+		"env":     "2",
+		"modules": "eks, vpc",
+	})
+	out := &bytes.Buffer{}
+
+	region, err := NewPrompt("region", "Region?").WithDefault("us-east-1").FAsk(out, os.Stdin, options)
+	require.NoError(t, err)
+	assert.Equal(t, "eu-west-1", region)
+
+	confirmed, err := NewPrompt("confirm", "Deploy?").FAskYesNo(out, os.Stdin, options)
+	require.NoError(t, err)
+	assert.False(t, confirmed)
+
+	env, err := NewPrompt("env", "Environment?").FAskSelection(out, os.Stdin, []string{"dev", "prod"}, options)
+	require.NoError(t, err)
+	assert.Equal(t, "prod", env)
+
+	modules, err := NewPrompt("modules", "Modules?").FAskMultiSelection(out, os.Stdin, []string{"vpc", "eks", "rds"}, options)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"vpc", "eks"}, modules)
+}
+
+func TestPromptFallsBackToDefaultWhenNonInteractive(t *testing.T) {
+	t.Parallel()
+
+	options := nonInteractiveOptions(nil)
+	out := &bytes.Buffer{}
+
+	region, err := NewPrompt("region", "Region?").WithDefault("us-east-1").FAsk(out, os.Stdin, options)
+	require.NoError(t, err)
+	assert.Equal(t, "us-east-1", region)
+
+	confirmed, err := NewPrompt("confirm", "Deploy?").WithDefault("yes").FAskYesNo(out, os.Stdin, options)
+	require.NoError(t, err)
+	assert.True(t, confirmed)
+
+	modules, err := NewPrompt("modules", "Modules?").WithDefault("vpc,rds").FAskMultiSelection(out, os.Stdin, []string{"vpc", "eks", "rds"}, options)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"vpc", "rds"}, modules)
+}
+
+func TestPromptFailsWithoutAnswerWhenNonInteractive(t *testing.T) {
+	t.Parallel()
+
+	options := nonInteractiveOptions(nil)
+
+	_, err := NewPrompt("db-name", "Database name?").FAsk(&bytes.Buffer{}, os.Stdin, options)
+	nonInteractiveErr, isNonInteractiveErr := errors.Unwrap(err).(NonInteractivePromptError)
+	require.True(t, isNonInteractiveErr, "expected a NonInteractivePromptError, got %T", errors.Unwrap(err))
+	assert.Equal(t, "db-name", nonInteractiveErr.Key)
+	assert.Equal(t, "GO_COMMONS_TEST_ANSWER_DB_NAME", nonInteractiveErr.EnvVar)
+	assert.Contains(t, err.Error(), "db-name")
+}
+
+func TestPromptRejectsInvalidAnswerWhenNonInteractive(t *testing.T) {
+	t.Parallel()
+
+	options := nonInteractiveOptions(map[string]string{"confirm": "maybe", "env": "staging"})
+
+	_, err := NewPrompt("confirm", "Deploy?").FAskYesNo(&bytes.Buffer{}, os.Stdin, options)
+	var invalidErr InvalidAnswerError
+	require.ErrorAs(t, err, &invalidErr)
+	assert.Equal(t, "confirm", invalidErr.Key)
+
+	_, err = NewPrompt("env", "Environment?").FAskSelection(&bytes.Buffer{}, os.Stdin, []string{"dev", "prod"}, options)
+	require.ErrorAs(t, err, &invalidErr)
+	var choiceErr InvalidChoiceError
+	assert.ErrorAs(t, err, &choiceErr)
+}
+
+func TestPromptPasswordFromAnswersIsRedacted(t *testing.T) {
+	t.Parallel()
+
+	options := nonInteractiveOptions(map[string]string{"db-password": "correct-horse-battery-staple"})
+	options.Redactor = logging.NewRedactor()
+
+	password, err := NewPrompt("db-password", "Password?").AskPassword(options)
+	require.NoError(t, err)
+	assert.Equal(t, "correct-horse-battery-staple", password)
+	assert.Equal(t, "password is [REDACTED]", options.Redactor.Redact("password is correct-horse-battery-staple"))
+}
@@ -1,10 +1,18 @@
 package shell
 
 import (
+	"context"
+	goerrors "errors"
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
 	"strings"
+	"time"
+
+	"golang.org/x/term"
 
 	"github.com/gruntwork-io/go-commons/errors"
 )
@@ -12,115 +20,255 @@ import (
 // Run the specified shell command with the specified arguments. Connect the command's stdin, stdout, and stderr to
 // the currently running app.
 func RunShellCommand(options *ShellOptions, command string, args ...string) error {
-	logCommand(options, command, args...)
-	cmd := exec.Command(command, args...)
-
-	// TODO: consider logging this via options.Logger
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-
-	setCommandOptions(options, cmd)
+	return RunShellCommandWithContext(context.Background(), options, command, args...)
+}
 
-	return errors.WithStackTrace(cmd.Run())
+// RunShellCommandWithContext is like RunShellCommand, but terminates the command if the given context is done before
+// it exits. See runCommand for how the command is terminated.
+func RunShellCommandWithContext(ctx context.Context, options *ShellOptions, command string, args ...string) error {
+	_, err := NewCommand(options, command, args...).
+		WithStdout(StreamInherit).
+		WithStderr(StreamInherit).
+		RunWithContext(ctx)
+	return err
 }
 
 // Like RunShellCommand, but feed the given content to the stdin of the process.
 func RunShellCommandWithInput(options *ShellOptions, inputString string, command string, args ...string) error {
-	logCommand(options, command, args...)
-	cmd := exec.Command(command, args...)
-
-	// TODO: consider logging this via options.Logger
-	cmd.Stdin = strings.NewReader(inputString)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-
-	setCommandOptions(options, cmd)
+	return RunShellCommandWithInputWithContext(context.Background(), options, inputString, command, args...)
+}
 
-	return errors.WithStackTrace(cmd.Run())
+// RunShellCommandWithInputWithContext is like RunShellCommandWithInput, but terminates the command if the given context
+// is done before it exits.
+func RunShellCommandWithInputWithContext(ctx context.Context, options *ShellOptions, inputString string, command string, args ...string) error {
+	_, err := NewCommand(options, command, args...).
+		WithInput(inputString).
+		WithStdout(StreamInherit).
+		WithStderr(StreamInherit).
+		RunWithContext(ctx)
+	return err
 }
 
 // Run the specified shell command with the specified arguments. Return its stdout, stderr, and interleaved output as
 // separate strings in a struct.
 func RunShellCommandAndGetOutputStruct(options *ShellOptions, command string, args ...string) (*Output, error) {
-	return runShellCommand(options, false, command, args...)
+	return runShellCommand(context.Background(), options, false, command, args...)
+}
+
+// RunShellCommandAndGetOutputStructWithContext is like RunShellCommandAndGetOutputStruct, but terminates the command if
+// the given context is done before it exits. The output the command wrote until then is returned along with the error.
+func RunShellCommandAndGetOutputStructWithContext(ctx context.Context, options *ShellOptions, command string, args ...string) (*Output, error) {
+	return runShellCommand(ctx, options, false, command, args...)
 }
 
 // Run the specified shell command with the specified arguments. Return its stdout and stderr as a string
 func RunShellCommandAndGetOutput(options *ShellOptions, command string, args ...string) (string, error) {
-	out, err := runShellCommand(options, false, command, args...)
+	out, err := runShellCommand(context.Background(), options, false, command, args...)
 	return out.Combined(), err
 }
 
 // Run the specified shell command with the specified arguments. Return its interleaved stdout and stderr as a string
 // and also stream stdout and stderr to the OS stdout/stderr
 func RunShellCommandAndGetAndStreamOutput(options *ShellOptions, command string, args ...string) (string, error) {
-	out, err := runShellCommand(options, true, command, args...)
+	out, err := runShellCommand(context.Background(), options, true, command, args...)
 	return out.Combined(), err
 }
 
 // Run the specified shell command with the specified arguments. Return its stdout as a string
 func RunShellCommandAndGetStdout(options *ShellOptions, command string, args ...string) (string, error) {
-	out, err := runShellCommand(options, false, command, args...)
+	out, err := runShellCommand(context.Background(), options, false, command, args...)
 	return out.Stdout(), err
 }
 
 // Run the specified shell command with the specified arguments. Return its stdout as a string and also stream stdout
 // and stderr to the OS stdout/stderr
 func RunShellCommandAndGetStdoutAndStreamOutput(options *ShellOptions, command string, args ...string) (string, error) {
-	out, err := runShellCommand(options, true, command, args...)
+	out, err := runShellCommand(context.Background(), options, true, command, args...)
 	return out.Stdout(), err
 }
 
 // Run the specified shell command with the specified arguments. Return its stdout, stderr, and interleaved output as a
 // struct and also stream stdout and stderr to the OS stdout/stderr
 func RunShellCommandAndGetOutputStructAndStreamOutput(options *ShellOptions, command string, args ...string) (*Output, error) {
-	return runShellCommand(options, true, command, args...)
+	return runShellCommand(context.Background(), options, true, command, args...)
+}
+
+// RunShellCommandAndGetOutputStructAndStreamOutputWithContext is like RunShellCommandAndGetOutputStructAndStreamOutput,
+// but terminates the command if the given context is done before it exits.
+func RunShellCommandAndGetOutputStructAndStreamOutputWithContext(ctx context.Context, options *ShellOptions, command string, args ...string) (*Output, error) {
+	return runShellCommand(ctx, options, true, command, args...)
 }
 
 // Run the specified shell command with the specified arguments. Return its stdout and stderr as a string and also
 // stream stdout and stderr to the OS stdout/stderr
-func runShellCommand(options *ShellOptions, streamOutput bool, command string, args ...string) (*Output, error) {
-	logCommand(options, command, args...)
-	cmd := exec.Command(command, args...)
+func runShellCommand(ctx context.Context, options *ShellOptions, streamOutput bool, command string, args ...string) (*Output, error) {
+	mode := StreamCapture
+	if streamOutput {
+		mode = StreamTee
+	}
+	return NewCommand(options, command, args...).WithStdout(mode).WithStderr(mode).RunWithContext(ctx)
+}
 
-	setCommandOptions(options, cmd)
+// runCommand starts the given command, calls whileRunning (if set), and waits for the command to exit. See
+// startCommand and runningCommand.wait for how the command is run, and which errors this returns.
+func runCommand(ctx context.Context, options *ShellOptions, cmd *exec.Cmd, output *Output, whileRunning func() error) error {
+	running, err := startCommand(ctx, options, cmd)
+	if err != nil {
+		return err
+	}
 
-	cmd.Stdin = os.Stdin
+	var readErr error
+	if whileRunning != nil {
+		readErr = whileRunning()
+	}
 
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		return nil, errors.WithStackTrace(err)
+	if err := running.wait(output); err != nil {
+		return err
 	}
+	return readErr
+}
 
-	stderr, err := cmd.StderrPipe()
-	if err != nil {
-		return nil, errors.WithStackTrace(err)
+// runningCommand is a command that was started with startCommand.
+type runningCommand struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	options *ShellOptions
+	cmd     *exec.Cmd
+	start   time.Time
+	exited  chan struct{}
+}
+
+// startCommand starts the given command. If the given context is done, or ShellOptions.Timeout runs out, before the
+// command exits, the command is sent SIGTERM, and then SIGKILL if it is still running after
+// ShellOptions.KillGracePeriod.
+//
+// Commands that can be cancelled run in a process group of their own, and the signals are sent to the whole group, so
+// that the processes they spawn (e.g. the providers run by terraform) are stopped too. As a consequence, such commands
+// don't get the signals sent by the terminal (e.g. on Ctrl-C) directly: cancel the context on those instead. The
+// exception is commands reading from the terminal, which must stay in the foreground process group to be able to do
+// so, so only the command itself is signalled.
+func startCommand(ctx context.Context, options *ShellOptions, cmd *exec.Cmd) (*runningCommand, error) {
+	cancel := context.CancelFunc(func() {})
+	if options.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, options.Timeout)
+	}
+
+	running := &runningCommand{
+		ctx:     ctx,
+		cancel:  cancel,
+		options: options,
+		cmd:     cmd,
+		start:   time.Now(),
+		exited:  make(chan struct{}),
+	}
+	if ctx.Err() != nil {
+		cancel()
+		return nil, running.contextError()
+	}
+
+	cancellable := ctx.Done() != nil
+	inProcessGroup := cancellable && !readsFromTerminal(cmd)
+	if inProcessGroup {
+		startInProcessGroup(cmd)
 	}
 
 	if err := cmd.Start(); err != nil {
+		cancel()
 		return nil, errors.WithStackTrace(err)
 	}
 
-	output, err := readStdoutAndStderr(
-		options.Logger.Logger,
-		streamOutput,
-		stdout,
-		stderr,
-	)
-	if err != nil {
-		return output, err
+	if cancellable {
+		go terminateWhenDone(ctx, options, cmd, inProcessGroup, running.exited)
 	}
+	return running, nil
+}
+
+// wait waits for the command to exit. If the command was terminated because its context was done, this returns a
+// CommandTimedOutError or a CommandCancelledError. If it failed, this returns a ShellCommandError, which includes the
+// end of the stderr captured in output (if set).
+func (running *runningCommand) wait(output *Output) error {
+	defer running.cancel()
+
+	err := running.cmd.Wait()
+	close(running.exited)
 
-	err = cmd.Wait()
-	return output, errors.WithStackTrace(err)
+	if err != nil && running.ctx.Err() != nil {
+		return running.contextError()
+	}
+
+	var exitErr *exec.ExitError
+	if goerrors.As(err, &exitErr) {
+		return errors.WithStackTrace(newShellCommandError(running.options, running.cmd, exitErr, running.start, output))
+	}
+	return errors.WithStackTrace(err)
+}
+
+// contextError returns the error to report for the command when it was terminated because its context is done.
+func (running *runningCommand) contextError() error {
+	command := filepath.Base(running.cmd.Path)
+	if running.ctx.Err() != context.DeadlineExceeded {
+		return errors.WithStackTrace(CommandCancelledError{Command: command})
+	}
+
+	timeout := running.options.Timeout
+	if deadline, hasDeadline := running.ctx.Deadline(); timeout == 0 && hasDeadline {
+		timeout = deadline.Sub(running.start).Round(time.Millisecond)
+	}
+	return errors.WithStackTrace(CommandTimedOutError{Command: command, Timeout: timeout})
+}
+
+// terminateWhenDone waits for the given context to be done, and then terminates the given command, escalating from
+// SIGTERM to SIGKILL after the grace period. This returns as soon as the exited channel is closed.
+func terminateWhenDone(ctx context.Context, options *ShellOptions, cmd *exec.Cmd, inProcessGroup bool, exited <-chan struct{}) {
+	select {
+	case <-exited:
+		return
+	case <-ctx.Done():
+	}
+
+	options.Logger.Warnf("Terminating command %s: %s", cmd.Path, ctx.Err())
+	if err := terminateCommand(cmd, inProcessGroup); err != nil {
+		options.Logger.Debugf("Error sending SIGTERM to command %s: %s", cmd.Path, err)
+	}
+
+	gracePeriod := time.NewTimer(killGracePeriod(options))
+	defer gracePeriod.Stop()
+
+	select {
+	case <-exited:
+	case <-gracePeriod.C:
+		options.Logger.Warnf("Command %s did not exit within %s, killing it", cmd.Path, killGracePeriod(options))
+		if err := killCommand(cmd, inProcessGroup); err != nil {
+			options.Logger.Debugf("Error sending SIGKILL to command %s: %s", cmd.Path, err)
+		}
+	}
+}
+
+func stderrTailLines(options *ShellOptions) int {
+	if options.StderrTailLines > 0 {
+		return options.StderrTailLines
+	}
+	return DefaultStderrTailLines
+}
+
+func killGracePeriod(options *ShellOptions) time.Duration {
+	if options.KillGracePeriod > 0 {
+		return options.KillGracePeriod
+	}
+	return DefaultKillGracePeriod
+}
+
+// readsFromTerminal returns true if the stdin of the given command is a terminal.
+func readsFromTerminal(cmd *exec.Cmd) bool {
+	stdin, isFile := cmd.Stdin.(*os.File)
+	return isFile && term.IsTerminal(int(stdin.Fd()))
 }
 
 func logCommand(options *ShellOptions, command string, args ...string) {
 	if options.SensitiveArgs {
 		options.Logger.Infof("Running command: %s (args redacted)", command)
 	} else {
-		options.Logger.Infof("Running command: %s %s", command, strings.Join(args, " "))
+		options.Logger.Infof("Running command: %s", redactor(options).Redact(command+" "+strings.Join(args, " ")))
 	}
 }
 
@@ -147,11 +295,75 @@ func setCommandOptions(options *ShellOptions, cmd *exec.Cmd) {
 }
 
 // formatEnvVars takes environment variables encoded into ShellOptions and converts them to a format understood by
-// exec.Command
+// exec.Command: the variables of the currently running app that the EnvMode keeps, minus the ones in EnvUnset, with
+// the ones in Env replacing those with the same name, or added at the end (in alphabetical order).
 func formatEnvVars(options *ShellOptions) []string {
-	env := os.Environ()
-	for key, value := range options.Env {
-		env = append(env, fmt.Sprintf("%s=%s", key, value))
+	env := []string{}
+	for _, keyValue := range os.Environ() {
+		key, _, _ := strings.Cut(keyValue, "=")
+		if inheritsEnvVar(options, key) {
+			env = append(env, keyValue)
+		}
+	}
+
+	keys := make([]string, 0, len(options.Env))
+	for key := range options.Env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		keyValue := fmt.Sprintf("%s=%s", key, options.Env[key])
+		index := indexOfEnvVar(env, key)
+		if index < 0 {
+			env = append(env, keyValue)
+		} else {
+			env[index] = keyValue
+		}
 	}
 	return env
 }
+
+// inheritsEnvVar returns true if a command run with the given options inherits the environment variable with the
+// given name from the currently running app.
+func inheritsEnvVar(options *ShellOptions, key string) bool {
+	for _, unset := range options.EnvUnset {
+		if envVarNamesEqual(unset, key) {
+			return false
+		}
+	}
+
+	switch options.EnvMode {
+	case EnvClean:
+		return false
+	case EnvAllowlist:
+		for _, allowed := range options.EnvAllowlist {
+			if envVarNamesEqual(allowed, key) {
+				return true
+			}
+		}
+		return false
+	default:
+		return true
+	}
+}
+
+// indexOfEnvVar returns the index of the environment variable with the given name in the given list of key=value
+// pairs, or -1 if it is not in the list.
+func indexOfEnvVar(env []string, key string) int {
+	for i, keyValue := range env {
+		if name, _, _ := strings.Cut(keyValue, "="); envVarNamesEqual(name, key) {
+			return i
+		}
+	}
+	return -1
+}
+
+// envVarNamesEqual returns true if the given environment variable names are the same, which is case insensitive on
+// Windows.
+func envVarNamesEqual(name1 string, name2 string) bool {
+	if runtime.GOOS == "windows" {
+		return strings.EqualFold(name1, name2)
+	}
+	return name1 == name2
+}
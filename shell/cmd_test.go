@@ -2,12 +2,22 @@ package shell
 
 import (
 	"bytes"
+	"context"
+	goerrors "errors"
 	"fmt"
+	"os"
+	"os/exec"
 	"path/filepath"
+	"strings"
+	"syscall"
 	"testing"
+	"time"
 
+	"github.com/gruntwork-io/go-commons/errors"
 	"github.com/gruntwork-io/go-commons/logging"
+	"github.com/gruntwork-io/terratest/modules/random"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestRunShellCommand(t *testing.T) {
@@ -99,6 +109,81 @@ func TestRunShellCommandWithEnv(t *testing.T) {
 	}
 }
 
+func TestFormatEnvVarsReplacesInheritedVars(t *testing.T) {
+	t.Parallel()
+
+	options := NewShellOptions()
+	options.Env = map[string]string{"PATH": "/custom/bin"}
+
+	env := formatEnvVars(options)
+	assert.Equal(t, len(os.Environ()), len(env))
+	assert.Equal(t, []string{"PATH=/custom/bin"}, envVarsNamed(env, "PATH"))
+}
+
+func TestFormatEnvVarsEnvModes(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name      string
+		mode      EnvMode
+		allowlist []string
+		unset     []string
+		expected  []string
+	}{
+		{"inherit", EnvInherit, nil, nil, os.Environ()},
+		{"inherit with unset", EnvInherit, nil, []string{"PATH"}, withoutEnvVar(os.Environ(), "PATH")},
+		{"clean", EnvClean, nil, nil, []string{}},
+		{"allowlist", EnvAllowlist, []string{"PATH", "NOT_A_REAL_ENV_VAR"}, nil, []string{"PATH=" + os.Getenv("PATH")}},
+		{"allowlist with unset", EnvAllowlist, []string{"PATH"}, []string{"PATH"}, []string{}},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			options := NewShellOptions()
+			options.EnvMode = testCase.mode
+			options.EnvAllowlist = testCase.allowlist
+			options.EnvUnset = testCase.unset
+			options.Env = map[string]string{"TEST_VAR": "value"}
+
+			assert.Equal(t, append(testCase.expected, "TEST_VAR=value"), formatEnvVars(options))
+		})
+	}
+}
+
+func TestCommandRunsInCleanEnv(t *testing.T) {
+	t.Parallel()
+
+	out, err := NewCommand(NewShellOptions(), "env").
+		WithEnvMode(EnvClean).
+		WithEnv("FOO", "bar").
+		Run()
+	require.NoError(t, err)
+	assert.Equal(t, "FOO=bar\n", out.Stdout())
+}
+
+func envVarsNamed(env []string, key string) []string {
+	named := []string{}
+	for _, keyValue := range env {
+		if strings.HasPrefix(keyValue, key+"=") {
+			named = append(named, keyValue)
+		}
+	}
+	return named
+}
+
+func withoutEnvVar(env []string, key string) []string {
+	without := []string{}
+	for _, keyValue := range env {
+		if !strings.HasPrefix(keyValue, key+"=") {
+			without = append(without, keyValue)
+		}
+	}
+	return without
+}
+
 func TestCommandInstalledOnValidCommand(t *testing.T) {
 	t.Parallel()
 
@@ -186,3 +271,100 @@ func TestSensitiveArgsFalseShowsOnRunShellCommandAndGetOutput(t *testing.T) {
 	assert.Contains(t, buffer.String(), "hi")
 	assert.Contains(t, buffer.String(), "echo")
 }
+
+func TestRunShellCommandTimesOut(t *testing.T) {
+	t.Parallel()
+
+	options := NewShellOptions()
+	options.Timeout = 100 * time.Millisecond
+
+	start := time.Now()
+	err := RunShellCommand(options, "sleep", "10")
+	require.Error(t, err)
+	timeoutErr, isTimeoutErr := errors.Unwrap(err).(CommandTimedOutError)
+	require.True(t, isTimeoutErr, "expected a CommandTimedOutError, got %v", err)
+	assert.Equal(t, "sleep", timeoutErr.Command)
+	assert.Equal(t, options.Timeout, timeoutErr.Timeout)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestRunShellCommandWithContextIsCancelled(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	err := RunShellCommandWithContext(ctx, NewShellOptions(), "sleep", "10")
+	require.Error(t, err)
+	assert.IsType(t, CommandCancelledError{}, errors.Unwrap(err))
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestRunShellCommandKillsCommandIgnoringSigterm(t *testing.T) {
+	t.Parallel()
+
+	options := NewShellOptions()
+	options.Timeout = 100 * time.Millisecond
+	options.KillGracePeriod = 100 * time.Millisecond
+
+	start := time.Now()
+	err := RunShellCommand(options, "bash", "-c", "trap '' TERM; sleep 10")
+	require.Error(t, err)
+	assert.IsType(t, CommandTimedOutError{}, errors.Unwrap(err))
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestRunShellCommandAndGetOutputStructWithContextTerminatesChildProcesses(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	// The background sleep inherits stdout, so reading the output only finishes once it is terminated too.
+	start := time.Now()
+	out, err := RunShellCommandAndGetOutputStructWithContext(ctx, NewShellOptions(), "bash", "-c", "echo started; sleep 10 & wait")
+	require.Error(t, err)
+	assert.IsType(t, CommandTimedOutError{}, errors.Unwrap(err))
+	assert.Equal(t, "started\n", out.Stdout())
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestFailingCommandReturnsShellCommandError(t *testing.T) {
+	t.Parallel()
+
+	secret := "secret-" + random.UniqueId()
+	options := NewShellOptions()
+	options.Redactor = logging.NewRedactor()
+	options.Redactor.AddSecret(secret)
+	options.StderrTailLines = 2
+
+	_, err := RunShellCommandAndGetOutput(options, "bash", "-c", `for i in 1 2 3; do echo "line $i" >&2; done; exit 3`, secret)
+	require.Error(t, err)
+
+	var shellErr ShellCommandError
+	require.True(t, goerrors.As(err, &shellErr), "expected a ShellCommandError, got %v", err)
+	assert.Equal(t, "bash", shellErr.Command)
+	assert.Equal(t, []string{"-c", `for i in 1 2 3; do echo "line $i" >&2; done; exit 3`, logging.RedactedText}, shellErr.Args)
+	assert.Equal(t, 3, shellErr.ExitCode)
+	assert.Equal(t, 3, shellErr.ExitStatus())
+	assert.Zero(t, shellErr.Signal)
+	assert.Positive(t, shellErr.Duration)
+	assert.Equal(t, []string{"line 2", "line 3"}, shellErr.StderrTail)
+	assert.Contains(t, err.Error(), "line 3")
+
+	var exitErr *exec.ExitError
+	assert.True(t, goerrors.As(err, &exitErr))
+}
+
+func TestKilledCommandReturnsShellCommandErrorWithSignal(t *testing.T) {
+	t.Parallel()
+
+	err := RunShellCommand(NewShellOptions(), "bash", "-c", "kill -KILL $$")
+	require.Error(t, err)
+
+	var shellErr ShellCommandError
+	require.True(t, goerrors.As(err, &shellErr), "expected a ShellCommandError, got %v", err)
+	assert.Equal(t, syscall.SIGKILL, shellErr.Signal)
+	assert.Equal(t, 128+9, shellErr.ExitStatus())
+}
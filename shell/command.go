@@ -0,0 +1,247 @@
+package shell
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/gruntwork-io/go-commons/errors"
+)
+
+// StreamMode is what to do with the stdout or stderr of a Command.
+type StreamMode int
+
+const (
+	// Capture the stream in the Output returned by Run. This is the default.
+	StreamCapture StreamMode = iota
+	// Capture the stream in the Output returned by Run, and also stream it line by line to the logger of the
+	// ShellOptions (or to ShellOptions.StreamWriter, if set).
+	StreamTee
+	// Throw the stream away.
+	StreamDiscard
+	// Connect the stream to the stdout or stderr of the currently running app. The command can then tell whether it
+	// is writing to a terminal, but the stream is neither captured nor redacted.
+	StreamInherit
+)
+
+// Command is a shell command to run, configured with the With* methods, which can be chained:
+//
+//	out, err := shell.NewCommand(options, "terraform", "output", "-json").
+//		WithWorkingDir("live/prod").
+//		WithStderr(shell.StreamTee).
+//		Run()
+//
+// By default, the command reads from the stdin of the currently running app, and its stdout and stderr are captured.
+type Command struct {
+	command string
+	args    []string
+	options ShellOptions
+	stdin   io.Reader
+	stdout  StreamMode
+	stderr  StreamMode
+	// Streams the output of the command. If nil, a new one is used on each run.
+	streamer *outputStreamer
+}
+
+// NewCommand returns a Command that runs the given command with the given arguments, with the given options. The
+// options are copied, so changing the Command doesn't change them.
+func NewCommand(options *ShellOptions, command string, args ...string) *Command {
+	cmd := &Command{
+		command: command,
+		args:    args,
+		options: *options,
+		stdin:   os.Stdin,
+	}
+	cmd.options.Env = map[string]string{}
+	for key, value := range options.Env {
+		cmd.options.Env[key] = value
+	}
+	cmd.options.EnvAllowlist = append([]string{}, options.EnvAllowlist...)
+	cmd.options.EnvUnset = append([]string{}, options.EnvUnset...)
+	return cmd
+}
+
+// WithStdin makes the command read its stdin from the given reader.
+func (cmd *Command) WithStdin(stdin io.Reader) *Command {
+	cmd.stdin = stdin
+	return cmd
+}
+
+// WithInput feeds the given content to the stdin of the command.
+func (cmd *Command) WithInput(input string) *Command {
+	return cmd.WithStdin(strings.NewReader(input))
+}
+
+// WithTerminalStdin makes the command read from the stdin of the currently running app, which is usually the terminal.
+// This is the default.
+func (cmd *Command) WithTerminalStdin() *Command {
+	return cmd.WithStdin(os.Stdin)
+}
+
+// WithStdout sets what to do with the stdout of the command.
+func (cmd *Command) WithStdout(mode StreamMode) *Command {
+	cmd.stdout = mode
+	return cmd
+}
+
+// WithStderr sets what to do with the stderr of the command.
+func (cmd *Command) WithStderr(mode StreamMode) *Command {
+	cmd.stderr = mode
+	return cmd
+}
+
+// WithWorkingDir sets the directory to run the command in, overriding ShellOptions.WorkingDir.
+func (cmd *Command) WithWorkingDir(workingDir string) *Command {
+	cmd.options.WorkingDir = workingDir
+	return cmd
+}
+
+// WithEnv sets an environment variable for the command, on top of ShellOptions.Env.
+func (cmd *Command) WithEnv(key string, value string) *Command {
+	cmd.options.Env[key] = value
+	return cmd
+}
+
+// WithoutEnv removes an environment variable that the command would otherwise inherit from the currently running app,
+// on top of ShellOptions.EnvUnset. This doesn't remove the variables set with ShellOptions.Env or WithEnv.
+func (cmd *Command) WithoutEnv(key string) *Command {
+	cmd.options.EnvUnset = append(cmd.options.EnvUnset, key)
+	return cmd
+}
+
+// WithEnvMode sets which environment variables of the currently running app the command inherits, overriding
+// ShellOptions.EnvMode.
+func (cmd *Command) WithEnvMode(mode EnvMode) *Command {
+	cmd.options.EnvMode = mode
+	return cmd
+}
+
+// Run runs the command and waits for it to exit. This returns the stdout and stderr of the command that were captured,
+// even if the command fails.
+func (cmd *Command) Run() (*Output, error) {
+	return cmd.RunWithContext(context.Background())
+}
+
+// RunWithContext is like Run, but terminates the command if the given context is done before it exits. See
+// RunShellCommandWithContext for details.
+//
+// The command is run by the Executor of its options (see ShellOptions.Executor), which runs it for real by default.
+func (cmd *Command) RunWithContext(ctx context.Context) (*Output, error) {
+	return executorFor(&cmd.options).Execute(ctx, cmd)
+}
+
+// Name returns the name (or path) of the command to run.
+func (cmd *Command) Name() string {
+	return cmd.command
+}
+
+// Args returns the arguments of the command.
+func (cmd *Command) Args() []string {
+	return append([]string{}, cmd.args...)
+}
+
+// WorkingDir returns the directory the command runs in.
+func (cmd *Command) WorkingDir() string {
+	return cmd.options.WorkingDir
+}
+
+// Env returns the environment variables set for the command, on top of the ones it inherits (see
+// ShellOptions.EnvMode).
+func (cmd *Command) Env() map[string]string {
+	env := map[string]string{}
+	for key, value := range cmd.options.Env {
+		env[key] = value
+	}
+	return env
+}
+
+// execute runs the command for real, as a child process. This is what RealExecutor does.
+func (cmd *Command) execute(ctx context.Context) (*Output, error) {
+	options := &cmd.options
+	execCmd := cmd.newExecCmd()
+
+	stdout, err := connectStream(cmd.stdout, os.Stdout, &execCmd.Stdout, execCmd.StdoutPipe)
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := connectStream(cmd.stderr, os.Stderr, &execCmd.Stderr, execCmd.StderrPipe)
+	if err != nil {
+		return nil, err
+	}
+
+	output := newOutput()
+	streamer := cmd.streamer
+	if streamer == nil {
+		streamer = &outputStreamer{options: options}
+	}
+	err = runCommand(ctx, options, execCmd, output, func() error {
+		return readStdoutAndStderr(
+			redactor(options),
+			stdout,
+			stderr,
+			output,
+			streamer.lineHandler(cmd.stdout == StreamTee, logrus.InfoLevel, options.StdoutCallback),
+			streamer.lineHandler(cmd.stderr == StreamTee, logrus.WarnLevel, options.StderrCallback),
+		)
+	})
+	return output, err
+}
+
+// simulateOutput returns the Output of the command as if it had written the given stdout and stderr, which are also
+// streamed according to the StreamModes and options of the command, just like when it runs for real. This is for the
+// executors that don't run commands for real.
+func (cmd *Command) simulateOutput(stdout string, stderr string) *Output {
+	options := &cmd.options
+	streamer := cmd.streamer
+	if streamer == nil {
+		streamer = &outputStreamer{options: options}
+	}
+
+	output := newOutput()
+	simulate := func(mode StreamMode, text string, inherit io.Writer, capture io.StringWriter, level logrus.Level, callback func(line string)) {
+		switch mode {
+		case StreamDiscard:
+		case StreamInherit:
+			fmt.Fprint(inherit, text)
+		default:
+			// Reading from a string can't fail.
+			readData(redactor(options), bufio.NewReader(strings.NewReader(text)), capture, streamer.lineHandler(mode == StreamTee, level, callback))
+		}
+	}
+	simulate(cmd.stdout, stdout, os.Stdout, output.stdout, logrus.InfoLevel, options.StdoutCallback)
+	simulate(cmd.stderr, stderr, os.Stderr, output.stderr, logrus.WarnLevel, options.StderrCallback)
+	return output
+}
+
+// newExecCmd logs the command and returns the exec.Cmd to run it with, with its streams left to connect.
+func (cmd *Command) newExecCmd() *exec.Cmd {
+	logCommand(&cmd.options, cmd.command, cmd.args...)
+	execCmd := exec.Command(cmd.command, cmd.args...)
+
+	setCommandOptions(&cmd.options, execCmd)
+
+	execCmd.Stdin = cmd.stdin
+	return execCmd
+}
+
+// connectStream connects a stream of a command according to the given mode, either by setting the writer it is
+// written to, or by opening a pipe to read it from, which is returned.
+func connectStream(mode StreamMode, inherit io.Writer, writer *io.Writer, pipe func() (io.ReadCloser, error)) (io.ReadCloser, error) {
+	switch mode {
+	case StreamDiscard:
+		*writer = nil
+		return nil, nil
+	case StreamInherit:
+		*writer = inherit
+		return nil, nil
+	default:
+		reader, err := pipe()
+		return reader, errors.WithStackTrace(err)
+	}
+}
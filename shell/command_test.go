@@ -0,0 +1,73 @@
+package shell
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommandCapturesStdoutAndStderr(t *testing.T) {
+	t.Parallel()
+
+	out, err := NewCommand(NewShellOptions(), filepath.Join("test-fixture", "echo_stdoutstderr.sh")).Run()
+	require.NoError(t, err)
+	assert.Equal(t, "hello\n", out.Stdout())
+	assert.Equal(t, "world\n", out.Stderr())
+	assert.Equal(t, "hello\nworld\n", out.Combined())
+}
+
+func TestCommandDiscardsStream(t *testing.T) {
+	t.Parallel()
+
+	out, err := NewCommand(NewShellOptions(), filepath.Join("test-fixture", "echo_stdoutstderr.sh")).
+		WithStderr(StreamDiscard).
+		Run()
+	require.NoError(t, err)
+	assert.Equal(t, "hello\n", out.Stdout())
+	assert.Equal(t, "", out.Stderr())
+	assert.Equal(t, "hello\n", out.Combined())
+}
+
+func TestCommandReadsStdin(t *testing.T) {
+	t.Parallel()
+
+	out, err := NewCommand(NewShellOptions(), "cat").WithInput("hello from stdin").Run()
+	require.NoError(t, err)
+	assert.Equal(t, "hello from stdin", out.Stdout())
+
+	out, err = NewCommand(NewShellOptions(), "cat").WithStdin(bytes.NewBufferString("hello from reader")).Run()
+	require.NoError(t, err)
+	assert.Equal(t, "hello from reader", out.Stdout())
+}
+
+func TestCommandWorkingDirAndEnv(t *testing.T) {
+	t.Parallel()
+
+	options := NewShellOptions()
+	options.Env = map[string]string{"FROM_OPTIONS": "options"}
+	workingDir := t.TempDir()
+
+	out, err := NewCommand(options, "bash", "-c", `echo "$(pwd) $FROM_OPTIONS $FROM_COMMAND"`).
+		WithWorkingDir(workingDir).
+		WithEnv("FROM_COMMAND", "command").
+		Run()
+	require.NoError(t, err)
+	resolvedDir, err := filepath.EvalSymlinks(workingDir)
+	require.NoError(t, err)
+	assert.Equal(t, resolvedDir+" options command\n", out.Stdout())
+
+	// The options passed to NewCommand are left untouched.
+	assert.Equal(t, ".", options.WorkingDir)
+	assert.Equal(t, map[string]string{"FROM_OPTIONS": "options"}, options.Env)
+}
+
+func TestCommandInheritedStreamsAreNotCaptured(t *testing.T) {
+	t.Parallel()
+
+	out, err := NewCommand(NewShellOptions(), "echo", "hi").WithStdout(StreamInherit).Run()
+	require.NoError(t, err)
+	assert.Equal(t, "", out.Stdout())
+}
@@ -0,0 +1,153 @@
+package shell
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gruntwork-io/go-commons/logging"
+)
+
+// ShellCommandError is returned when a command exits with a non-zero exit code, or is killed by a signal. It wraps the
+// *exec.ExitError of the command, so both can be retrieved with errors.As.
+type ShellCommandError struct {
+	// The command that was run, and its arguments, with secrets masked. The arguments are all masked if
+	// ShellOptions.SensitiveArgs is set.
+	Command string
+	Args    []string
+	// The exit code of the command, or -1 if it was killed by a signal
+	ExitCode int
+	// The signal that killed the command, if any
+	Signal syscall.Signal
+	// How long the command ran for
+	Duration time.Duration
+	// The last lines the command wrote to stderr (up to ShellOptions.StderrTailLines), if stderr was captured
+	StderrTail []string
+	Err        error
+}
+
+func (err ShellCommandError) Error() string {
+	message := fmt.Sprintf("Command %s exited with code %d after %s", err.Command, err.ExitCode, err.Duration)
+	if err.Signal != 0 {
+		message = fmt.Sprintf("Command %s was killed by signal %s after %s", err.Command, err.Signal, err.Duration)
+	}
+	if len(err.StderrTail) > 0 {
+		message += ". Last lines of stderr:\n" + strings.Join(err.StderrTail, "\n")
+	}
+	return message
+}
+
+// Unwrap returns the *exec.ExitError of the command.
+func (err ShellCommandError) Unwrap() error {
+	return err.Err
+}
+
+// ExitStatus returns the exit status to forward to the caller of the app that ran the command: its exit code, or 128
+// plus the number of the signal that killed it, as shells do. entrypoint.RunApp exits with this status when the app
+// fails with a ShellCommandError.
+func (err ShellCommandError) ExitStatus() int {
+	if err.Signal != 0 {
+		return 128 + int(err.Signal)
+	}
+	return err.ExitCode
+}
+
+// newShellCommandError returns the error to report for the given command, started at the given time, that failed with
+// the given error, whose output was captured in the given output (which may be nil).
+func newShellCommandError(options *ShellOptions, cmd *exec.Cmd, exitErr *exec.ExitError, start time.Time, output *Output) ShellCommandError {
+	shellErr := ShellCommandError{
+		ExitCode: exitErr.ExitCode(),
+		Duration: time.Since(start).Round(time.Millisecond),
+		Err:      exitErr,
+	}
+	if status, isWaitStatus := exitErr.Sys().(syscall.WaitStatus); isWaitStatus && status.Signaled() {
+		shellErr.Signal = status.Signal()
+	}
+	shellErr.setCommand(options, cmd.Args[0], cmd.Args[1:], output)
+	return shellErr
+}
+
+// newSimulatedShellCommandError returns the error for the given command exiting with the given exit code, for the
+// executors that don't run commands for real.
+func newSimulatedShellCommandError(cmd *Command, exitCode int, output *Output) ShellCommandError {
+	shellErr := ShellCommandError{ExitCode: exitCode}
+	shellErr.setCommand(&cmd.options, cmd.command, cmd.args, output)
+	return shellErr
+}
+
+// setCommand sets the command and arguments of the error, with secrets masked, and the end of the stderr captured in
+// output (if set).
+func (err *ShellCommandError) setCommand(options *ShellOptions, command string, args []string, output *Output) {
+	err.Command = redactor(options).Redact(command)
+	err.Args = redactArgs(options, args)
+	if output != nil {
+		err.StderrTail = output.stderr.tail(stderrTailLines(options))
+	}
+}
+
+// redactArgs returns the given arguments of a command with secrets masked. They are all masked if
+// ShellOptions.SensitiveArgs is set.
+func redactArgs(options *ShellOptions, args []string) []string {
+	var redacted []string
+	for _, arg := range args {
+		if options.SensitiveArgs {
+			arg = logging.RedactedText
+		}
+		redacted = append(redacted, redactor(options).Redact(arg))
+	}
+	return redacted
+}
+
+// CommandTimedOutError is returned when a command is terminated because it ran for longer than ShellOptions.Timeout,
+// or past the deadline of its context.
+type CommandTimedOutError struct {
+	Command string
+	// How long the command ran before it was terminated
+	Timeout time.Duration
+}
+
+func (err CommandTimedOutError) Error() string {
+	return fmt.Sprintf("Command %s timed out after %s", err.Command, err.Timeout)
+}
+
+// Unwrap returns context.DeadlineExceeded, so that callers can treat all timeouts alike with errors.Is.
+func (err CommandTimedOutError) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+// CommandCancelledError is returned when a command is terminated because its context was cancelled.
+type CommandCancelledError struct {
+	Command string
+}
+
+func (err CommandCancelledError) Error() string {
+	return fmt.Sprintf("Command %s was cancelled", err.Command)
+}
+
+// Unwrap returns context.Canceled, so that callers can treat all cancellations alike with errors.Is.
+func (err CommandCancelledError) Unwrap() error {
+	return context.Canceled
+}
+
+// UnexpectedCommandError is returned by a FakeExecutor when it is asked to run a command that none of its responses
+// match.
+type UnexpectedCommandError struct {
+	Command string
+	Args    []string
+}
+
+func (err UnexpectedCommandError) Error() string {
+	return fmt.Sprintf("Unexpected command: %s", strings.TrimSpace(err.Command+" "+strings.Join(err.Args, " ")))
+}
+
+// PtyNotSupportedError is returned when a command is run in a pseudo-terminal on an OS where that is not supported.
+type PtyNotSupportedError struct {
+	OS string
+}
+
+func (err PtyNotSupportedError) Error() string {
+	return fmt.Sprintf("Running commands in a pseudo-terminal is not supported on %s", err.OS)
+}
@@ -0,0 +1,68 @@
+package shell
+
+import (
+	"context"
+	"sync"
+)
+
+// Executor runs commands on behalf of all the functions of this package that run commands (NewCommand, the
+// RunShellCommand* functions, pipelines, etc). The default one, RealExecutor, runs them as child processes. Code that
+// runs commands can be unit tested without running real binaries by replacing it with a FakeExecutor, or with the
+// executor returned by LoadRecordings to replay commands saved by a Recorder.
+type Executor interface {
+	// Execute runs the given command and waits for it to exit, returning its captured output, and an error (e.g. a
+	// ShellCommandError) if it fails, just like Command.RunWithContext.
+	Execute(ctx context.Context, cmd *Command) (*Output, error)
+}
+
+// RealExecutor runs commands for real, as child processes. This is the default Executor.
+type RealExecutor struct{}
+
+func (executor RealExecutor) Execute(ctx context.Context, cmd *Command) (*Output, error) {
+	return cmd.execute(ctx)
+}
+
+var (
+	defaultExecutor      Executor = RealExecutor{}
+	defaultExecutorMutex sync.RWMutex
+)
+
+// SetDefaultExecutor sets the executor that runs the commands whose ShellOptions.Executor is not set, which includes
+// the commands run by other packages of this repo, such as git, and returns a function that restores the previous one.
+// As this is global, tests that set it must not run in parallel:
+//
+//	fake := shell.NewFakeExecutor()
+//	fake.On("git", "checkout", "main")
+//	defer shell.SetDefaultExecutor(fake)()
+func SetDefaultExecutor(executor Executor) func() {
+	defaultExecutorMutex.Lock()
+	defer defaultExecutorMutex.Unlock()
+
+	previous := defaultExecutor
+	defaultExecutor = executor
+	return func() {
+		SetDefaultExecutor(previous)
+	}
+}
+
+// GetDefaultExecutor returns the executor that runs the commands whose ShellOptions.Executor is not set.
+func GetDefaultExecutor() Executor {
+	defaultExecutorMutex.RLock()
+	defer defaultExecutorMutex.RUnlock()
+
+	return defaultExecutor
+}
+
+// executorFor returns the executor to run commands with the given options with.
+func executorFor(options *ShellOptions) Executor {
+	if options.Executor != nil {
+		return options.Executor
+	}
+	return GetDefaultExecutor()
+}
+
+// runsForReal returns true if commands run with the given options run as child processes.
+func runsForReal(options *ShellOptions) bool {
+	_, isReal := executorFor(options).(RealExecutor)
+	return isReal
+}
@@ -0,0 +1,241 @@
+package shell
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gruntwork-io/go-commons/errors"
+	"github.com/gruntwork-io/go-commons/logging"
+)
+
+func TestFakeExecutorReturnsCannedOutput(t *testing.T) {
+	t.Parallel()
+
+	fake := NewFakeExecutor()
+	fake.On("git", "rev-parse", "HEAD").Return("abc123\n").ReturnStderr("warning\n")
+
+	options := NewShellOptions()
+	options.Executor = fake
+	options.WorkingDir = "/repo"
+
+	out, err := RunShellCommandAndGetOutputStruct(options, "git", "rev-parse", "HEAD")
+	require.NoError(t, err)
+	assert.Equal(t, "abc123\n", out.Stdout())
+	assert.Equal(t, "warning\n", out.Stderr())
+
+	require.Len(t, fake.Calls(), 1)
+	assert.Equal(t, "git", fake.Calls()[0].Command)
+	assert.Equal(t, []string{"rev-parse", "HEAD"}, fake.Calls()[0].Args)
+	assert.Equal(t, "/repo", fake.Calls()[0].WorkingDir)
+}
+
+func TestFakeExecutorFailsCommands(t *testing.T) {
+	t.Parallel()
+
+	fake := NewFakeExecutor()
+	fake.On("git", "push").Fail(1, "rejected\n")
+
+	options := NewShellOptions()
+	options.Executor = fake
+
+	_, err := RunShellCommandAndGetOutput(options, "git", "push")
+	var shellErr ShellCommandError
+	require.ErrorAs(t, err, &shellErr)
+	assert.Equal(t, 1, shellErr.ExitCode)
+	assert.Equal(t, []string{"rejected"}, shellErr.StderrTail)
+
+	err = RunShellCommand(options, "git", "pull")
+	var unexpectedErr UnexpectedCommandError
+	require.ErrorAs(t, err, &unexpectedErr)
+	assert.Equal(t, UnexpectedCommandError{Command: "git", Args: []string{"pull"}}, unexpectedErr)
+}
+
+func TestFakeExecutorResponsesInOrder(t *testing.T) {
+	t.Parallel()
+
+	fake := NewFakeExecutor()
+	fake.On("date").Return("first\n").Once()
+	fake.OnMatch(func(call FakeCall) bool { return call.Command == "date" }).Return("later\n")
+
+	options := NewShellOptions()
+	options.Executor = fake
+
+	for _, expected := range []string{"first\n", "later\n", "later\n"} {
+		out, err := RunShellCommandAndGetStdout(options, "date")
+		require.NoError(t, err)
+		assert.Equal(t, expected, out)
+	}
+}
+
+func TestFakeExecutorRecordsStdinAndStreamsOutput(t *testing.T) {
+	t.Parallel()
+
+	fake := NewFakeExecutor()
+	fake.On("cat").Return("line 1\nline 2\n")
+
+	stream := &bytes.Buffer{}
+	options := NewShellOptions()
+	options.Executor = fake
+	options.StreamWriter = stream
+	options.OutputPrefix = "[cat] "
+
+	out, err := NewCommand(options, "cat").WithInput("some input").WithStdout(StreamTee).Run()
+	require.NoError(t, err)
+	assert.Equal(t, "line 1\nline 2\n", out.Stdout())
+	assert.Equal(t, "[cat] line 1\n[cat] line 2\n", stream.String())
+	assert.Equal(t, "some input", fake.Calls()[0].Stdin)
+}
+
+func TestFakeExecutorIsCancelled(t *testing.T) {
+	t.Parallel()
+
+	fake := NewFakeExecutor()
+	fake.On("sleep", "10")
+
+	options := NewShellOptions()
+	options.Executor = fake
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := RunShellCommandWithContext(ctx, options, "sleep", "10")
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestPipelineWithFakeExecutor(t *testing.T) {
+	t.Parallel()
+
+	fake := NewFakeExecutor()
+	fake.On("git", "log").Return("commit 2\ncommit 1\n")
+	fake.OnMatch(func(call FakeCall) bool { return call.Command == "head" }).Return("commit 2\n")
+
+	options := NewShellOptions()
+	options.Executor = fake
+
+	result, err := NewPipeline(NewCommand(options, "git", "log"), NewCommand(options, "head", "-n", "1")).Run()
+	require.NoError(t, err)
+	assert.Equal(t, "commit 2\n", result.Output.Stdout())
+	assert.Equal(t, []int{0, 0}, result.ExitStatuses)
+	assert.Equal(t, "commit 2\ncommit 1\n", fake.Calls()[1].Stdin)
+}
+
+func TestRecorderRecordsAndReplaysCommands(t *testing.T) {
+	t.Parallel()
+
+	redactor := logging.NewRedactor()
+	redactor.AddSecret("hunter2")
+	options := NewShellOptions()
+	options.Redactor = redactor
+
+	recorder := NewRecorder(RealExecutor{})
+	options.Executor = recorder
+
+	out, err := RunShellCommandAndGetOutputStruct(options, "bash", "-c", "echo password is hunter2; echo oops >&2", "hunter2")
+	require.NoError(t, err)
+	assert.Equal(t, "password is [REDACTED]\n", out.Stdout())
+	_, err = RunShellCommandAndGetOutput(options, "bash", "-c", "exit 3")
+	require.Error(t, err)
+
+	assert.Equal(t, []Recording{
+		{
+			Command: "bash",
+			Args:    []string{"-c", "echo password is [REDACTED]; echo oops >&2", "[REDACTED]"},
+			Stdout:  "password is [REDACTED]\n",
+			Stderr:  "oops\n",
+		},
+		{Command: "bash", Args: []string{"-c", "exit 3"}, ExitCode: 3},
+	}, recorder.Recordings())
+
+	fixture := filepath.Join(t.TempDir(), "testdata", "recordings.json")
+	require.NoError(t, recorder.Save(fixture))
+
+	replay, err := LoadRecordings(fixture)
+	require.NoError(t, err)
+	options.Executor = replay
+
+	out, err = RunShellCommandAndGetOutputStruct(options, "bash", "-c", "echo password is hunter2; echo oops >&2", "hunter2")
+	require.NoError(t, err)
+	assert.Equal(t, "password is [REDACTED]\n", out.Stdout())
+	assert.Equal(t, "oops\n", out.Stderr())
+
+	_, err = RunShellCommandAndGetOutput(options, "bash", "-c", "exit 3")
+	var shellErr ShellCommandError
+	require.ErrorAs(t, err, &shellErr)
+	assert.Equal(t, 3, shellErr.ExitCode)
+
+	// Each recording is only replayed once.
+	_, err = RunShellCommandAndGetOutput(options, "bash", "-c", "exit 3")
+	_, isUnexpected := errors.Unwrap(err).(UnexpectedCommandError)
+	assert.True(t, isUnexpected)
+}
+
+func TestRecorderReplaysTimeoutsAndCancellations(t *testing.T) {
+	t.Parallel()
+
+	recorder := NewRecorder(RealExecutor{})
+	options := NewShellOptions()
+	options.Executor = recorder
+	options.Timeout = 50 * time.Millisecond
+	_, err := NewCommand(options, "sleep", "5").Run()
+	require.ErrorAs(t, err, &CommandTimedOutError{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	options.Timeout = 0
+	_, err = NewCommand(options, "sleep", "1").RunWithContext(ctx)
+	require.ErrorAs(t, err, &CommandCancelledError{})
+
+	fixture := filepath.Join(t.TempDir(), "recordings.json")
+	require.NoError(t, recorder.Save(fixture))
+	replay, err := LoadRecordings(fixture)
+	require.NoError(t, err)
+	options.Executor = replay
+
+	_, err = NewCommand(options, "sleep", "5").Run()
+	var timedOutErr CommandTimedOutError
+	require.ErrorAs(t, err, &timedOutErr)
+	assert.Equal(t, 50*time.Millisecond, timedOutErr.Timeout)
+
+	_, err = NewCommand(options, "sleep", "1").Run()
+	assert.ErrorAs(t, err, &CommandCancelledError{})
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestRecorderWarnsAboutInheritedStreams(t *testing.T) {
+	t.Parallel()
+
+	buffer := &bytes.Buffer{}
+	options := NewShellOptions()
+	options.Logger = logging.GetLogger(t.Name(), "")
+	options.Logger.Logger.Out = buffer
+	recorder := NewRecorder(RealExecutor{})
+	options.Executor = recorder
+
+	require.NoError(t, RunShellCommand(options, "true"))
+	assert.Equal(t, []Recording{{Command: "true"}}, recorder.Recordings())
+	assert.Contains(t, buffer.String(), "Recording true without the output it writes straight to the terminal")
+
+	buffer.Reset()
+	_, err := RunShellCommandAndGetOutput(options, "true")
+	require.NoError(t, err)
+	assert.NotContains(t, buffer.String(), "without the output")
+}
+
+// This test sets the default executor, so it must not run in parallel.
+func TestSetDefaultExecutor(t *testing.T) {
+	fake := NewFakeExecutor()
+	fake.On("terraform", "version").Return("Terraform v1.9.0\n")
+
+	restore := SetDefaultExecutor(fake)
+	out, err := RunShellCommandAndGetStdout(NewShellOptions(), "terraform", "version")
+	restore()
+
+	require.NoError(t, err)
+	assert.Equal(t, "Terraform v1.9.0\n", out)
+	assert.Equal(t, RealExecutor{}, GetDefaultExecutor())
+}
@@ -0,0 +1,186 @@
+package shell
+
+import (
+	"context"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/gruntwork-io/go-commons/errors"
+)
+
+// FakeExecutor is an Executor for unit tests, which doesn't run commands, but returns the canned responses set up with
+// On and OnMatch, and remembers the commands it was asked to run:
+//
+//	fake := shell.NewFakeExecutor()
+//	fake.On("git", "rev-parse", "HEAD").Return("abc123\n")
+//	fake.On("git", "push").Fail(1, "rejected\n")
+//
+//	options := shell.NewShellOptions()
+//	options.Executor = fake
+//	sha, err := shell.RunShellCommandAndGetStdout(options, "git", "rev-parse", "HEAD")
+//
+// The canned output goes through the same redaction, streaming and callbacks as the output of real commands. Commands
+// that match no response fail with an UnexpectedCommandError. A FakeExecutor is safe to use from multiple goroutines.
+type FakeExecutor struct {
+	mutex     sync.Mutex
+	responses []*FakeResponse
+	calls     []FakeCall
+}
+
+// FakeCall is a command that a FakeExecutor was asked to run.
+type FakeCall struct {
+	Command    string
+	Args       []string
+	WorkingDir string
+	Env        map[string]string
+	// What the command got on stdin, unless it reads from a file (e.g. the terminal), which is left alone
+	Stdin string
+	// The arguments with secrets masked, as they are saved by a Recorder
+	redactedArgs []string
+}
+
+// FakeResponse is what a FakeExecutor returns for the commands that match it, which is configured with its methods.
+// By default, the commands succeed without any output.
+type FakeResponse struct {
+	matcher  func(call FakeCall) bool
+	stdout   string
+	stderr   string
+	exitCode int
+	err      error
+	// How many more commands the response can be used for, or -1 for any number
+	remaining int
+}
+
+// NewFakeExecutor returns a FakeExecutor with no responses.
+func NewFakeExecutor() *FakeExecutor {
+	return &FakeExecutor{}
+}
+
+// On adds a response for the commands with exactly the given name and arguments, and returns it to configure.
+func (fake *FakeExecutor) On(command string, args ...string) *FakeResponse {
+	return fake.OnMatch(func(call FakeCall) bool {
+		if call.Command != command || len(call.Args) != len(args) {
+			return false
+		}
+		for i, arg := range args {
+			if call.Args[i] != arg {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// OnMatch adds a response for the commands the given function returns true for, and returns it to configure.
+// Responses are tried in the order they were added, so add the more specific ones first.
+func (fake *FakeExecutor) OnMatch(matcher func(call FakeCall) bool) *FakeResponse {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	response := &FakeResponse{matcher: matcher, remaining: -1}
+	fake.responses = append(fake.responses, response)
+	return response
+}
+
+// Return makes the commands that match the response write the given stdout.
+func (response *FakeResponse) Return(stdout string) *FakeResponse {
+	response.stdout = stdout
+	return response
+}
+
+// ReturnStderr makes the commands that match the response write the given stderr.
+func (response *FakeResponse) ReturnStderr(stderr string) *FakeResponse {
+	response.stderr = stderr
+	return response
+}
+
+// Fail makes the commands that match the response write the given stderr and exit with the given exit code, so that
+// they fail with a ShellCommandError.
+func (response *FakeResponse) Fail(exitCode int, stderr string) *FakeResponse {
+	response.exitCode = exitCode
+	response.stderr = stderr
+	return response
+}
+
+// ReturnError makes the commands that match the response fail with the given error, e.g. to simulate a command that
+// can't be found.
+func (response *FakeResponse) ReturnError(err error) *FakeResponse {
+	response.err = err
+	return response
+}
+
+// Times makes the response match only the given number of commands, after which the next matching response is used.
+func (response *FakeResponse) Times(times int) *FakeResponse {
+	response.remaining = times
+	return response
+}
+
+// Once makes the response match only one command. See Times.
+func (response *FakeResponse) Once() *FakeResponse {
+	return response.Times(1)
+}
+
+// Calls returns the commands the FakeExecutor was asked to run so far, in order.
+func (fake *FakeExecutor) Calls() []FakeCall {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	return append([]FakeCall{}, fake.calls...)
+}
+
+func (fake *FakeExecutor) Execute(ctx context.Context, cmd *Command) (*Output, error) {
+	logCommand(&cmd.options, cmd.command, cmd.args...)
+
+	call := FakeCall{
+		Command:      cmd.command,
+		Args:         cmd.Args(),
+		WorkingDir:   cmd.WorkingDir(),
+		Env:          cmd.Env(),
+		redactedArgs: redactArgs(&cmd.options, cmd.args),
+	}
+	if _, isFile := cmd.stdin.(*os.File); !isFile && cmd.stdin != nil {
+		stdin, err := io.ReadAll(cmd.stdin)
+		if err != nil {
+			return nil, errors.WithStackTrace(err)
+		}
+		call.Stdin = string(stdin)
+	}
+
+	response := fake.respond(call)
+	if response == nil {
+		return newOutput(), errors.WithStackTrace(UnexpectedCommandError{Command: call.Command, Args: call.redactedArgs})
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		return newOutput(), errors.WithStackTrace(CommandTimedOutError{Command: cmd.command, Timeout: cmd.options.Timeout})
+	}
+	if ctx.Err() != nil {
+		return newOutput(), errors.WithStackTrace(CommandCancelledError{Command: cmd.command})
+	}
+	if response.err != nil {
+		return newOutput(), response.err
+	}
+
+	output := cmd.simulateOutput(response.stdout, response.stderr)
+	if response.exitCode != 0 {
+		return output, errors.WithStackTrace(newSimulatedShellCommandError(cmd, response.exitCode, output))
+	}
+	return output, nil
+}
+
+// respond records the given call, and returns the response for it, if any.
+func (fake *FakeExecutor) respond(call FakeCall) *FakeResponse {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+
+	fake.calls = append(fake.calls, call)
+	for _, response := range fake.responses {
+		if response.remaining != 0 && response.matcher(call) {
+			if response.remaining > 0 {
+				response.remaining--
+			}
+			return response
+		}
+	}
+	return nil
+}
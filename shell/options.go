@@ -1,16 +1,53 @@
 package shell
 
 import (
+	"io"
+	"time"
+
 	"github.com/gruntwork-io/go-commons/logging"
 	"github.com/sirupsen/logrus"
 )
 
+// DefaultKillGracePeriod is how long a command that is cancelled or times out gets to exit after SIGTERM, before it is
+// sent SIGKILL, when ShellOptions.KillGracePeriod is not set.
+const DefaultKillGracePeriod = 10 * time.Second
+
+// DefaultStderrTailLines is how many of the last lines of stderr a ShellCommandError includes, when
+// ShellOptions.StderrTailLines is not set.
+const DefaultStderrTailLines = 20
+
+// EnvMode is which environment variables of the currently running app a command inherits.
+type EnvMode int
+
+const (
+	// Inherit all the environment variables of the currently running app. This is the default.
+	EnvInherit EnvMode = iota
+	// Inherit none of the environment variables of the currently running app, so that the command only gets
+	// ShellOptions.Env.
+	EnvClean
+	// Inherit only the environment variables listed in ShellOptions.EnvAllowlist.
+	EnvAllowlist
+)
+
 type ShellOptions struct {
-	NonInteractive bool
-	Logger         *logrus.Entry
-	WorkingDir     string
-	SensitiveArgs  bool              // If true, will not log the arguments to the command
-	Env            map[string]string // Additional environment variables to set
+	NonInteractive  bool
+	Logger          *logrus.Entry
+	WorkingDir      string
+	SensitiveArgs   bool              // If true, will not log the arguments to the command
+	Env             map[string]string // Additional environment variables to set, which replace the inherited ones
+	EnvMode         EnvMode           // Which environment variables of the currently running app to inherit. Defaults to EnvInherit.
+	EnvAllowlist    []string          // The environment variables to inherit with EnvAllowlist, e.g. PATH and HOME
+	EnvUnset        []string          // Environment variables to remove from the inherited ones
+	Timeout         time.Duration     // If set, the command is terminated if it runs for longer than this
+	KillGracePeriod time.Duration     // How long a terminated command gets to exit before it is killed. Defaults to DefaultKillGracePeriod.
+	OutputPrefix    string            // Prefix for each line of streamed output, e.g. the command name or a host tag
+	StreamWriter    io.Writer         // If set, streamed output is written to this as is, rather than logged
+	StdoutCallback  func(line string) // Called with each line the command writes to stdout, when it is captured
+	StderrCallback  func(line string) // Called with each line the command writes to stderr, when it is captured
+	Redactor        *logging.Redactor // Masks secrets in the logged command and in its output. Defaults to the global registry of the logging package.
+	StderrTailLines int               // How many of the last lines of stderr to include in a ShellCommandError. Defaults to DefaultStderrTailLines.
+	Executor        Executor          // Runs the commands. Defaults to the executor set with SetDefaultExecutor, which runs them for real.
+	Answers         *Answers          // The answers to the prompts created with NewPrompt, by key, for when NonInteractive is set
 }
 
 func NewShellOptions() *ShellOptions {
@@ -20,5 +57,14 @@ func NewShellOptions() *ShellOptions {
 		WorkingDir:     ".",
 		SensitiveArgs:  false,
 		Env:            map[string]string{},
+		Redactor:       logging.GetRedactor(),
+	}
+}
+
+// redactor returns the registry of secrets to mask in the logs and output of commands run with the given options.
+func redactor(options *ShellOptions) *logging.Redactor {
+	if options.Redactor != nil {
+		return options.Redactor
 	}
+	return logging.GetRedactor()
 }
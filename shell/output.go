@@ -2,18 +2,21 @@ package shell
 
 // The structs and functions in this file are almost exactly the same as the version in terratest
 // (https://github.com/gruntwork-io/terratest/blob/37812f27666423c28ea22acb2bac2c80513dd318/modules/shell/output.go),
-// except this version does not trim newlines from the streamed and captured texts. This ensures that the newlines
+// except this version does not trim newlines from the captured texts. This ensures that the newlines
 // reflect exactly how the underlying shell commands outputted. Otherwise, the newline is always stripped out on the
 // last line, regardless of if the original command included it. That final terminating newline is more significant in
 // production CLI usage vs testing purposes.
 
 import (
 	"bufio"
+	"fmt"
 	"io"
 	"strings"
 	"sync"
 
 	"github.com/sirupsen/logrus"
+
+	"github.com/gruntwork-io/go-commons/logging"
 )
 
 // Output contains the output after runnig a command.
@@ -67,8 +70,27 @@ type outputStream struct {
 }
 
 func (st *outputStream) WriteString(s string) (n int, err error) {
+	// The lock of the merged stream also guards this stream, which the stages of a Pipeline write to concurrently.
+	st.merged.Lock()
+	defer st.merged.Unlock()
+
 	st.Lines = append(st.Lines, string(s))
-	return st.merged.WriteString(s)
+	st.merged.Lines = append(st.merged.Lines, string(s))
+	return len(s), nil
+}
+
+// tail returns the last n lines of the stream, without their terminating newlines.
+func (st *outputStream) tail(n int) []string {
+	lines := st.Lines
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+
+	tail := []string{}
+	for _, line := range lines {
+		tail = append(tail, strings.TrimRight(line, "\r\n"))
+	}
+	return tail
 }
 
 func (st *outputStream) String() string {
@@ -102,41 +124,39 @@ func (m *merged) WriteString(s string) (n int, err error) {
 	return len(s), nil
 }
 
-// This function captures stdout and stderr into the given variables while still printing it to the stdout and stderr
-// of this Go program.
+// This function captures stdout and stderr into the given output, calling the given functions (if set) with each line
+// of each stream. Secrets are masked with the given redactor before the lines are captured or passed on. Streams that
+// are nil are skipped.
 // This is almost exactly the same as
 // https://github.com/gruntwork-io/terratest/blob/37812f27666423c28ea22acb2bac2c80513dd318/modules/shell/command.go#L130,
-// except it uses a different logger.
-func readStdoutAndStderr(log *logrus.Logger, streamOutput bool, stdout, stderr io.ReadCloser) (*Output, error) {
-	out := newOutput()
-	stdoutReader := bufio.NewReader(stdout)
-	stderrReader := bufio.NewReader(stderr)
-
+// except the streaming is left to the callers.
+func readStdoutAndStderr(redactor *logging.Redactor, stdout, stderr io.ReadCloser, out *Output, onStdoutLine, onStderrLine func(line string)) error {
 	wg := &sync.WaitGroup{}
 
-	wg.Add(2)
 	var stdoutErr, stderrErr error
-	go func() {
-		defer wg.Done()
-		stdoutErr = readData(log, streamOutput, stdoutReader, out.stdout)
-	}()
-	go func() {
-		defer wg.Done()
-		stderrErr = readData(log, streamOutput, stderrReader, out.stderr)
-	}()
+	if stdout != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			stdoutErr = readData(redactor, bufio.NewReader(stdout), out.stdout, onStdoutLine)
+		}()
+	}
+	if stderr != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			stderrErr = readData(redactor, bufio.NewReader(stderr), out.stderr, onStderrLine)
+		}()
+	}
 	wg.Wait()
 
 	if stdoutErr != nil {
-		return out, stdoutErr
-	}
-	if stderrErr != nil {
-		return out, stderrErr
+		return stdoutErr
 	}
-
-	return out, nil
+	return stderrErr
 }
 
-func readData(log *logrus.Logger, streamOutput bool, reader *bufio.Reader, writer io.StringWriter) error {
+func readData(redactor *logging.Redactor, reader *bufio.Reader, writer io.StringWriter, onLine func(line string)) error {
 	var line string
 	var readErr error
 	for {
@@ -150,8 +170,9 @@ func readData(log *logrus.Logger, streamOutput bool, reader *bufio.Reader, write
 			break
 		}
 
-		if streamOutput {
-			log.Println(line)
+		line = redactor.Redact(line)
+		if onLine != nil {
+			onLine(line)
 		}
 		if _, err := writer.WriteString(line); err != nil {
 			return err
@@ -166,3 +187,73 @@ func readData(log *logrus.Logger, streamOutput bool, reader *bufio.Reader, write
 	}
 	return nil
 }
+
+// outputStreamer streams the lines of the output of a command as configured in ShellOptions: to the logger, with
+// stdout at info level and stderr at warn level, or else as is to ShellOptions.StreamWriter. Every line is prefixed
+// with ShellOptions.OutputPrefix.
+type outputStreamer struct {
+	options *ShellOptions
+	// ensure that the lines of stdout and stderr are not interleaved in the stream writer
+	mutex sync.Mutex
+	// If set, the lines are held back until flush is called, so that the output of the command can be streamed in one
+	// go, rather than interleaved with the output of other commands running at the same time.
+	grouped bool
+	lines   []streamedLine
+}
+
+type streamedLine struct {
+	line  string
+	level logrus.Level
+}
+
+// lineHandler returns the function to call with each line of a stream of a command, which streams the line at the
+// given level if stream is set, and calls the given callback (if set) with the line, without its terminating newline.
+func (streamer *outputStreamer) lineHandler(stream bool, level logrus.Level, callback func(line string)) func(line string) {
+	if !stream && callback == nil {
+		return nil
+	}
+
+	return func(line string) {
+		if stream {
+			streamer.streamLine(line, level)
+		}
+		if callback != nil {
+			callback(strings.TrimRight(line, "\r\n"))
+		}
+	}
+}
+
+func (streamer *outputStreamer) streamLine(line string, level logrus.Level) {
+	streamer.mutex.Lock()
+	defer streamer.mutex.Unlock()
+
+	if streamer.grouped {
+		streamer.lines = append(streamer.lines, streamedLine{line: line, level: level})
+		return
+	}
+	streamer.writeLine(line, level)
+}
+
+// flush streams the lines held back so far.
+func (streamer *outputStreamer) flush() {
+	streamer.mutex.Lock()
+	defer streamer.mutex.Unlock()
+
+	for _, line := range streamer.lines {
+		streamer.writeLine(line.line, line.level)
+	}
+	streamer.lines = nil
+}
+
+func (streamer *outputStreamer) writeLine(line string, level logrus.Level) {
+	prefix := streamer.options.OutputPrefix
+
+	if streamer.options.StreamWriter != nil {
+		// Errors writing to the stream are ignored, just like errors writing to the log.
+		fmt.Fprint(streamer.options.StreamWriter, prefix+line)
+		return
+	}
+
+	// The logger terminates each entry with a newline, so the one of the line must be dropped.
+	streamer.options.Logger.Log(level, prefix+strings.TrimRight(line, "\r\n"))
+}
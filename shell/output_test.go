@@ -0,0 +1,91 @@
+package shell
+
+import (
+	"bytes"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/random"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gruntwork-io/go-commons/logging"
+)
+
+func TestStreamedOutputIsLoggedOncePerLineWithLevels(t *testing.T) {
+	t.Parallel()
+
+	buffer := &bytes.Buffer{}
+	options := NewShellOptions()
+	options.Logger = logging.GetLogger("", "")
+	options.Logger.Logger.Out = buffer
+	options.OutputPrefix = "[fixture] "
+
+	_, err := RunShellCommandAndGetAndStreamOutput(options, filepath.Join("test-fixture", "echo_stdoutstderr.sh"))
+	require.NoError(t, err)
+	assert.Contains(t, buffer.String(), `level=info msg="[fixture] hello"`)
+	assert.Contains(t, buffer.String(), `level=warning msg="[fixture] world"`)
+	assert.NotContains(t, buffer.String(), `\n"`)
+}
+
+func TestStreamedOutputIsWrittenToStreamWriter(t *testing.T) {
+	t.Parallel()
+
+	buffer := &bytes.Buffer{}
+	options := NewShellOptions()
+	options.StreamWriter = buffer
+	options.OutputPrefix = "[host-1] "
+
+	out, err := NewCommand(options, "printf", `one\ntwo`).WithStdout(StreamTee).Run()
+	require.NoError(t, err)
+	assert.Equal(t, "[host-1] one\n[host-1] two", buffer.String())
+	assert.Equal(t, "one\ntwo", out.Stdout())
+}
+
+func TestOutputLineCallbacks(t *testing.T) {
+	t.Parallel()
+
+	mutex := sync.Mutex{}
+	stdoutLines := []string{}
+	stderrLines := []string{}
+	options := NewShellOptions()
+	options.StdoutCallback = func(line string) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		stdoutLines = append(stdoutLines, line)
+	}
+	options.StderrCallback = func(line string) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		stderrLines = append(stderrLines, line)
+	}
+
+	_, err := RunShellCommandAndGetOutput(options, "bash", "-c", "echo one; echo two; echo three >&2")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"one", "two"}, stdoutLines)
+	assert.Equal(t, []string{"three"}, stderrLines)
+}
+
+func TestSecretsAreRedactedInLogsAndOutput(t *testing.T) {
+	t.Parallel()
+
+	secret := "secret-" + random.UniqueId()
+	redactor := logging.NewRedactor()
+	redactor.AddSecret(secret)
+
+	buffer := &bytes.Buffer{}
+	options := NewShellOptions()
+	options.Redactor = redactor
+	// A logger without the hook of the global registry, to check that the shell package masks secrets by itself.
+	options.Logger = logrus.NewEntry(logrus.New())
+	options.Logger.Logger.Out = buffer
+
+	out, err := RunShellCommandAndGetOutputStructAndStreamOutput(options, "bash", "-c", `echo "token=$0"; echo "token=$0" >&2`, secret)
+	require.NoError(t, err)
+	assert.Equal(t, "token=[REDACTED]\n", out.Stdout())
+	assert.Equal(t, "token=[REDACTED]\n", out.Stderr())
+	assert.Contains(t, buffer.String(), "Running command: bash")
+	assert.NotContains(t, buffer.String(), secret)
+}
@@ -0,0 +1,107 @@
+package shell
+
+import (
+	"context"
+	"runtime"
+	"sync"
+
+	multierror "github.com/hashicorp/go-multierror"
+)
+
+// ParallelOptions configures how RunCommandsInParallel runs a batch of commands.
+type ParallelOptions struct {
+	Concurrency int  // The maximum number of commands to run at once. Defaults to the number of CPUs.
+	FailFast    bool // If true, the commands still running are cancelled, and the others skipped, as soon as one fails
+}
+
+// CommandResult is the result of one of the commands run by RunCommandsInParallel.
+type CommandResult struct {
+	Command *Command
+	Output  *Output // The output of the command that was captured, which is nil if the command was skipped
+	Err     error   // The error the command failed with, if any
+	Skipped bool    // True if the command was never started, because another one failed with FailFast set, or the context is done
+}
+
+// RunCommandsInParallel runs the given commands, with at most ParallelOptions.Concurrency of them at once, and waits
+// for them all to finish, e.g. to run the same command in many module directories:
+//
+//	commands := []*shell.Command{}
+//	for _, dir := range moduleDirs {
+//		commands = append(commands, shell.NewCommand(options, "terraform", "validate").WithWorkingDir(dir).WithStdout(shell.StreamTee))
+//	}
+//	results, err := shell.RunCommandsInParallel(shell.ParallelOptions{Concurrency: 4}, commands...)
+//
+// The output each command streams (see StreamTee) is held back until the command exits, and then streamed in one go,
+// so that the output of different commands is grouped together rather than interleaved. Setting a different
+// ShellOptions.OutputPrefix for each command makes it easy to tell them apart. Note that StdoutCallback and
+// StderrCallback are still called as soon as each line is read, and that streams connected with StreamInherit can't be
+// grouped.
+//
+// This returns the result of each command, in the same order as the commands, along with a multierror with the errors
+// of all the commands that failed, if any.
+func RunCommandsInParallel(options ParallelOptions, commands ...*Command) ([]CommandResult, error) {
+	return RunCommandsInParallelWithContext(context.Background(), options, commands...)
+}
+
+// RunCommandsInParallelWithContext is like RunCommandsInParallel, but terminates the commands still running, and skips
+// the others, if the given context is done before they all finish.
+func RunCommandsInParallelWithContext(ctx context.Context, options ParallelOptions, commands ...*Command) ([]CommandResult, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	concurrency := options.Concurrency
+	if concurrency <= 0 {
+		concurrency = runtime.NumCPU()
+	}
+	slots := make(chan struct{}, concurrency)
+
+	results := make([]CommandResult, len(commands))
+	// ensure that the grouped output of the commands is streamed one command at a time
+	flushMutex := &sync.Mutex{}
+	wg := &sync.WaitGroup{}
+
+	for i, command := range commands {
+		results[i] = CommandResult{Command: command, Skipped: true}
+
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			continue
+		}
+		// The context may be done even though a slot was free.
+		if ctx.Err() != nil {
+			<-slots
+			continue
+		}
+
+		wg.Add(1)
+		go func(result *CommandResult) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			// Run a copy of the command, so that the streamer holding back its output is not shared.
+			grouped := *command
+			grouped.streamer = &outputStreamer{options: &grouped.options, grouped: true}
+
+			result.Skipped = false
+			result.Output, result.Err = grouped.RunWithContext(ctx)
+
+			flushMutex.Lock()
+			grouped.streamer.flush()
+			flushMutex.Unlock()
+
+			if result.Err != nil && options.FailFast {
+				cancel()
+			}
+		}(&results[i])
+	}
+	wg.Wait()
+
+	var allErrs *multierror.Error
+	for _, result := range results {
+		if result.Err != nil {
+			allErrs = multierror.Append(allErrs, result.Err)
+		}
+	}
+	return results, allErrs.ErrorOrNil()
+}
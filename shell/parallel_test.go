@@ -0,0 +1,135 @@
+package shell
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	multierror "github.com/hashicorp/go-multierror"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunCommandsInParallelReturnsResultsInOrder(t *testing.T) {
+	t.Parallel()
+
+	options := NewShellOptions()
+	commands := []*Command{}
+	for i := 0; i < 5; i++ {
+		commands = append(commands, NewCommand(options, "bash", "-c", fmt.Sprintf("sleep 0.%d; echo %d", 5-i, i)))
+	}
+
+	results, err := RunCommandsInParallel(ParallelOptions{Concurrency: 5}, commands...)
+	require.NoError(t, err)
+	require.Len(t, results, 5)
+	for i, result := range results {
+		assert.Equal(t, commands[i], result.Command)
+		assert.Equal(t, fmt.Sprintf("%d\n", i), result.Output.Stdout())
+		assert.False(t, result.Skipped)
+		assert.NoError(t, result.Err)
+	}
+}
+
+func TestRunCommandsInParallelLimitsConcurrency(t *testing.T) {
+	t.Parallel()
+
+	mutex := sync.Mutex{}
+	running := 0
+	maxRunning := 0
+
+	options := NewShellOptions()
+	options.StdoutCallback = func(line string) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if line == "start" {
+			running++
+			if running > maxRunning {
+				maxRunning = running
+			}
+		} else {
+			running--
+		}
+	}
+
+	commands := []*Command{}
+	for i := 0; i < 6; i++ {
+		commands = append(commands, NewCommand(options, "bash", "-c", "echo start; sleep 0.3; echo end"))
+	}
+
+	_, err := RunCommandsInParallel(ParallelOptions{Concurrency: 2}, commands...)
+	require.NoError(t, err)
+	assert.Equal(t, 2, maxRunning)
+}
+
+func TestRunCommandsInParallelGroupsOutput(t *testing.T) {
+	t.Parallel()
+
+	stream := &bytes.Buffer{}
+	commands := []*Command{}
+	for _, name := range []string{"a", "b", "c"} {
+		options := NewShellOptions()
+		options.StreamWriter = stream
+		options.OutputPrefix = name + ": "
+		commands = append(commands, NewCommand(options, "bash", "-c", "for i in 1 2 3; do echo $i; sleep 0.1; done").WithStdout(StreamTee))
+	}
+
+	_, err := RunCommandsInParallel(ParallelOptions{Concurrency: 3}, commands...)
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSuffix(stream.String(), "\n"), "\n")
+	require.Len(t, lines, 9)
+	for group := 0; group < 3; group++ {
+		prefix := strings.SplitN(lines[group*3], ":", 2)[0]
+		for i := 0; i < 3; i++ {
+			assert.Equal(t, fmt.Sprintf("%s: %d", prefix, i+1), lines[group*3+i])
+		}
+	}
+}
+
+func TestRunCommandsInParallelAggregatesErrors(t *testing.T) {
+	t.Parallel()
+
+	options := NewShellOptions()
+	results, err := RunCommandsInParallel(
+		ParallelOptions{Concurrency: 3},
+		NewCommand(options, "bash", "-c", "exit 1"),
+		NewCommand(options, "true"),
+		NewCommand(options, "bash", "-c", "exit 2"),
+	)
+	require.Error(t, err)
+
+	multiErr, isMultiErr := err.(*multierror.Error)
+	require.True(t, isMultiErr, "expected a multierror, got %T", err)
+	assert.Len(t, multiErr.Errors, 2)
+
+	assert.Error(t, results[0].Err)
+	assert.NoError(t, results[1].Err)
+	assert.Error(t, results[2].Err)
+}
+
+func TestRunCommandsInParallelFailFast(t *testing.T) {
+	t.Parallel()
+
+	options := NewShellOptions()
+	options.KillGracePeriod = time.Second
+
+	start := time.Now()
+	results, err := RunCommandsInParallel(
+		ParallelOptions{Concurrency: 2, FailFast: true},
+		NewCommand(options, "sleep", "30"),
+		NewCommand(options, "bash", "-c", "sleep 0.2; exit 1"),
+		NewCommand(options, "sleep", "30"),
+	)
+	require.Error(t, err)
+	assert.Less(t, time.Since(start), 10*time.Second)
+
+	var cancelledErr CommandCancelledError
+	assert.ErrorAs(t, results[0].Err, &cancelledErr)
+	var shellErr ShellCommandError
+	assert.ErrorAs(t, results[1].Err, &shellErr)
+	assert.True(t, results[2].Skipped)
+	assert.Nil(t, results[2].Output)
+}
@@ -0,0 +1,162 @@
+package shell
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+
+	"golang.org/x/term"
+
+	"github.com/gruntwork-io/go-commons/errors"
+)
+
+// ConfirmPasswordPrompt is the prompt the user gets to enter a new password a second time.
+const ConfirmPasswordPrompt = "Confirm password: "
+
+// MaxPasswordAttempts is how many times the user gets to enter a new password that is valid and matches its
+// confirmation before FPromptUserForPasswordWithConfirmation gives up.
+const MaxPasswordAttempts = 3
+
+// Prompt the user for a new password, and then for it again to confirm it. See
+// FPromptUserForPasswordWithConfirmation.
+func PromptUserForPasswordWithConfirmation(prompt string, validator func(password string) error, options *ShellOptions) (string, error) {
+	return FPromptUserForPasswordWithConfirmation(os.Stdout, os.Stdin, int(os.Stdin.Fd()), prompt, validator, options)
+}
+
+// FPromptUserForPasswordWithConfirmation prompts the user for a new password, and then for it again to confirm it,
+// reading them like FPromptUserForPassword. If the given validator (e.g. PasswordRequirements.Validate) rejects the
+// password, or the confirmation doesn't match, the user is told why and prompted again, up to MaxPasswordAttempts
+// times, after which the last error is returned (a PasswordMismatchError for a mismatch). As there is no answer for
+// this prompt when the non-interactive flag is set, this returns a NonInteractivePromptError then.
+func FPromptUserForPasswordWithConfirmation(out io.Writer, in io.Reader, fd int, prompt string, validator func(password string) error, options *ShellOptions) (string, error) {
+	if options.NonInteractive {
+		return NewPrompt("", prompt).WithValidator(validator).FAskPassword(out, in, fd, options)
+	}
+
+	password := ""
+	err := readUntilValid(out, in, MaxPasswordAttempts, func(reader *bufio.Reader, attempt int) (*invalidAnswer, error) {
+		BRIGHT_GREEN.Fprint(out, prompt)
+		text, err := readPassword(out, reader, fd)
+		if err != nil {
+			return nil, err
+		}
+
+		if validator != nil {
+			if err := validator(text); err != nil {
+				return newInvalidAnswer("Invalid password", err), nil
+			}
+		}
+
+		BRIGHT_GREEN.Fprint(out, ConfirmPasswordPrompt)
+		confirmation, err := readPassword(out, reader, fd)
+		if err != nil {
+			return nil, err
+		}
+		if text != confirmation {
+			return &invalidAnswer{message: "Passwords do not match", err: PasswordMismatchError{Attempts: attempt}}, nil
+		}
+
+		password = text
+		return nil, nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	registerSecret(options, password)
+	return password, nil
+}
+
+// readPassword reads a password from the given terminal file descriptor without echoing it, or else from the given
+// reader, up to the end of the line. Unlike other answers, whitespace is kept, as it may be part of the password.
+func readPassword(out io.Writer, reader *bufio.Reader, fd int) (string, error) {
+	if term.IsTerminal(fd) {
+		password, err := term.ReadPassword(fd)
+		// The newline the user entered is not echoed either.
+		fmt.Fprintln(out)
+		if err != nil {
+			return "", errors.WithStackTrace(err)
+		}
+		return string(password), nil
+	}
+
+	text, err := reader.ReadString('\n')
+	if err != nil {
+		return "", errors.WithStackTrace(err)
+	}
+	return strings.TrimRight(text, "\r\n"), nil
+}
+
+// PasswordRequirements are the requirements for a strong password, whose Validate method can be used as the validator
+// of password prompts:
+//
+//	requirements := shell.PasswordRequirements{MinLength: 12, RequireDigit: true}
+//	password, err := shell.PromptUserForPasswordWithConfirmation("New password: ", requirements.Validate, options)
+type PasswordRequirements struct {
+	MinLength     int
+	RequireUpper  bool // If true, the password must contain an upper case letter
+	RequireLower  bool // If true, the password must contain a lower case letter
+	RequireDigit  bool // If true, the password must contain a digit
+	RequireSymbol bool // If true, the password must contain a character that is neither a letter, a digit nor a space
+}
+
+// Validate returns a WeakPasswordError listing the requirements the given password doesn't meet, if any.
+func (requirements PasswordRequirements) Validate(password string) error {
+	hasUpper, hasLower, hasDigit, hasSymbol := false, false, false, false
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case !unicode.IsLetter(r) && !unicode.IsSpace(r):
+			hasSymbol = true
+		}
+	}
+
+	problems := []string{}
+	if length := len([]rune(password)); length < requirements.MinLength {
+		problems = append(problems, fmt.Sprintf("be at least %d characters long", requirements.MinLength))
+	}
+	if requirements.RequireUpper && !hasUpper {
+		problems = append(problems, "contain an upper case letter")
+	}
+	if requirements.RequireLower && !hasLower {
+		problems = append(problems, "contain a lower case letter")
+	}
+	if requirements.RequireDigit && !hasDigit {
+		problems = append(problems, "contain a digit")
+	}
+	if requirements.RequireSymbol && !hasSymbol {
+		problems = append(problems, "contain a symbol")
+	}
+
+	if len(problems) > 0 {
+		return errors.WithStackTrace(WeakPasswordError{Problems: problems})
+	}
+	return nil
+}
+
+// WeakPasswordError is returned when a password doesn't meet the PasswordRequirements.
+type WeakPasswordError struct {
+	// What the password must do to meet the requirements, e.g. "contain a digit"
+	Problems []string
+}
+
+func (err WeakPasswordError) Error() string {
+	return fmt.Sprintf("The password must %s", strings.Join(err.Problems, ", and "))
+}
+
+// PasswordMismatchError is returned when the user fails to enter the same new password twice.
+type PasswordMismatchError struct {
+	Attempts int
+}
+
+func (err PasswordMismatchError) Error() string {
+	return fmt.Sprintf("The passwords did not match after %d attempts", err.Attempts)
+}
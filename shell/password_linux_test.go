@@ -0,0 +1,52 @@
+//go:build linux
+
+package shell
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/creack/pty"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+)
+
+func TestFPromptUserForPasswordDoesNotEchoOnTerminal(t *testing.T) {
+	t.Parallel()
+
+	ptmx, tty, err := pty.Open()
+	require.NoError(t, err)
+	defer ptmx.Close()
+	defer tty.Close()
+
+	type result struct {
+		password string
+		err      error
+	}
+	results := make(chan result, 1)
+	out := &bytes.Buffer{}
+	go func() {
+		password, err := FPromptUserForPassword(out, bytes.NewBufferString("not from here\n"), int(tty.Fd()), "Password: ", NewShellOptions())
+		results <- result{password, err}
+	}()
+
+	// Type the password once the prompt has turned off the echo of the terminal.
+	require.Eventually(t, func() bool {
+		termios, err := unix.IoctlGetTermios(int(tty.Fd()), unix.TCGETS)
+		return err == nil && termios.Lflag&unix.ECHO == 0
+	}, 5*time.Second, 10*time.Millisecond)
+	_, err = ptmx.Write([]byte("hill valley\n"))
+	require.NoError(t, err)
+
+	res := <-results
+	require.NoError(t, res.err)
+	assert.Equal(t, "hill valley", res.password)
+	assert.Equal(t, "Password: \n", out.String())
+
+	tty.Close()
+	echoed, _ := io.ReadAll(ptmx)
+	assert.NotContains(t, string(echoed), "hill valley")
+}
@@ -0,0 +1,113 @@
+package shell
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gruntwork-io/go-commons/logging"
+)
+
+// A file descriptor that is not a terminal, so that passwords are read from the reader.
+const notATerminal = -1
+
+func TestFPromptUserForPasswordReadsFromReader(t *testing.T) {
+	t.Parallel()
+
+	options := NewShellOptions()
+	options.Redactor = logging.NewRedactor()
+	out := &bytes.Buffer{}
+
+	password, err := FPromptUserForPassword(out, bytes.NewBufferString(" 88 miles per hour \n"), notATerminal, "Password: ", options)
+	require.NoError(t, err)
+	assert.Equal(t, " 88 miles per hour ", password)
+	assert.Contains(t, out.String(), "Password: ")
+	assert.Equal(t, "speed:[REDACTED]", options.Redactor.Redact("speed: 88 miles per hour "))
+}
+
+func TestFPromptUserForPasswordOnNonInteractive(t *testing.T) {
+	t.Parallel()
+
+	options := NewShellOptions()
+	options.NonInteractive = true
+
+	_, err := FPromptUserForPassword(&bytes.Buffer{}, bytes.NewBufferString("secret\n"), notATerminal, "Password: ", options)
+	var nonInteractiveErr NonInteractivePromptError
+	assert.ErrorAs(t, err, &nonInteractiveErr)
+	assert.ErrorIs(t, err, NonInteractivePasswordPrompt)
+
+	_, err = FPromptUserForPasswordWithConfirmation(&bytes.Buffer{}, bytes.NewBufferString("secret\nsecret\n"), notATerminal, "New password: ", nil, options)
+	assert.ErrorAs(t, err, &nonInteractiveErr)
+	assert.ErrorIs(t, err, NonInteractivePasswordPrompt)
+
+	// Other prompts never returned NonInteractivePasswordPrompt.
+	_, err = FPromptUserForInput(&bytes.Buffer{}, bytes.NewBufferString("text\n"), "Text: ", options)
+	assert.ErrorAs(t, err, &nonInteractiveErr)
+	assert.NotErrorIs(t, err, NonInteractivePasswordPrompt)
+}
+
+func TestFAskPasswordOnlyRedactsAcceptedPassword(t *testing.T) {
+	t.Parallel()
+
+	requirements := PasswordRequirements{MinLength: 8}
+	options := NewShellOptions()
+	options.Redactor = logging.NewRedactor()
+
+	password, err := NewPrompt("password", "Password: ").WithValidator(requirements.Validate).FAskPassword(&bytes.Buffer{}, bytes.NewBufferString("doc\nemmett-brown\n"), notATerminal, options)
+	require.NoError(t, err)
+	assert.Equal(t, "emmett-brown", password)
+	assert.Equal(t, "doc is [REDACTED]", options.Redactor.Redact("doc is emmett-brown"))
+}
+
+func TestFPromptUserForPasswordWithConfirmationRetriesOnMismatch(t *testing.T) {
+	t.Parallel()
+
+	out := &bytes.Buffer{}
+	in := bytes.NewBufferString("flux-capacitor\nflux-capacitr\nflux-capacitor\nflux-capacitor\n")
+
+	password, err := FPromptUserForPasswordWithConfirmation(out, in, notATerminal, "New password: ", nil, NewShellOptions())
+	require.NoError(t, err)
+	assert.Equal(t, "flux-capacitor", password)
+	assert.Contains(t, out.String(), "Passwords do not match")
+}
+
+func TestFPromptUserForPasswordWithConfirmationChecksStrength(t *testing.T) {
+	t.Parallel()
+
+	requirements := PasswordRequirements{MinLength: 8, RequireUpper: true, RequireDigit: true}
+	out := &bytes.Buffer{}
+	in := bytes.NewBufferString("short\nGigawatts1\nGigawatts1\n")
+
+	password, err := FPromptUserForPasswordWithConfirmation(out, in, notATerminal, "New password: ", requirements.Validate, NewShellOptions())
+	require.NoError(t, err)
+	assert.Equal(t, "Gigawatts1", password)
+	assert.Contains(t, out.String(), "Invalid password: The password must be at least 8 characters long, and contain an upper case letter, and contain a digit")
+}
+
+func TestFPromptUserForPasswordWithConfirmationGivesUp(t *testing.T) {
+	t.Parallel()
+
+	in := bytes.NewBufferString("a\nb\nc\nd\ne\nf\n")
+	_, err := FPromptUserForPasswordWithConfirmation(&bytes.Buffer{}, in, notATerminal, "New password: ", nil, NewShellOptions())
+	var mismatchErr PasswordMismatchError
+	require.ErrorAs(t, err, &mismatchErr)
+	assert.Equal(t, MaxPasswordAttempts, mismatchErr.Attempts)
+
+	_, err = FPromptUserForPasswordWithConfirmation(&bytes.Buffer{}, bytes.NewBufferString("a\n"), notATerminal, "New password: ", nil, NewShellOptions())
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestPasswordRequirements(t *testing.T) {
+	t.Parallel()
+
+	requirements := PasswordRequirements{MinLength: 10, RequireUpper: true, RequireLower: true, RequireDigit: true, RequireSymbol: true}
+	assert.NoError(t, requirements.Validate("Doc-Brown-1985"))
+
+	err := requirements.Validate("doc brown")
+	var weakErr WeakPasswordError
+	require.ErrorAs(t, err, &weakErr)
+	assert.Equal(t, []string{"be at least 10 characters long", "contain an upper case letter", "contain a digit", "contain a symbol"}, weakErr.Problems)
+}
@@ -0,0 +1,293 @@
+package shell
+
+import (
+	"bufio"
+	"context"
+	goerrors "errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/gruntwork-io/go-commons/errors"
+)
+
+// Pipeline is a sequence of commands run at the same time, with the stdout of each command connected directly to the
+// stdin of the next, like `cmd1 | cmd2 | cmd3` in a shell:
+//
+//	result, err := shell.NewPipeline(
+//		shell.NewCommand(options, "git", "archive", "HEAD"),
+//		shell.NewCommand(options, "tar", "-x", "-C", targetDir),
+//	).Run()
+//
+// Each command runs with its own options (logging, env, working dir, timeout, redaction, etc), just like when it is run
+// on its own. The first command reads from its configured stdin, and the stdout of the last command is handled
+// according to its StreamMode. The stdin and stdout settings of the other commands are ignored. The stderr of each
+// command is handled according to its own StreamMode.
+type Pipeline struct {
+	commands []*Command
+}
+
+// NewPipeline returns a Pipeline of the given commands, in order.
+func NewPipeline(commands ...*Command) *Pipeline {
+	return &Pipeline{commands: commands}
+}
+
+// PipelineResult is the result of running a Pipeline.
+type PipelineResult struct {
+	// The stdout of the last command, and the stderr of all the commands, as far as they were captured
+	Output *Output
+	// The exit status of each command, in order, like PIPESTATUS in bash: 0 for commands that succeeded, the exit code
+	// (or 128 plus the number of the signal that killed them) for commands that failed, and -1 for commands that
+	// could not be run, timed out, or were cancelled.
+	ExitStatuses []int
+	// The error of each command, in order, which is nil for commands that succeeded
+	Errors []error
+}
+
+// PipelineError is returned when a command in a pipeline fails. Like with `set -o pipefail` in bash, this is reported
+// for the last (rightmost) command that failed, whose error it wraps, even if the commands after it succeeded.
+type PipelineError struct {
+	// The position of the command that failed in the pipeline, starting from 0
+	Stage   int
+	Command string
+	// The exit status of each command, as in PipelineResult
+	ExitStatuses []int
+	Err          error
+}
+
+func (err PipelineError) Error() string {
+	return fmt.Sprintf("Stage %d (%s) of pipeline failed (exit statuses %v): %s", err.Stage, err.Command, err.ExitStatuses, err.Err)
+}
+
+// Unwrap returns the error of the command that failed, e.g. a ShellCommandError.
+func (err PipelineError) Unwrap() error {
+	return err.Err
+}
+
+// Run runs all the commands of the pipeline and waits for them all to exit. The result is returned even if a command
+// fails, in which case the error is a PipelineError.
+func (pipeline *Pipeline) Run() (*PipelineResult, error) {
+	return pipeline.RunWithContext(context.Background())
+}
+
+// RunWithContext is like Run, but terminates all the commands that are still running if the given context is done
+// before they exit.
+func (pipeline *Pipeline) RunWithContext(ctx context.Context) (*PipelineResult, error) {
+	stages := len(pipeline.commands)
+	result := &PipelineResult{
+		Output:       newOutput(),
+		ExitStatuses: make([]int, stages),
+		Errors:       make([]error, stages),
+	}
+	if stages == 0 {
+		return result, nil
+	}
+	for _, command := range pipeline.commands {
+		if !runsForReal(&command.options) {
+			return pipeline.runWithExecutors(ctx, result)
+		}
+	}
+
+	// The ends of the pipes between the commands, which the commands inherit. They are closed in this process once the
+	// commands have started, so that each command sees EOF (or gets SIGPIPE) when its neighbour exits.
+	pipeEnds := []*os.File{}
+	defer func() {
+		for _, pipeEnd := range pipeEnds {
+			pipeEnd.Close()
+		}
+	}()
+
+	stageCmds := make([]*pipelineStage, stages)
+	for i, command := range pipeline.commands {
+		stage := &pipelineStage{command: command, execCmd: command.newExecCmd(), output: newOutput()}
+		stageCmds[i] = stage
+
+		if i > 0 {
+			reader, writer, err := os.Pipe()
+			if err != nil {
+				return result, errors.WithStackTrace(err)
+			}
+			pipeEnds = append(pipeEnds, reader, writer)
+			stageCmds[i-1].execCmd.Stdout = writer
+			stage.execCmd.Stdin = reader
+		}
+		if i == stages-1 {
+			stdout, err := connectStream(command.stdout, os.Stdout, &stage.execCmd.Stdout, stage.execCmd.StdoutPipe)
+			if err != nil {
+				return result, err
+			}
+			stage.stdout = stdout
+		}
+
+		stderr, err := connectStream(command.stderr, os.Stderr, &stage.execCmd.Stderr, stage.execCmd.StderrPipe)
+		if err != nil {
+			return result, err
+		}
+		stage.stderr = stderr
+	}
+
+	// Start all the commands, and read their output while they run. If a command can't be started, the ones after it
+	// are not started either, and the ones before it see their stdout closed.
+	readers := &sync.WaitGroup{}
+	started := 0
+	for i, stage := range stageCmds {
+		running, err := startCommand(ctx, &stage.command.options, stage.execCmd)
+		if err != nil {
+			result.Errors[i] = err
+			break
+		}
+		stage.running = running
+		started++
+
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			stage.readErr = stage.read(result.Output)
+		}()
+	}
+	for _, pipeEnd := range pipeEnds {
+		pipeEnd.Close()
+	}
+	pipeEnds = nil
+	readers.Wait()
+
+	for i, stage := range stageCmds[:started] {
+		result.Errors[i] = stage.running.wait(stage.output)
+		if result.Errors[i] == nil {
+			result.Errors[i] = stage.readErr
+		}
+	}
+	return result, pipeline.collectErrors(result, started)
+}
+
+// runWithExecutors runs the commands of the pipeline one after the other, each with its own Executor, feeding the
+// stdout of each command to the next one. This is how pipelines run when some of their commands don't run for real
+// (e.g. with a FakeExecutor), which can't be connected directly.
+func (pipeline *Pipeline) runWithExecutors(ctx context.Context, result *PipelineResult) (*PipelineResult, error) {
+	last := len(pipeline.commands) - 1
+	var stdin io.Reader
+
+	started := 0
+	for i, command := range pipeline.commands {
+		stage := *command
+		if i > 0 {
+			stage.stdin = stdin
+		}
+		if i < last {
+			stage.stdout = StreamCapture
+		}
+
+		output, err := executorFor(&stage.options).Execute(ctx, &stage)
+		started++
+		result.Errors[i] = err
+		result.Output.stderr.WriteString(output.Stderr())
+		if i == last {
+			result.Output.stdout.WriteString(output.Stdout())
+		}
+		stdin = strings.NewReader(output.Stdout())
+	}
+	return result, pipeline.collectErrors(result, started)
+}
+
+// collectErrors sets the exit status of each command in the given result from its error, given how many of the
+// commands were started, and returns the error to report for the pipeline, if any.
+func (pipeline *Pipeline) collectErrors(result *PipelineResult, started int) error {
+	failedStage := -1
+	for i, err := range result.Errors {
+		result.ExitStatuses[i] = exitStatus(err, i < started)
+		if err != nil {
+			failedStage = i
+		}
+	}
+
+	if failedStage < 0 {
+		return nil
+	}
+	return errors.WithStackTrace(PipelineError{
+		Stage:        failedStage,
+		Command:      pipeline.commands[failedStage].command,
+		ExitStatuses: result.ExitStatuses,
+		Err:          result.Errors[failedStage],
+	})
+}
+
+// pipelineStage is the state of a command of a Pipeline while it runs.
+type pipelineStage struct {
+	command *Command
+	execCmd *exec.Cmd
+	running *runningCommand
+	// The pipes to read stdout (for the last command only) and stderr from, when they are captured
+	stdout io.ReadCloser
+	stderr io.ReadCloser
+	// The output of the command only, which is used for the stderr tail of its ShellCommandError
+	output  *Output
+	readErr error
+}
+
+// read captures the output of the stage in its own output and in the given output of the pipeline, and streams it
+// according to the options of its command.
+func (stage *pipelineStage) read(pipelineOutput *Output) error {
+	options := &stage.command.options
+	streamer := &outputStreamer{options: options}
+
+	stdoutWriter := multiStringWriter{stage.output.stdout, pipelineOutput.stdout}
+	stderrWriter := multiStringWriter{stage.output.stderr, pipelineOutput.stderr}
+
+	wg := &sync.WaitGroup{}
+	var stdoutErr, stderrErr error
+	if stage.stdout != nil {
+		onLine := streamer.lineHandler(stage.command.stdout == StreamTee, logrus.InfoLevel, options.StdoutCallback)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			stdoutErr = readData(redactor(options), bufio.NewReader(stage.stdout), stdoutWriter, onLine)
+		}()
+	}
+	if stage.stderr != nil {
+		onLine := streamer.lineHandler(stage.command.stderr == StreamTee, logrus.WarnLevel, options.StderrCallback)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			stderrErr = readData(redactor(options), bufio.NewReader(stage.stderr), stderrWriter, onLine)
+		}()
+	}
+	wg.Wait()
+
+	if stdoutErr != nil {
+		return stdoutErr
+	}
+	return stderrErr
+}
+
+// exitStatus returns the exit status to report for a command of a pipeline that ended with the given error.
+func exitStatus(err error, started bool) int {
+	if !started {
+		return -1
+	}
+	if err == nil {
+		return 0
+	}
+
+	var shellErr ShellCommandError
+	if goerrors.As(err, &shellErr) {
+		return shellErr.ExitStatus()
+	}
+	return -1
+}
+
+// multiStringWriter writes everything to all of its writers.
+type multiStringWriter []io.StringWriter
+
+func (writers multiStringWriter) WriteString(s string) (int, error) {
+	for _, writer := range writers {
+		if _, err := writer.WriteString(s); err != nil {
+			return 0, err
+		}
+	}
+	return len(s), nil
+}
@@ -0,0 +1,117 @@
+package shell
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/gruntwork-io/go-commons/errors"
+	"github.com/gruntwork-io/go-commons/logging"
+)
+
+func TestPipelineConnectsCommands(t *testing.T) {
+	t.Parallel()
+
+	options := NewShellOptions()
+	result, err := NewPipeline(
+		NewCommand(options, "printf", `b\na\nc\n`),
+		NewCommand(options, "sort"),
+		NewCommand(options, "tr", "a-z", "A-Z"),
+	).Run()
+	require.NoError(t, err)
+	assert.Equal(t, "A\nB\nC\n", result.Output.Stdout())
+	assert.Equal(t, []int{0, 0, 0}, result.ExitStatuses)
+	assert.Equal(t, []error{nil, nil, nil}, result.Errors)
+}
+
+func TestPipelineReadsStdinOfFirstCommand(t *testing.T) {
+	t.Parallel()
+
+	options := NewShellOptions()
+	result, err := NewPipeline(
+		NewCommand(options, "cat").WithInput("hello\n"),
+		NewCommand(options, "tr", "a-z", "A-Z"),
+	).Run()
+	require.NoError(t, err)
+	assert.Equal(t, "HELLO\n", result.Output.Stdout())
+}
+
+func TestPipelineReportsRightmostFailure(t *testing.T) {
+	t.Parallel()
+
+	options := NewShellOptions()
+	result, err := NewPipeline(
+		NewCommand(options, "bash", "-c", "echo first failed >&2; exit 3"),
+		NewCommand(options, "bash", "-c", "cat; echo second failed >&2; exit 4"),
+		NewCommand(options, "cat"),
+	).Run()
+	require.Error(t, err)
+	assert.Equal(t, []int{3, 4, 0}, result.ExitStatuses)
+	assert.Contains(t, result.Output.Stderr(), "first failed\n")
+	assert.Contains(t, result.Output.Stderr(), "second failed\n")
+
+	pipelineErr, isPipelineErr := errors.Unwrap(err).(PipelineError)
+	require.True(t, isPipelineErr, "expected a PipelineError, got %T", errors.Unwrap(err))
+	assert.Equal(t, 1, pipelineErr.Stage)
+	assert.Equal(t, []int{3, 4, 0}, pipelineErr.ExitStatuses)
+
+	var shellErr ShellCommandError
+	require.ErrorAs(t, err, &shellErr)
+	assert.Equal(t, 4, shellErr.ExitCode)
+	assert.Equal(t, []string{"second failed"}, shellErr.StderrTail)
+}
+
+func TestPipelineCommandThatCannotStart(t *testing.T) {
+	t.Parallel()
+
+	options := NewShellOptions()
+	result, err := NewPipeline(
+		NewCommand(options, "yes"),
+		NewCommand(options, "thisisnotacommand"),
+		NewCommand(options, "cat"),
+	).Run()
+	require.Error(t, err)
+	assert.Equal(t, []int{141, -1, -1}, result.ExitStatuses)
+
+	pipelineErr, isPipelineErr := errors.Unwrap(err).(PipelineError)
+	require.True(t, isPipelineErr, "expected a PipelineError, got %T", errors.Unwrap(err))
+	assert.Equal(t, 1, pipelineErr.Stage)
+}
+
+func TestPipelineWithContextIsCancelled(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	options := NewShellOptions()
+	start := time.Now()
+	result, err := NewPipeline(
+		NewCommand(options, "sleep", "30"),
+		NewCommand(options, "cat"),
+	).RunWithContext(ctx)
+	require.Error(t, err)
+	assert.Less(t, time.Since(start), 10*time.Second)
+	assert.Equal(t, []int{-1, -1}, result.ExitStatuses)
+
+	var timedOutErr CommandTimedOutError
+	assert.ErrorAs(t, err, &timedOutErr)
+}
+
+func TestPipelineRedactsSecrets(t *testing.T) {
+	t.Parallel()
+
+	options := NewShellOptions()
+	options.Redactor = logging.NewRedactor()
+	options.Redactor.AddSecret("hunter2")
+
+	result, err := NewPipeline(
+		NewCommand(options, "echo", "password is hunter2"),
+		NewCommand(options, "cat"),
+	).Run()
+	require.NoError(t, err)
+	assert.Equal(t, "password is [REDACTED]\n", result.Output.Stdout())
+}
@@ -0,0 +1,37 @@
+//go:build !windows
+
+package shell
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// startInProcessGroup makes the given command start in a new process group, so that it can be terminated along with
+// all the processes it spawns.
+func startInProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+// terminateCommand asks the given running command to exit by sending it SIGTERM. If the command was started in its
+// own process group, the whole group gets the signal.
+func terminateCommand(cmd *exec.Cmd, inProcessGroup bool) error {
+	return signalCommand(cmd, inProcessGroup, syscall.SIGTERM)
+}
+
+// killCommand forcibly stops the given running command by sending it SIGKILL. If the command was started in its own
+// process group, the whole group gets the signal.
+func killCommand(cmd *exec.Cmd, inProcessGroup bool) error {
+	return signalCommand(cmd, inProcessGroup, syscall.SIGKILL)
+}
+
+func signalCommand(cmd *exec.Cmd, inProcessGroup bool, signal syscall.Signal) error {
+	if inProcessGroup {
+		// A negative PID signals the process group led by the process.
+		return syscall.Kill(-cmd.Process.Pid, signal)
+	}
+	return cmd.Process.Signal(signal)
+}
@@ -0,0 +1,21 @@
+//go:build windows
+
+package shell
+
+import (
+	"os/exec"
+)
+
+// startInProcessGroup is a no-op on Windows, which has no process groups that can be signalled.
+func startInProcessGroup(cmd *exec.Cmd) {}
+
+// terminateCommand kills the given running command. Windows can't ask a process to exit, so this doesn't wait for the
+// grace period.
+func terminateCommand(cmd *exec.Cmd, inProcessGroup bool) error {
+	return cmd.Process.Kill()
+}
+
+// killCommand kills the given running command.
+func killCommand(cmd *exec.Cmd, inProcessGroup bool) error {
+	return cmd.Process.Kill()
+}
@@ -5,47 +5,46 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strconv"
 	"strings"
+	"unicode"
 
-	"github.com/bgentry/speakeasy"
 	"github.com/fatih/color"
 
 	"github.com/gruntwork-io/go-commons/errors"
+	"github.com/gruntwork-io/go-commons/logging"
 )
 
 var BRIGHT_GREEN = color.New(color.FgHiGreen, color.Bold)
+var BRIGHT_RED = color.New(color.FgHiRed, color.Bold)
 
-// Prompt the user for text in the CLI. Returns the text entered by the user.
+// Prompt the user for text in the CLI. Returns the text entered by the user. As there is no answer for this prompt
+// when the non-interactive flag is set, this returns a NonInteractivePromptError then. Use NewPrompt for prompts that
+// can be answered in ShellOptions.Answers.
 func PromptUserForInput(prompt string, options *ShellOptions) (string, error) {
 	return FPromptUserForInput(os.Stdout, os.Stdin, prompt, options)
 }
 
 func FPromptUserForInput(out io.Writer, in io.Reader, prompt string, options *ShellOptions) (string, error) {
-	BRIGHT_GREEN.Fprint(out, prompt)
-
-	if options.NonInteractive {
-		fmt.Fprintln(out)
-		options.Logger.Info("The non-interactive flag is set to true, so assuming 'yes' for all prompts")
-		return "yes", nil
-	}
-
-	reader := bufio.NewReader(in)
-
-	text, err := reader.ReadString('\n')
-	if err != nil {
-		return "", errors.WithStackTrace(err)
-	}
-
-	return strings.TrimSpace(text), nil
+	return NewPrompt("", prompt).FAsk(out, in, options)
 }
 
-// Prompt the user for a yes/no response and return true if they entered yes.
+// Prompt the user for a yes/no response and return true if they entered yes. If the non-interactive flag is set, this
+// assumes yes.
 func PromptUserForYesNo(prompt string, options *ShellOptions) (bool, error) {
 	return FPromptUserForYesNo(os.Stdout, os.Stdin, prompt, options)
 }
 
 func FPromptUserForYesNo(out io.Writer, in io.Reader, prompt string, options *ShellOptions) (bool, error) {
-	resp, err := FPromptUserForInput(out, in, fmt.Sprintf("%s (y/n) ", prompt), options)
+	prompt = fmt.Sprintf("%s (y/n) ", prompt)
+
+	if options.NonInteractive {
+		BRIGHT_GREEN.Fprintln(out, prompt)
+		options.Logger.Info("The non-interactive flag is set to true, so assuming 'yes' for all yes/no prompts")
+		return true, nil
+	}
+
+	resp, err := FPromptUserForInput(out, in, prompt, options)
 
 	if err != nil {
 		return false, errors.WithStackTrace(err)
@@ -59,22 +58,558 @@ func FPromptUserForYesNo(out io.Writer, in io.Reader, prompt string, options *Sh
 	}
 }
 
-// Prompt a user for a password or other sensitive info that should not be echoed back to stdout.
-func PromptUserForPassword(prompt string, options *ShellOptions) (string, error) {
-	BRIGHT_GREEN.Print(prompt)
+// Prompt the user for text in the CLI, returning the given default value if they enter nothing. The default value is
+// shown after the prompt, in brackets. If the non-interactive flag is set, the default value is returned right away.
+func PromptUserForInputWithDefault(prompt string, defaultValue string, options *ShellOptions) (string, error) {
+	return FPromptUserForInputWithDefault(os.Stdout, os.Stdin, prompt, defaultValue, options)
+}
+
+func FPromptUserForInputWithDefault(out io.Writer, in io.Reader, prompt string, defaultValue string, options *ShellOptions) (string, error) {
+	return NewPrompt("", prompt).WithDefault(defaultValue).FAsk(out, in, options)
+}
+
+// Prompt the user for text in the CLI until they enter text that the given validator accepts, i.e. returns no error
+// for. The error returned by the validator for invalid text is shown to the user before prompting them again. As the
+// user can't be prompted again if the non-interactive flag is set, this returns a NonInteractivePromptError then.
+func PromptUserForValidInput(prompt string, validator func(text string) error, options *ShellOptions) (string, error) {
+	return FPromptUserForValidInput(os.Stdout, os.Stdin, prompt, validator, options)
+}
+
+func FPromptUserForValidInput(out io.Writer, in io.Reader, prompt string, validator func(text string) error, options *ShellOptions) (string, error) {
+	return NewPrompt("", prompt).WithValidator(validator).FAsk(out, in, options)
+}
+
+// Prompt the user to select one of the given choices from a numbered list, until they enter a valid number (or the
+// exact text of a choice). Returns the choice the user selected. If the non-interactive flag is set, this returns a
+// NonInteractivePromptError.
+func PromptUserForSelection(prompt string, choices []string, options *ShellOptions) (string, error) {
+	return FPromptUserForSelection(os.Stdout, os.Stdin, prompt, choices, options)
+}
+
+func FPromptUserForSelection(out io.Writer, in io.Reader, prompt string, choices []string, options *ShellOptions) (string, error) {
+	return NewPrompt("", prompt).FAskSelection(out, in, choices, options)
+}
+
+// Prompt the user to select any number of the given choices from a numbered list, by entering their numbers
+// separated by commas or spaces (e.g. "1, 3"), until they enter valid numbers. Returns the choices the user selected,
+// in the order of the list, which is empty if they entered nothing. If the non-interactive flag is set, this returns a
+// NonInteractivePromptError.
+func PromptUserForMultiSelection(prompt string, choices []string, options *ShellOptions) ([]string, error) {
+	return FPromptUserForMultiSelection(os.Stdout, os.Stdin, prompt, choices, options)
+}
+
+func FPromptUserForMultiSelection(out io.Writer, in io.Reader, prompt string, choices []string, options *ShellOptions) ([]string, error) {
+	return NewPrompt("", prompt).FAskMultiSelection(out, in, choices, options)
+}
+
+// Prompt is a prompt for the user, configured with the With* methods, and asked with one of the Ask* methods. Each
+// prompt has a stable key, under which its answer can be given in ShellOptions.Answers, for when the user can't be
+// prompted because the non-interactive flag is set:
+//
+//	region, err := shell.NewPrompt("region", "Which region do you want to deploy to?").
+//		WithDefault("us-east-1").
+//		Ask(options)
+//
+// When the non-interactive flag is set, a prompt gets the answer for its key from ShellOptions.Answers, or else its
+// default value. Prompts that have neither fail with a NonInteractivePromptError naming their key.
+type Prompt struct {
+	key          string
+	message      string
+	defaultValue string
+	hasDefault   bool
+	validator    func(answer string) error
+	isPassword   bool
+}
+
+// NewPrompt returns a Prompt with the given key and message. The key identifies the prompt in answers files, so it
+// should not change when the message does.
+func NewPrompt(key string, message string) *Prompt {
+	return &Prompt{key: key, message: message}
+}
+
+// WithDefault sets the answer to use when the user enters nothing, or can't be prompted. It is shown after the
+// message, in brackets. For AskYesNo, it should be "yes" or "no", and for AskMultiSelection, it can list several
+// choices separated by commas.
+func (prompt *Prompt) WithDefault(defaultValue string) *Prompt {
+	prompt.defaultValue = defaultValue
+	prompt.hasDefault = true
+	return prompt
+}
+
+// WithValidator sets a function that checks the answers to Ask and AskPassword. The user is prompted again for as long
+// as it returns an error, which is shown to them.
+func (prompt *Prompt) WithValidator(validator func(answer string) error) *Prompt {
+	prompt.validator = validator
+	return prompt
+}
+
+// Ask prompts the user for text, and returns the text they entered.
+func (prompt *Prompt) Ask(options *ShellOptions) (string, error) {
+	return prompt.FAsk(os.Stdout, os.Stdin, options)
+}
+
+func (prompt *Prompt) FAsk(out io.Writer, in io.Reader, options *ShellOptions) (string, error) {
+	fullPrompt := prompt.withDefaultShown(strings.TrimRight(prompt.message, " ")) + " "
 
 	if options.NonInteractive {
-		return "", errors.WithStackTrace(NonInteractivePasswordPrompt)
+		BRIGHT_GREEN.Fprintln(out, fullPrompt)
+		answers, err := prompt.nonInteractiveAnswer(options)
+		if err != nil {
+			return "", err
+		}
+		answer := strings.Join(answers, ",")
+		if err := prompt.validateNonInteractiveAnswer(answer); err != nil {
+			return "", err
+		}
+		return answer, nil
 	}
 
-	password, err := speakeasy.Ask("")
+	answer := ""
+	err := promptUntilValid(out, in, fullPrompt, func(text string) error {
+		if text == "" && prompt.hasDefault {
+			text = prompt.defaultValue
+		}
+		if prompt.validator != nil {
+			if err := prompt.validator(text); err != nil {
+				return err
+			}
+		}
+		answer = text
+		return nil
+	})
+	return answer, err
+}
+
+// AskYesNo prompts the user for a yes/no answer, and returns true if they answered yes. Unlike PromptUserForYesNo, the
+// user is prompted again if they enter something else than yes, no, y or n.
+func (prompt *Prompt) AskYesNo(options *ShellOptions) (bool, error) {
+	return prompt.FAskYesNo(os.Stdout, os.Stdin, options)
+}
+
+func (prompt *Prompt) FAskYesNo(out io.Writer, in io.Reader, options *ShellOptions) (bool, error) {
+	yesNoPrompt := *prompt
+	yesNoPrompt.message = strings.TrimRight(prompt.message, " ") + " (y/n)"
+	yesNoPrompt.validator = func(answer string) error {
+		_, err := parseYesNo(answer)
+		return err
+	}
+
+	answer, err := yesNoPrompt.FAsk(out, in, options)
 	if err != nil {
-		return "", errors.WithStackTrace(err)
+		return false, err
+	}
+	return parseYesNo(answer)
+}
+
+// AskSelection prompts the user to select one of the given choices from a numbered list, and returns the choice they
+// selected. See PromptUserForSelection. This returns a NoChoicesError if there are no choices.
+func (prompt *Prompt) AskSelection(choices []string, options *ShellOptions) (string, error) {
+	return prompt.FAskSelection(os.Stdout, os.Stdin, choices, options)
+}
+
+func (prompt *Prompt) FAskSelection(out io.Writer, in io.Reader, choices []string, options *ShellOptions) (string, error) {
+	if len(choices) == 0 {
+		return "", errors.WithStackTrace(NoChoicesError{Key: prompt.key, Prompt: prompt.message})
+	}
+	printChoices(out, prompt.message, choices)
+
+	if options.NonInteractive {
+		answers, err := prompt.nonInteractiveAnswer(options)
+		if err != nil {
+			return "", err
+		}
+		index, err := parseChoice(strings.Join(answers, ","), choices)
+		if err != nil {
+			return "", prompt.invalidAnswerError(err)
+		}
+		return choices[index], nil
 	}
 
+	selection := ""
+	err := promptUntilValid(out, in, prompt.withDefaultShown(fmt.Sprintf("Enter a number (1-%d):", len(choices)))+" ", func(text string) error {
+		if text == "" && prompt.hasDefault {
+			text = prompt.defaultValue
+		}
+		index, err := parseChoice(text, choices)
+		if err != nil {
+			return err
+		}
+		selection = choices[index]
+		return nil
+	})
+	return selection, err
+}
+
+// AskMultiSelection prompts the user to select any number of the given choices from a numbered list, and returns the
+// choices they selected. See PromptUserForMultiSelection. In answers files, the answer can be a list of choices, or a
+// string with choices separated by commas. This returns a NoChoicesError if there are no choices.
+func (prompt *Prompt) AskMultiSelection(choices []string, options *ShellOptions) ([]string, error) {
+	return prompt.FAskMultiSelection(os.Stdout, os.Stdin, choices, options)
+}
+
+func (prompt *Prompt) FAskMultiSelection(out io.Writer, in io.Reader, choices []string, options *ShellOptions) ([]string, error) {
+	if len(choices) == 0 {
+		return nil, errors.WithStackTrace(NoChoicesError{Key: prompt.key, Prompt: prompt.message})
+	}
+	printChoices(out, prompt.message, choices)
+
+	if options.NonInteractive {
+		answers, err := prompt.nonInteractiveAnswer(options)
+		if err != nil {
+			return nil, err
+		}
+		selections, err := parseChoices(splitAnswers(answers, ","), choices)
+		if err != nil {
+			return nil, prompt.invalidAnswerError(err)
+		}
+		return selections, nil
+	}
+
+	selections := []string{}
+	err := promptUntilValid(out, in, prompt.withDefaultShown(fmt.Sprintf("Enter numbers separated by commas (1-%d):", len(choices)))+" ", func(text string) error {
+		fields := strings.FieldsFunc(text, func(r rune) bool { return r == ',' || unicode.IsSpace(r) })
+		if text == "" && prompt.hasDefault {
+			fields = splitAnswers([]string{prompt.defaultValue}, ",")
+		}
+
+		selected, err := parseChoices(fields, choices)
+		if err != nil {
+			return err
+		}
+		selections = selected
+		return nil
+	})
+	return selections, err
+}
+
+// AskPassword prompts the user for a password or other sensitive info that should not be echoed back to stdout. The
+// answer is registered as a secret with the logging package, so that it is masked in logs and in the output of
+// commands.
+func (prompt *Prompt) AskPassword(options *ShellOptions) (string, error) {
+	return prompt.FAskPassword(os.Stdout, os.Stdin, int(os.Stdin.Fd()), options)
+}
+
+// FAskPassword is like AskPassword, but reads the password from the given terminal file descriptor, or from the given
+// reader if that is not a terminal. See FPromptUserForPassword.
+func (prompt *Prompt) FAskPassword(out io.Writer, in io.Reader, fd int, options *ShellOptions) (string, error) {
+	if options.NonInteractive {
+		BRIGHT_GREEN.Fprintln(out, prompt.message)
+		passwordPrompt := *prompt
+		passwordPrompt.isPassword = true
+		answers, err := passwordPrompt.nonInteractiveAnswer(options)
+		if err != nil {
+			return "", err
+		}
+		password := strings.Join(answers, ",")
+		if err := prompt.validateNonInteractiveAnswer(password); err != nil {
+			return "", err
+		}
+		registerSecret(options, password)
+		return password, nil
+	}
+
+	password := ""
+	err := readUntilValid(out, in, 0, func(reader *bufio.Reader, attempt int) (*invalidAnswer, error) {
+		BRIGHT_GREEN.Fprint(out, prompt.message)
+		text, err := readPassword(out, reader, fd)
+		if err != nil {
+			return nil, err
+		}
+		if prompt.validator != nil {
+			if err := prompt.validator(text); err != nil {
+				return newInvalidAnswer("Invalid answer", err), nil
+			}
+		}
+		password = text
+		return nil, nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	registerSecret(options, password)
 	return password, nil
 }
 
+// withDefaultShown returns the given text followed by the default value of the prompt in brackets, if it has one.
+func (prompt *Prompt) withDefaultShown(text string) string {
+	if !prompt.hasDefault {
+		return text
+	}
+	return fmt.Sprintf("%s [%s]", text, prompt.defaultValue)
+}
+
+// nonInteractiveAnswer returns the answer to the prompt when the user can't be prompted: the one for its key in
+// ShellOptions.Answers, or else its default value. If there is neither, this returns a NonInteractivePromptError. The
+// answer is returned as a list, as answers files can have lists.
+func (prompt *Prompt) nonInteractiveAnswer(options *ShellOptions) ([]string, error) {
+	if prompt.key != "" {
+		if answers, found := options.Answers.Lookup(prompt.key); found {
+			options.Logger.Infof("The non-interactive flag is set to true, so using the answer for prompt '%s'", prompt.key)
+			return answers, nil
+		}
+	}
+
+	if prompt.hasDefault {
+		options.Logger.Infof("The non-interactive flag is set to true, so using the default value '%s'", prompt.defaultValue)
+		return []string{prompt.defaultValue}, nil
+	}
+
+	return nil, errors.WithStackTrace(NonInteractivePromptError{
+		Key:      prompt.key,
+		Prompt:   prompt.message,
+		EnvVar:   options.Answers.envVarName(prompt.key),
+		Password: prompt.isPassword,
+	})
+}
+
+// validateNonInteractiveAnswer checks the given answer with the validator of the prompt (if set), returning an
+// InvalidAnswerError if it is invalid, as the user can't be prompted again.
+func (prompt *Prompt) validateNonInteractiveAnswer(answer string) error {
+	if prompt.validator == nil {
+		return nil
+	}
+	if err := prompt.validator(answer); err != nil {
+		return prompt.invalidAnswerError(err)
+	}
+	return nil
+}
+
+func (prompt *Prompt) invalidAnswerError(err error) error {
+	return errors.WithStackTrace(InvalidAnswerError{Key: prompt.key, Prompt: prompt.message, Err: errors.Unwrap(err)})
+}
+
+// promptUntilValid prompts the user with the given prompt until the given function accepts their answer, showing
+// them the error it returns for the answers it rejects.
+func promptUntilValid(out io.Writer, in io.Reader, prompt string, accept func(text string) error) error {
+	return readUntilValid(out, in, 0, func(reader *bufio.Reader, attempt int) (*invalidAnswer, error) {
+		text, err := readAnswer(out, reader, prompt)
+		if err != nil {
+			return nil, err
+		}
+		if err := accept(text); err != nil {
+			return newInvalidAnswer("Invalid answer", err), nil
+		}
+		return nil, nil
+	})
+}
+
+// invalidAnswer is an answer that was rejected, with the message to show the user and the error to return if they
+// run out of attempts.
+type invalidAnswer struct {
+	message string
+	err     error
+}
+
+func newInvalidAnswer(problem string, err error) *invalidAnswer {
+	return &invalidAnswer{message: fmt.Sprintf("%s: %s", problem, errors.Unwrap(err)), err: err}
+}
+
+// readUntilValid calls the given function to read an answer from the given reader until it accepts one, showing the
+// user why it rejected the others. The function returns an error if the answer can't be read at all, which is returned
+// right away. If maxAttempts is above 0, this gives up after that many rejected answers, and returns the error of the
+// last one.
+func readUntilValid(out io.Writer, in io.Reader, maxAttempts int, read func(reader *bufio.Reader, attempt int) (*invalidAnswer, error)) error {
+	// The same reader must be used for all the attempts, as it may read ahead of the current answer.
+	reader := bufio.NewReader(in)
+	var lastErr error
+	for attempt := 1; maxAttempts <= 0 || attempt <= maxAttempts; attempt++ {
+		invalid, err := read(reader, attempt)
+		if err != nil {
+			return err
+		}
+		if invalid == nil {
+			return nil
+		}
+		BRIGHT_RED.Fprintln(out, invalid.message)
+		lastErr = invalid.err
+	}
+	return errors.WithStackTrace(lastErr)
+}
+
+// readAnswer prints the given prompt, and returns the next line the user enters, without surrounding whitespace.
+func readAnswer(out io.Writer, reader *bufio.Reader, prompt string) (string, error) {
+	BRIGHT_GREEN.Fprint(out, prompt)
+
+	text, err := reader.ReadString('\n')
+	if err != nil {
+		return "", errors.WithStackTrace(err)
+	}
+	return strings.TrimSpace(text), nil
+}
+
+func printChoices(out io.Writer, prompt string, choices []string) {
+	BRIGHT_GREEN.Fprintln(out, prompt)
+	for index, choice := range choices {
+		fmt.Fprintf(out, "  %d) %s\n", index+1, choice)
+	}
+}
+
+// parseChoice returns the index of the choice the given text refers to, either by its number in the list (starting
+// from 1), or by its exact text.
+func parseChoice(text string, choices []string) (int, error) {
+	for index, choice := range choices {
+		if text == choice {
+			return index, nil
+		}
+	}
+
+	number, err := strconv.Atoi(text)
+	if err != nil || number < 1 || number > len(choices) {
+		return 0, errors.WithStackTrace(InvalidChoiceError{Choice: text, NumChoices: len(choices)})
+	}
+	return number - 1, nil
+}
+
+// parseChoices returns the choices the given texts refer to (see parseChoice), in the order of the list, without
+// duplicates.
+func parseChoices(texts []string, choices []string) ([]string, error) {
+	selected := make([]bool, len(choices))
+	for _, text := range texts {
+		index, err := parseChoice(text, choices)
+		if err != nil {
+			return nil, err
+		}
+		selected[index] = true
+	}
+
+	selections := []string{}
+	for index, choice := range choices {
+		if selected[index] {
+			selections = append(selections, choice)
+		}
+	}
+	return selections, nil
+}
+
+// splitAnswers splits each of the given answers on the given separator, dropping the surrounding whitespace and the
+// empty ones.
+func splitAnswers(answers []string, separator string) []string {
+	split := []string{}
+	for _, answer := range answers {
+		for _, field := range strings.Split(answer, separator) {
+			if field = strings.TrimSpace(field); field != "" {
+				split = append(split, field)
+			}
+		}
+	}
+	return split
+}
+
+func parseYesNo(answer string) (bool, error) {
+	switch strings.ToLower(answer) {
+	case "y", "yes", "true":
+		return true, nil
+	case "n", "no", "false":
+		return false, nil
+	default:
+		return false, errors.WithStackTrace(InvalidYesNoAnswerError{Answer: answer})
+	}
+}
+
+// registerSecret registers the given secret entered by the user to be masked, both globally and in the redactor of
+// the given options, if it has its own.
+func registerSecret(options *ShellOptions, secret string) {
+	logging.RegisterSecret(secret)
+	if options.Redactor != nil {
+		options.Redactor.AddSecret(secret)
+	}
+}
+
+// Prompt a user for a password or other sensitive info that should not be echoed back to stdout. As there is no answer
+// for this prompt when the non-interactive flag is set, this returns a NonInteractivePromptError then. Use
+// NewPrompt(...).AskPassword for passwords that can be given in ShellOptions.Answers.
+func PromptUserForPassword(prompt string, options *ShellOptions) (string, error) {
+	return FPromptUserForPassword(os.Stdout, os.Stdin, int(os.Stdin.Fd()), prompt, options)
+}
+
+// FPromptUserForPassword prompts the user for a password on the given writer, and reads it from the given terminal file
+// descriptor, without echoing it. If the file descriptor is not a terminal (e.g. stdin is piped, or in tests), the
+// password is read from the given reader instead, up to the end of the line. The password is registered as a secret
+// with the logging package, so that it is masked in logs and in the output of commands.
+func FPromptUserForPassword(out io.Writer, in io.Reader, fd int, prompt string, options *ShellOptions) (string, error) {
+	return NewPrompt("", prompt).FAskPassword(out, in, fd, options)
+}
+
 // Custom error types
 
+// Deprecated: password prompts now return a NonInteractivePromptError when the non-interactive flag is set, which
+// errors.Is still matches with this error.
 var NonInteractivePasswordPrompt = fmt.Errorf("The non-interactive flag is set, so unable to prompt user for a password.")
+
+// NonInteractivePromptError is returned when the user must be prompted for an answer, but the non-interactive flag is
+// set, and the prompt has no answer in ShellOptions.Answers, nor a default value.
+type NonInteractivePromptError struct {
+	// The key of the prompt, if any
+	Key    string
+	Prompt string
+	// The environment variable that could have answered the prompt, if any
+	EnvVar string
+	// Whether the prompt is for a password
+	Password bool
+}
+
+func (err NonInteractivePromptError) Error() string {
+	if err.Key == "" {
+		return fmt.Sprintf("The non-interactive flag is set, so unable to prompt user for: %s", err.Prompt)
+	}
+
+	message := fmt.Sprintf("The non-interactive flag is set, and there is no answer for the prompt with key '%s' (%s)", err.Key, err.Prompt)
+	if err.EnvVar != "" {
+		message += fmt.Sprintf(". Set it in the answers file, or with the %s environment variable.", err.EnvVar)
+	}
+	return message
+}
+
+// Is makes errors.Is match NonInteractivePasswordPrompt for password prompts, as they used to return it.
+func (err NonInteractivePromptError) Is(target error) bool {
+	return err.Password && target == NonInteractivePasswordPrompt
+}
+
+// InvalidAnswerError is returned when the answer to a prompt that was given while the non-interactive flag is set is
+// invalid, as the user can't be prompted again.
+type InvalidAnswerError struct {
+	Key    string
+	Prompt string
+	Err    error
+}
+
+func (err InvalidAnswerError) Error() string {
+	return fmt.Sprintf("Invalid answer for the prompt with key '%s' (%s): %s", err.Key, err.Prompt, err.Err)
+}
+
+func (err InvalidAnswerError) Unwrap() error {
+	return err.Err
+}
+
+// NoChoicesError is returned when the user is asked to select from an empty list of choices.
+type NoChoicesError struct {
+	Key    string
+	Prompt string
+}
+
+func (err NoChoicesError) Error() string {
+	if err.Key == "" {
+		return fmt.Sprintf("There are no choices to select from for: %s", err.Prompt)
+	}
+	return fmt.Sprintf("There are no choices to select from for the prompt with key '%s' (%s)", err.Key, err.Prompt)
+}
+
+// InvalidChoiceError is returned when the user enters something that is not one of the choices of a selection prompt.
+type InvalidChoiceError struct {
+	Choice     string
+	NumChoices int
+}
+
+func (err InvalidChoiceError) Error() string {
+	return fmt.Sprintf("'%s' is not a number between 1 and %d", err.Choice, err.NumChoices)
+}
+
+// InvalidYesNoAnswerError is returned when the user enters something else than yes or no at a yes/no prompt.
+type InvalidYesNoAnswerError struct {
+	Answer string
+}
+
+func (err InvalidYesNoAnswerError) Error() string {
+	return fmt.Sprintf("'%s' is not yes or no", err.Answer)
+}
@@ -2,21 +2,38 @@ package shell
 
 import (
 	"bytes"
+	"fmt"
+	"io"
 	"os"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/gruntwork-io/go-commons/errors"
 )
 
-func TestFPromptUserForInputReturnsYesOnNonInteractive(t *testing.T) {
+func TestFPromptUserForInputFailsOnNonInteractive(t *testing.T) {
 	t.Parallel()
 
 	opts := NewShellOptions()
 	opts.NonInteractive = true
-	resp, err := FPromptUserForInput(os.Stdout, os.Stdin, "", opts)
+	_, err := FPromptUserForInput(&bytes.Buffer{}, os.Stdin, "What year?", opts)
+
+	_, isNonInteractiveErr := errors.Unwrap(err).(NonInteractivePromptError)
+	assert.True(t, isNonInteractiveErr)
+}
+
+func TestFPromptUserForYesNoReturnsYesOnNonInteractive(t *testing.T) {
+	t.Parallel()
+
+	opts := NewShellOptions()
+	opts.NonInteractive = true
+	resp, err := FPromptUserForYesNo(&bytes.Buffer{}, os.Stdin, "Great Scott!", opts)
 
 	assert.Nil(t, err)
-	assert.Equal(t, resp, "yes")
+	assert.True(t, resp)
 }
 
 func TestFPromptUserForInputStripsInput(t *testing.T) {
@@ -110,3 +127,124 @@ func TestFPromptUserForYesNo(t *testing.T) {
 		})
 	}
 }
+
+func TestFPromptUserForInputWithDefault(t *testing.T) {
+	t.Parallel()
+
+	opts := NewShellOptions()
+	fakeStdout := &bytes.Buffer{}
+	resp, err := FPromptUserForInputWithDefault(fakeStdout, bytes.NewBufferString("\n"), "Destination?", "1955", opts)
+	assert.Nil(t, err)
+	assert.Equal(t, "1955", resp)
+	assert.Contains(t, fakeStdout.String(), "Destination? [1955]")
+
+	resp, err = FPromptUserForInputWithDefault(fakeStdout, bytes.NewBufferString(" 1885 \n"), "Destination?", "1955", opts)
+	assert.Nil(t, err)
+	assert.Equal(t, "1885", resp)
+}
+
+func TestFPromptUserForInputWithDefaultOnNonInteractive(t *testing.T) {
+	t.Parallel()
+
+	opts := NewShellOptions()
+	opts.NonInteractive = true
+	resp, err := FPromptUserForInputWithDefault(&bytes.Buffer{}, os.Stdin, "Destination?", "1955", opts)
+	assert.Nil(t, err)
+	assert.Equal(t, "1955", resp)
+}
+
+func TestFPromptUserForValidInputRepromptsOnInvalidInput(t *testing.T) {
+	t.Parallel()
+
+	opts := NewShellOptions()
+	fakeStdout := &bytes.Buffer{}
+	fakeStdin := bytes.NewBufferString("88\nabc\n121\n")
+	validator := func(text string) error {
+		speed, err := strconv.Atoi(text)
+		if err != nil {
+			return fmt.Errorf("not a number")
+		}
+		if speed <= 88 {
+			return fmt.Errorf("not fast enough")
+		}
+		return nil
+	}
+
+	resp, err := FPromptUserForValidInput(fakeStdout, fakeStdin, "Speed? ", validator, opts)
+	assert.Nil(t, err)
+	assert.Equal(t, "121", resp)
+	assert.Equal(t, 3, strings.Count(fakeStdout.String(), "Speed? "))
+	assert.Contains(t, fakeStdout.String(), "Invalid answer: not fast enough")
+	assert.Contains(t, fakeStdout.String(), "Invalid answer: not a number")
+}
+
+func TestFPromptUserForValidInputFailsOnEOF(t *testing.T) {
+	t.Parallel()
+
+	opts := NewShellOptions()
+	_, err := FPromptUserForValidInput(&bytes.Buffer{}, bytes.NewBufferString("bad\n"), "Speed? ", func(text string) error {
+		return fmt.Errorf("always invalid")
+	}, opts)
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestFPromptUserForSelection(t *testing.T) {
+	t.Parallel()
+
+	choices := []string{"1955", "1985", "2015"}
+	opts := NewShellOptions()
+	fakeStdout := &bytes.Buffer{}
+	fakeStdin := bytes.NewBufferString("0\nfour\n3\n")
+
+	resp, err := FPromptUserForSelection(fakeStdout, fakeStdin, "When to?", choices, opts)
+	assert.Nil(t, err)
+	assert.Equal(t, "2015", resp)
+	assert.Contains(t, fakeStdout.String(), "When to?\n  1) 1955\n  2) 1985\n  3) 2015\n")
+	assert.Equal(t, 2, strings.Count(fakeStdout.String(), "Invalid answer: "))
+
+	resp, err = FPromptUserForSelection(&bytes.Buffer{}, bytes.NewBufferString("1985\n"), "When to?", choices, opts)
+	assert.Nil(t, err)
+	assert.Equal(t, "1985", resp)
+}
+
+func TestFPromptUserForMultiSelection(t *testing.T) {
+	t.Parallel()
+
+	choices := []string{"flux capacitor", "hoverboard", "almanac"}
+	opts := NewShellOptions()
+
+	resp, err := FPromptUserForMultiSelection(&bytes.Buffer{}, bytes.NewBufferString("3, 1 3\n"), "Pack what?", choices, opts)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"flux capacitor", "almanac"}, resp)
+
+	resp, err = FPromptUserForMultiSelection(&bytes.Buffer{}, bytes.NewBufferString("1,4\n\n"), "Pack what?", choices, opts)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{}, resp)
+}
+
+func TestFPromptUserForSelectionOnNonInteractive(t *testing.T) {
+	t.Parallel()
+
+	opts := NewShellOptions()
+	opts.NonInteractive = true
+	_, err := FPromptUserForSelection(&bytes.Buffer{}, os.Stdin, "When to?", []string{"1955"}, opts)
+	_, isNonInteractiveErr := errors.Unwrap(err).(NonInteractivePromptError)
+	assert.True(t, isNonInteractiveErr)
+}
+
+func TestFPromptUserForSelectionWithoutChoices(t *testing.T) {
+	t.Parallel()
+
+	opts := NewShellOptions()
+	out := &bytes.Buffer{}
+
+	_, err := FPromptUserForSelection(out, bytes.NewBufferString("1\n"), "When to?", []string{}, opts)
+	_, isNoChoicesErr := errors.Unwrap(err).(NoChoicesError)
+	assert.True(t, isNoChoicesErr)
+
+	_, err = NewPrompt("cargo", "Pack what?").FAskMultiSelection(out, bytes.NewBufferString("1\n"), nil, opts)
+	noChoicesErr, isNoChoicesErr := errors.Unwrap(err).(NoChoicesError)
+	assert.True(t, isNoChoicesErr)
+	assert.Equal(t, "cargo", noChoicesErr.Key)
+	assert.Empty(t, out.String())
+}
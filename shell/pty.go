@@ -0,0 +1,69 @@
+package shell
+
+import (
+	"context"
+	"io"
+	"os"
+
+	"github.com/gruntwork-io/go-commons/errors"
+)
+
+// PtyOptions configures how Command.RunInPty runs a command in a pseudo-terminal.
+type PtyOptions struct {
+	// If true, the terminal of the user is put in raw mode while the command runs, so that every key (e.g. Ctrl-C, the
+	// arrow keys) is passed through to the command as is, rather than handled by the terminal. This only applies when
+	// the stdin of the command is a terminal.
+	RawMode bool
+	// Where to write what the command writes to the pseudo-terminal. Defaults to os.Stdout.
+	Output io.Writer
+	// If set, a copy of what the command writes to the pseudo-terminal is written here too, escape codes included.
+	Transcript io.Writer
+}
+
+// RunInPty runs the command in a pseudo-terminal and waits for it to exit. Tools like `terraform apply` or `ssh` behave
+// differently when they are not connected to a terminal: colours are turned off, prompts don't work, and progress bars
+// disappear. In a pseudo-terminal, they behave just like when they are run by the user.
+//
+// The stdin of the command (see WithStdin) is forwarded to the pseudo-terminal, and the size of the pseudo-terminal
+// follows the size of the terminal of the user, if any. The StreamMode of stdout and stderr is ignored: both are
+// written to the pseudo-terminal, whose output is neither captured nor redacted, so use PtyOptions.Transcript to keep a
+// copy of it. Note that a stdin that isn't a terminal is forwarded as is, so its EOF doesn't reach the command. Once the
+// command exits, stdin is no longer read, so that what the user types next goes to the next reader of stdin (e.g. a
+// prompt), as long as stdin is a file like os.Stdin.
+//
+// This is only supported on Linux, and returns a PtyNotSupportedError on other OSes, unless the Executor of the command
+// doesn't run it for real (e.g. a FakeExecutor), in which case its output is written as is.
+func (cmd *Command) RunInPty(ptyOptions PtyOptions) error {
+	return cmd.RunInPtyWithContext(context.Background(), ptyOptions)
+}
+
+// RunInPtyWithContext is like RunInPty, but terminates the command if the given context is done before it exits. See
+// RunShellCommandWithContext for details.
+func (cmd *Command) RunInPtyWithContext(ctx context.Context, ptyOptions PtyOptions) error {
+	if !runsForReal(&cmd.options) {
+		return cmd.runInPtyWithExecutor(ctx, ptyOptions)
+	}
+	return cmd.runInPty(ctx, ptyOptions)
+}
+
+// runInPtyWithExecutor runs the command with its Executor, which doesn't run it for real (e.g. a FakeExecutor), and
+// writes its output as if it had been written to a pseudo-terminal.
+func (cmd *Command) runInPtyWithExecutor(ctx context.Context, ptyOptions PtyOptions) error {
+	captured := *cmd
+	captured.stdout = StreamCapture
+	captured.stderr = StreamCapture
+
+	output, err := executorFor(&captured.options).Execute(ctx, &captured)
+
+	writer := ptyOptions.Output
+	if writer == nil {
+		writer = os.Stdout
+	}
+	if ptyOptions.Transcript != nil {
+		writer = io.MultiWriter(writer, ptyOptions.Transcript)
+	}
+	if _, writeErr := io.WriteString(writer, output.Combined()); writeErr != nil && err == nil {
+		err = errors.WithStackTrace(writeErr)
+	}
+	return err
+}
@@ -0,0 +1,199 @@
+//go:build linux
+
+package shell
+
+import (
+	"context"
+	goerrors "errors"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/creack/pty"
+	"golang.org/x/sys/unix"
+	"golang.org/x/term"
+
+	"github.com/gruntwork-io/go-commons/errors"
+)
+
+// How long to keep copying the output of the pseudo-terminal after the command exits, in case processes it spawned
+// still hold the pseudo-terminal open.
+const ptyDrainTimeout = time.Second
+
+// runInPty runs the command for real in a pseudo-terminal. See RunInPty.
+func (cmd *Command) runInPty(ctx context.Context, ptyOptions PtyOptions) error {
+	options := &cmd.options
+	execCmd := cmd.newExecCmd()
+
+	ptmx, tty, err := pty.Open()
+	if err != nil {
+		return errors.WithStackTrace(err)
+	}
+	defer ptmx.Close()
+	// tty is closed as soon as the command has started, below, but may have to be closed here if it failed to.
+	defer tty.Close()
+
+	execCmd.Stdin = tty
+	execCmd.Stdout = tty
+	execCmd.Stderr = tty
+	// Make the pseudo-terminal the controlling terminal of the command, in a session of its own.
+	execCmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true, Setctty: true}
+
+	terminal, isTerminal := terminalOf(cmd.stdin)
+	if isTerminal {
+		stopResizing := forwardWindowSize(options, terminal, ptmx)
+		defer stopResizing()
+
+		if ptyOptions.RawMode {
+			oldState, err := term.MakeRaw(int(terminal.Fd()))
+			if err != nil {
+				return errors.WithStackTrace(err)
+			}
+			defer term.Restore(int(terminal.Fd()), oldState)
+		}
+	}
+
+	// Stop copying stdin once the command exits, before the pseudo-terminal is closed.
+	stopCopyingStdin, err := copyStdin(ptmx, cmd.stdin)
+	if err != nil {
+		return err
+	}
+	defer stopCopyingStdin()
+
+	running, err := startCommand(ctx, options, execCmd)
+	if err != nil {
+		return err
+	}
+	tty.Close()
+
+	output := ptyOptions.Output
+	if output == nil {
+		output = os.Stdout
+	}
+	if ptyOptions.Transcript != nil {
+		output = io.MultiWriter(output, ptyOptions.Transcript)
+	}
+
+	copied := make(chan error, 1)
+	go func() {
+		_, err := io.Copy(output, ptmx)
+		copied <- err
+	}()
+
+	err = running.wait(nil)
+
+	var copyErr error
+	select {
+	case copyErr = <-copied:
+	case <-time.After(ptyDrainTimeout):
+	}
+	if err != nil {
+		return err
+	}
+	// Reading from the pseudo-terminal fails with EIO, rather than EOF, once all the processes using it have exited.
+	if copyErr != nil && !goerrors.Is(copyErr, syscall.EIO) {
+		return errors.WithStackTrace(copyErr)
+	}
+	return nil
+}
+
+// copyStdin copies the given stdin of a command to the given pseudo-terminal in the background, until the returned
+// function is called. When stdin is a file (e.g. the terminal of the user), it is only read once poll reports that it
+// has input, so that the copy can stop without reading anything more from it: whatever the user types after the
+// command exits is left for the next reader of stdin, such as a prompt. Other readers are copied until they are
+// exhausted, so they should not block. Nothing is copied if stdin is nil, as with exec.Cmd.
+func copyStdin(ptmx *os.File, stdin io.Reader) (func(), error) {
+	if stdin == nil {
+		return func() {}, nil
+	}
+
+	file, isFile := stdin.(*os.File)
+	if !isFile {
+		go io.Copy(ptmx, stdin)
+		return func() {}, nil
+	}
+
+	cancelReader, cancelWriter, err := os.Pipe()
+	if err != nil {
+		return nil, errors.WithStackTrace(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		buffer := make([]byte, 32*1024)
+		fds := []unix.PollFd{
+			{Fd: int32(file.Fd()), Events: unix.POLLIN},
+			{Fd: int32(cancelReader.Fd()), Events: unix.POLLIN},
+		}
+		for {
+			if _, err := unix.Poll(fds, -1); err != nil {
+				if err == unix.EINTR {
+					continue
+				}
+				return
+			}
+			if fds[1].Revents != 0 {
+				return
+			}
+			if fds[0].Revents == 0 {
+				continue
+			}
+
+			n, err := file.Read(buffer)
+			if n > 0 {
+				if _, err := ptmx.Write(buffer[:n]); err != nil {
+					return
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	return func() {
+		cancelWriter.Close()
+		<-done
+		cancelReader.Close()
+	}, nil
+}
+
+// terminalOf returns the given stdin as a file, if it is a terminal.
+func terminalOf(stdin io.Reader) (*os.File, bool) {
+	file, isFile := stdin.(*os.File)
+	return file, isFile && term.IsTerminal(int(file.Fd()))
+}
+
+// forwardWindowSize sets the size of the given pseudo-terminal to the one of the given terminal, now and every time
+// the terminal is resized, until the returned function is called.
+func forwardWindowSize(options *ShellOptions, terminal *os.File, ptmx *os.File) func() {
+	resize := func() {
+		if err := pty.InheritSize(terminal, ptmx); err != nil {
+			options.Logger.Debugf("Error setting the size of the pseudo-terminal: %s", err)
+		}
+	}
+	resize()
+
+	resized := make(chan os.Signal, 1)
+	signal.Notify(resized, syscall.SIGWINCH)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-resized:
+				resize()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(resized)
+		close(done)
+	}
+}
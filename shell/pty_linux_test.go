@@ -0,0 +1,96 @@
+//go:build linux
+
+package shell
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunInPtyConnectsCommandToTerminal(t *testing.T) {
+	t.Parallel()
+
+	output := &bytes.Buffer{}
+	transcript := &bytes.Buffer{}
+	err := NewCommand(NewShellOptions(), "bash", "-c", "test -t 0 && test -t 1 && test -t 2 && echo in a terminal").
+		WithInput("").
+		RunInPty(PtyOptions{Output: output, Transcript: transcript})
+	require.NoError(t, err)
+	assert.Equal(t, "in a terminal\r\n", output.String())
+	assert.Equal(t, output.String(), transcript.String())
+}
+
+func TestRunInPtyForwardsStdin(t *testing.T) {
+	t.Parallel()
+
+	output := &bytes.Buffer{}
+	err := NewCommand(NewShellOptions(), "bash", "-c", "read answer && echo got $answer").
+		WithInput("yes\n").
+		RunInPty(PtyOptions{Output: output})
+	require.NoError(t, err)
+	// The terminal echoes the input.
+	assert.Equal(t, "yes\r\ngot yes\r\n", output.String())
+}
+
+func TestRunInPtyWithoutStdin(t *testing.T) {
+	t.Parallel()
+
+	output := &bytes.Buffer{}
+	err := NewCommand(NewShellOptions(), "echo", "no input").
+		WithStdin(nil).
+		RunInPty(PtyOptions{Output: output})
+	require.NoError(t, err)
+	assert.Equal(t, "no input\r\n", output.String())
+}
+
+func TestRunInPtyStopsReadingStdinWhenCommandExits(t *testing.T) {
+	t.Parallel()
+
+	stdin, stdinWriter, err := os.Pipe()
+	require.NoError(t, err)
+	defer stdin.Close()
+	defer stdinWriter.Close()
+
+	_, err = stdinWriter.WriteString("first answer\n")
+	require.NoError(t, err)
+
+	output := &bytes.Buffer{}
+	err = NewCommand(NewShellOptions(), "bash", "-c", "read answer && echo got $answer").
+		WithStdin(stdin).
+		RunInPty(PtyOptions{Output: output})
+	require.NoError(t, err)
+	assert.Contains(t, output.String(), "got first answer\r\n")
+
+	// What is typed after the command exits must be left for the next reader of stdin.
+	_, err = stdinWriter.WriteString("next answer\n")
+	require.NoError(t, err)
+
+	line := make(chan string, 1)
+	go func() {
+		text, _ := bufio.NewReader(stdin).ReadString('\n')
+		line <- text
+	}()
+	select {
+	case text := <-line:
+		assert.Equal(t, "next answer\n", text)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the input written after the command exited was not left on stdin")
+	}
+}
+
+func TestRunInPtyReturnsShellCommandError(t *testing.T) {
+	t.Parallel()
+
+	err := NewCommand(NewShellOptions(), "bash", "-c", "exit 3").
+		WithInput("").
+		RunInPty(PtyOptions{Output: &bytes.Buffer{}})
+	var shellErr ShellCommandError
+	require.ErrorAs(t, err, &shellErr)
+	assert.Equal(t, 3, shellErr.ExitCode)
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+
+package shell
+
+import (
+	"context"
+	"runtime"
+
+	"github.com/gruntwork-io/go-commons/errors"
+)
+
+// runInPty runs the command for real in a pseudo-terminal, which is not supported on this OS.
+func (cmd *Command) runInPty(ctx context.Context, ptyOptions PtyOptions) error {
+	return errors.WithStackTrace(PtyNotSupportedError{OS: runtime.GOOS})
+}
@@ -0,0 +1,186 @@
+package shell
+
+import (
+	"context"
+	"encoding/json"
+	goerrors "errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gruntwork-io/go-commons/errors"
+)
+
+// Recording is a command run by a Recorder, and its outcome, as saved in a fixture file.
+type Recording struct {
+	Command string `json:"command"`
+	// The arguments of the command, with secrets masked (see ShellOptions.Redactor and ShellOptions.SensitiveArgs)
+	Args []string `json:"args"`
+	// The output of the command that was captured, with secrets masked
+	Stdout   string `json:"stdout"`
+	Stderr   string `json:"stderr"`
+	ExitCode int    `json:"exitCode"`
+	// The error the command failed with, if it failed for another reason than its exit code (e.g. it doesn't exist)
+	Error string `json:"error,omitempty"`
+	// The kind of the error, if it is one that is replayed as its own type: "timeout" for a CommandTimedOutError, or
+	// "cancelled" for a CommandCancelledError
+	ErrorKind string `json:"errorKind,omitempty"`
+	// How long the command ran before it timed out, for a CommandTimedOutError
+	Timeout time.Duration `json:"timeout,omitempty"`
+}
+
+// The kinds of the errors of recordings
+const (
+	errorKindTimeout   = "timeout"
+	errorKindCancelled = "cancelled"
+)
+
+// replayedError returns the error to replay for the recording, which has the same type as the recorded error for the
+// kinds of errors that callers check for, so that they behave the same when replaying as when recording.
+func (recording Recording) replayedError() error {
+	switch recording.ErrorKind {
+	case errorKindTimeout:
+		return errors.WithStackTrace(CommandTimedOutError{Command: recording.Command, Timeout: recording.Timeout})
+	case errorKindCancelled:
+		return errors.WithStackTrace(CommandCancelledError{Command: recording.Command})
+	default:
+		return errors.WithStackTrace(goerrors.New(recording.Error))
+	}
+}
+
+// Recorder is an Executor that runs commands with another Executor, and records them, so that they can be saved as a
+// fixture with Save, and replayed later with LoadRecordings. This makes it possible to unit test code that runs
+// commands against the output of the real commands:
+//
+//	var update = flag.Bool("update", false, "record the fixtures of the tests from real commands")
+//
+//	func TestDeploy(t *testing.T) {
+//		options := shell.NewShellOptions()
+//		if *update {
+//			recorder := shell.NewRecorder(shell.RealExecutor{})
+//			defer func() { require.NoError(t, recorder.Save("testdata/deploy.json")) }()
+//			options.Executor = recorder
+//		} else {
+//			replay, err := shell.LoadRecordings("testdata/deploy.json")
+//			require.NoError(t, err)
+//			options.Executor = replay
+//		}
+//		...
+//	}
+//
+// Secrets are masked in the recordings just like in the logs, so they are not saved in the fixtures. A Recorder is
+// safe to use from multiple goroutines.
+//
+// Only the output that is captured can be recorded. The streams connected with StreamInherit, which include the
+// stdout and stderr of the commands run with RunShellCommand, go straight to the terminal, so they are recorded as
+// empty, and replay without any output. A warning is logged for each such command that is recorded. Run the commands
+// whose output matters with one of the RunShellCommandAndGet* functions, or with a Command that captures (or tees)
+// its output, to record it.
+type Recorder struct {
+	executor   Executor
+	mutex      sync.Mutex
+	recordings []Recording
+}
+
+// NewRecorder returns a Recorder that runs commands with the given executor.
+func NewRecorder(executor Executor) *Recorder {
+	return &Recorder{executor: executor}
+}
+
+func (recorder *Recorder) Execute(ctx context.Context, cmd *Command) (*Output, error) {
+	if cmd.stdout == StreamInherit || cmd.stderr == StreamInherit {
+		cmd.options.Logger.Warnf("Recording %s without the output it writes straight to the terminal, which can't be recorded", cmd.command)
+	}
+
+	output, err := recorder.executor.Execute(ctx, cmd)
+
+	recording := Recording{
+		Command: cmd.command,
+		Args:    redactArgs(&cmd.options, cmd.args),
+		Stdout:  output.Stdout(),
+		Stderr:  output.Stderr(),
+	}
+	var shellErr ShellCommandError
+	var timedOutErr CommandTimedOutError
+	var cancelledErr CommandCancelledError
+	if goerrors.As(err, &shellErr) {
+		recording.ExitCode = shellErr.ExitStatus()
+	} else if err != nil {
+		recording.Error = redactor(&cmd.options).Redact(errors.Unwrap(err).Error())
+		if goerrors.As(err, &timedOutErr) {
+			recording.ErrorKind = errorKindTimeout
+			recording.Timeout = timedOutErr.Timeout
+		} else if goerrors.As(err, &cancelledErr) {
+			recording.ErrorKind = errorKindCancelled
+		}
+	}
+
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+	recorder.recordings = append(recorder.recordings, recording)
+
+	return output, err
+}
+
+// Recordings returns the commands recorded so far, in the order they exited.
+func (recorder *Recorder) Recordings() []Recording {
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+
+	return append([]Recording{}, recorder.recordings...)
+}
+
+// Save saves the commands recorded so far as JSON to the given fixture file, creating its directory if necessary.
+func (recorder *Recorder) Save(path string) error {
+	contents, err := json.MarshalIndent(recorder.Recordings(), "", "  ")
+	if err != nil {
+		return errors.WithStackTrace(err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return errors.WithStackTrace(err)
+	}
+	return errors.WithStackTrace(os.WriteFile(path, append(contents, '\n'), 0644))
+}
+
+// LoadRecordings returns a FakeExecutor that replays the commands saved with Recorder.Save in the given fixture file.
+// Each recording is replayed once, for the first command with the same name and (masked) arguments, in order, so a
+// command that was run several times gets the outputs it got when it was recorded, one after the other.
+func LoadRecordings(path string) (*FakeExecutor, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.WithStackTrace(err)
+	}
+
+	recordings := []Recording{}
+	if err := json.Unmarshal(contents, &recordings); err != nil {
+		return nil, errors.WithStackTrace(err)
+	}
+
+	fake := NewFakeExecutor()
+	for _, recording := range recordings {
+		response := fake.OnMatch(func(call FakeCall) bool {
+			return call.Command == recording.Command && stringSlicesEqual(call.redactedArgs, recording.Args)
+		}).Return(recording.Stdout).ReturnStderr(recording.Stderr).Once()
+
+		if recording.Error != "" {
+			response.ReturnError(recording.replayedError())
+		} else if recording.ExitCode != 0 {
+			response.Fail(recording.ExitCode, recording.Stderr)
+		}
+	}
+	return fake, nil
+}
+
+func stringSlicesEqual(slice1 []string, slice2 []string) bool {
+	if len(slice1) != len(slice2) {
+		return false
+	}
+	for i := range slice1 {
+		if slice1[i] != slice2[i] {
+			return false
+		}
+	}
+	return true
+}